@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/thanhfphan/svg2sheet/internal/config"
+)
+
+// configFile is the path given with --config
+var configFile string
+
+// applyConfigFile loads --config into cfg. Flags set on the command line keep
+// their values; everything else is taken from the file.
+func applyConfigFile(cmd *cobra.Command) error {
+	if configFile == "" {
+		return nil
+	}
+
+	fileCfg, err := config.LoadFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	// Flags are bound to the fields of cfg, so replace it wholesale and then
+	// restore the values that were given explicitly
+	explicit := make(map[*pflag.Flag]string)
+	slices := make(map[*pflag.Flag][]string)
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			slices[flag] = slice.GetSlice()
+			return
+		}
+		explicit[flag] = flag.Value.String()
+	})
+
+	cfg = *fileCfg
+
+	// Set would add the values of repeatable flags to those of the file
+	for flag, values := range slices {
+		if err := flag.Value.(pflag.SliceValue).Replace(values); err != nil {
+			return err
+		}
+	}
+	for flag, value := range explicit {
+		if err := flag.Value.Set(value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"text/tabwriter"
@@ -10,6 +11,16 @@ import (
 	"github.com/thanhfphan/svg2sheet/internal/svg"
 )
 
+var convertersJSON bool
+
+// allConverterTypes lists the converter backends in the order they are shown
+var allConverterTypes = []config.ConverterType{
+	config.ConverterOkSVG,
+	config.ConverterRod,
+	config.ConverterRSVG,
+	config.ConverterInkscape,
+}
+
 // convertersCmd represents the converters command
 var convertersCmd = &cobra.Command{
 	Use:   "converters",
@@ -24,7 +35,10 @@ Examples:
   svg2sheet converters
 
   # List converters with verbose output
-  svg2sheet converters --verbose`,
+  svg2sheet converters --verbose
+
+  # List converters as JSON for scripts
+  svg2sheet converters --json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runConvertersList()
 	},
@@ -33,6 +47,7 @@ Examples:
 func init() {
 	rootCmd.AddCommand(convertersCmd)
 	convertersCmd.Flags().BoolVarP(&cfg.Verbose, "verbose", "v", false, "Show detailed converter information")
+	convertersCmd.Flags().BoolVar(&convertersJSON, "json", false, "Print the converters as a JSON array instead of a table")
 }
 
 func runConvertersList() error {
@@ -45,14 +60,12 @@ func runConvertersList() error {
 
 	// Create registry and options
 	registry := svg.NewConverterRegistry()
-	options := svg.NewConversionOptions(tempConfig)
-
-	// Get all converter types
-	converterTypes := []config.ConverterType{
-		config.ConverterOkSVG,
-		config.ConverterRod,
-		config.ConverterRSVG,
-		config.ConverterInkscape,
+	options := svg.NewConversionOptions(tempConfig, tempConfig.NewLogger())
+
+	converterTypes := allConverterTypes
+
+	if convertersJSON {
+		return printConvertersJSON(registry, options, converterTypes)
 	}
 
 	fmt.Println("SVG Converter Backends")
@@ -138,3 +151,23 @@ func runConvertersList() error {
 
 	return nil
 }
+
+// printConvertersJSON writes the information of every converter to stdout as
+// a JSON array, with the reason for each one that is not available
+func printConvertersJSON(registry *svg.ConverterRegistry, options *svg.ConversionOptions, converterTypes []config.ConverterType) error {
+	infos := make([]*svg.ConverterInfo, 0, len(converterTypes))
+	for _, converterType := range converterTypes {
+		info, err := registry.GetConverterInfo(converterType, options)
+		if err != nil {
+			return err
+		}
+		infos = append(infos, info)
+	}
+
+	data, err := json.MarshalIndent(infos, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal converters: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/png"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thanhfphan/svg2sheet/internal/config"
+	"github.com/thanhfphan/svg2sheet/internal/svg"
+	"github.com/thanhfphan/svg2sheet/internal/utils"
+)
+
+var (
+	doctorInput   string
+	doctorTimeout string
+)
+
+// doctorCmd times every converter backend on a sample SVG
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Time every available converter backend on a sample SVG",
+	Long: `Render a sample SVG with every converter backend that is installed and
+print them ranked by speed, with the size of each result. Backends that are
+missing or fail are listed with the reason, which helps to diagnose a missing
+Chrome or a broken rsvg-convert. Each render is limited by --timeout, so a
+hanging backend is reported instead of blocking the others.
+
+Examples:
+  # Find the fastest backend for your SVGs
+  svg2sheet doctor --input sample.svg
+
+  # Give slow backends more time
+  svg2sheet doctor --input complex.svg --timeout 2m`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rendered, err := runDoctor(cmd.Context())
+		if err != nil {
+			return err
+		}
+		if !rendered {
+			// The failures were already listed; usage would bury them
+			cmd.SilenceUsage = true
+			return fmt.Errorf("no converter backend could render %s", doctorInput)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().StringVarP(&doctorInput, "input", "i", "", "Sample SVG file to render with each backend (required)")
+	doctorCmd.Flags().StringVar(&doctorTimeout, "timeout", "", "Time limit for each backend, e.g. 30s or 2m; 0 disables it (default: 30s)")
+	doctorCmd.MarkFlagRequired("input")
+}
+
+// doctorResult is the outcome of rendering the sample with one backend
+type doctorResult struct {
+	info     *svg.ConverterInfo
+	duration time.Duration
+	width    int
+	height   int
+	err      error
+}
+
+// runDoctor renders the sample with every backend, prints the ranking and
+// reports whether any backend rendered it
+func runDoctor(ctx context.Context) (bool, error) {
+	doctorConfig := &config.Config{
+		Input:     doctorInput,
+		Converter: string(config.ConverterOkSVG),
+		Timeout:   doctorTimeout,
+		Verbose:   cfg.Verbose,
+	}
+	doctorConfig.SetDefaults()
+
+	if err := utils.ValidateInputPath(doctorInput); err != nil {
+		return false, err
+	}
+	if doctorConfig.IsStdinInput() || !doctorConfig.IsSVGInput() {
+		return false, fmt.Errorf("doctor needs a single SVG file as input: %s", doctorInput)
+	}
+	timeout, err := doctorConfig.RenderTimeout()
+	if err != nil {
+		return false, err
+	}
+
+	registry := svg.NewConverterRegistry()
+	options := svg.NewConversionOptions(doctorConfig, doctorConfig.NewLogger())
+
+	fmt.Printf("Rendering %s with each converter backend\n\n", doctorInput)
+
+	var results []doctorResult
+	for _, converterType := range allConverterTypes {
+		info, err := registry.GetConverterInfo(converterType, options)
+		if err != nil {
+			return false, err
+		}
+
+		result := doctorResult{info: info}
+		if info.Available {
+			result.duration, result.width, result.height, result.err = timeConverter(ctx, registry, options, converterType, timeout)
+		}
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		results = append(results, result)
+	}
+
+	// Successful backends first, fastest first; the rest keep their order
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.ok() != b.ok() {
+			return a.ok()
+		}
+		return a.ok() && a.duration < b.duration
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RANK\tTYPE\tNAME\tTIME\tSIZE\tSTATUS")
+	fmt.Fprintln(w, "----\t----\t----\t----\t----\t------")
+	for i, result := range results {
+		switch {
+		case result.ok():
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%dx%d\t✅ ok\n", i+1, result.info.Type, result.info.Name,
+				result.duration.Round(time.Millisecond), result.width, result.height)
+		case !result.info.Available:
+			fmt.Fprintf(w, "-\t%s\t%s\t-\t-\t❌ not available: %s\n", result.info.Type, result.info.Name, result.info.Reason)
+		default:
+			fmt.Fprintf(w, "-\t%s\t%s\t%s\t-\t❌ failed: %v\n", result.info.Type, result.info.Name,
+				result.duration.Round(time.Millisecond), result.err)
+		}
+	}
+	w.Flush()
+	fmt.Println()
+
+	if !results[0].ok() {
+		return false, nil
+	}
+	fmt.Printf("Fastest backend: %s (use --converter %s)\n", results[0].info.Name, results[0].info.Type)
+	return true, nil
+}
+
+// ok reports whether the backend rendered the sample
+func (r doctorResult) ok() bool {
+	return r.info.Available && r.err == nil
+}
+
+// timeConverter renders the sample with one backend to a temporary PNG and
+// returns how long it took and the size of the result. The render is
+// abandoned after timeout, when it is not 0, even if the backend does not
+// stop by itself.
+func timeConverter(ctx context.Context, registry *svg.ConverterRegistry, options *svg.ConversionOptions, converterType config.ConverterType, timeout time.Duration) (time.Duration, int, int, error) {
+	converter, err := registry.Create(converterType, options)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer converter.Close()
+
+	output, err := utils.CreateTempFile(".png")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer os.Remove(output)
+
+	var runCtx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		runCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- converter.ConvertFile(runCtx, doctorInput, output)
+	}()
+
+	select {
+	case err = <-done:
+	case <-runCtx.Done():
+		err = runCtx.Err()
+	}
+	duration := time.Since(start)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = &svg.RenderTimeoutError{Timeout: timeout}
+	}
+	if err != nil {
+		return duration, 0, 0, err
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		return duration, 0, 0, err
+	}
+	defer file.Close()
+
+	size, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return duration, 0, 0, fmt.Errorf("unreadable output: %w", err)
+	}
+	return duration, size.Width, size.Height, nil
+}
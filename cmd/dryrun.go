@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+	"github.com/thanhfphan/svg2sheet/internal/utils"
+	"github.com/thanhfphan/svg2sheet/pkg/svg2sheet"
+)
+
+// printFilePlan reports the planned conversion of a single SVG
+func printFilePlan(file svg2sheet.FileResult) {
+	fmt.Println("Dry run: no files will be written")
+
+	output := file.Output
+	if cfg.IsStdoutOutput() {
+		output = "stdout"
+	}
+
+	if cfg.IsStdinInput() {
+		fmt.Printf("Would convert SVG from stdin -> %s with %s\n", output, file.Backend)
+		return
+	}
+
+	fmt.Printf("Would convert %s -> %s (%dx%d) with %s\n", file.Input, output, file.Width, file.Height, file.Backend)
+}
+
+// printConversionPlan reports the planned per-file conversions of a directory
+func printConversionPlan(files []svg2sheet.FileResult) error {
+	fmt.Println("Dry run: no files will be written")
+	fmt.Printf("Would convert %d files into %s\n\n", len(files), cfg.Output)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tINPUT\tOUTPUT\tACTION")
+
+	for i, file := range files {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", i, file.Input, file.Output, file.Action)
+	}
+
+	return w.Flush()
+}
+
+// printSheetPlan reports the planned sheet size and every sprite's placement
+func printSheetPlan(meta *svg2sheet.Metadata) error {
+	fmt.Println("Dry run: no files will be written")
+	if cfg.IsStdoutOutput() {
+		fmt.Printf("Output:   stdout (%s)\n", cfg.StdoutEncoding)
+	} else {
+		fmt.Printf("Output:   %s\n", cfg.Output)
+	}
+	if cfg.Meta != "" {
+		fmt.Printf("Metadata: %s (%s)\n", cfg.Meta, cfg.MetaFormat)
+	}
+	if cfg.MetaCSV != "" {
+		fmt.Printf("Metadata: %s (csv)\n", cfg.MetaCSV)
+	}
+	if scales, _ := cfg.SheetScales(); len(scales) > 0 {
+		sheets := make([]string, len(scales))
+		for i, density := range scales {
+			sheets[i] = fmt.Sprintf("%gx %s", density, config.ScaledPath(cfg.Output, density))
+		}
+		fmt.Printf("Scales:   %s; the layout below is the %gx sheet\n", strings.Join(sheets, ", "), meta.Density)
+	}
+	fmt.Printf("Sprites:  %d (sorted by %s)\n", len(meta.Sprites), cfg.Sort)
+	if len(meta.Animations) > 0 {
+		animations := make([]string, len(meta.Animations))
+		for i, animation := range meta.Animations {
+			animations[i] = fmt.Sprintf("%s %d-%d", animation.Name, animation.From, animation.To)
+		}
+		fmt.Printf("Tags:     %s\n", strings.Join(animations, ", "))
+	}
+	if len(meta.Pages) > 1 {
+		fmt.Printf("Pages:    %d, up to %dx%d (%s, %s, ...)\n", len(meta.Pages), meta.Width, meta.Height,
+			utils.PagePath(cfg.Output, 0), utils.PagePath(cfg.Output, 1))
+	}
+	spacing := fmt.Sprintf("padding %d", meta.Padding)
+	if meta.Margin > 0 {
+		spacing += fmt.Sprintf(", margin %d", meta.Margin)
+	}
+	if cfg.IsGIFOutput() {
+		fmt.Printf("GIF:      %dx%d, %d frames %s apart\n", meta.Width, meta.Height, len(meta.Sprites), cfg.FrameInterval)
+	} else if meta.Packed {
+		fmt.Printf("Sheet:    %dx%d packed, %s\n", meta.Width, meta.Height, spacing)
+	} else {
+		fmt.Printf("Sheet:    %dx%d, %d cols x %d rows of %dx%d tiles, %s\n",
+			meta.Width, meta.Height, meta.Cols, meta.Rows, meta.TileWidth, meta.TileHeight, spacing)
+	}
+	if meta.ContentWidth > 0 && (cfg.SheetWidth > 0 || cfg.SheetHeight > 0) {
+		fmt.Printf("Content:  %dx%d, on a fixed %dx%d sheet\n", meta.ContentWidth, meta.ContentHeight, meta.Width, meta.Height)
+	} else if meta.ContentWidth > 0 {
+		fmt.Printf("Content:  %dx%d, rounded up to %dx%d\n", meta.ContentWidth, meta.ContentHeight, meta.Width, meta.Height)
+	}
+	// Auto tiles are only known once the plan has measured the sprites
+	sized := cfg
+	if cfg.AutoTile && !meta.Packed {
+		sized.TileWidth, sized.TileHeight = meta.TileWidth, meta.TileHeight
+	}
+	fmt.Printf("Memory:   ~%d MB (max %d MB)\n", utils.EstimateMemoryUsage(&sized, len(meta.Sprites))/(1024*1024), cfg.MaxMemory)
+	if err := utils.ValidateMemoryUsage(&sized, len(meta.Sprites)); err != nil {
+		fmt.Printf("Warning:  %v\n", err)
+	}
+
+	// These depend on the rendered pixels, which a dry run does not produce
+	if config.SortMode(cfg.Sort).IsRenderedSort() {
+		fmt.Printf("Note:     %s ordering needs rendered sprites; placements are shown in name order\n", cfg.Sort)
+	}
+	if cfg.Dedupe {
+		fmt.Println("Note:     duplicates are found in the rendered sprites; every sprite is shown with its own region")
+	}
+	if rotated := countRotated(meta.Sprites); rotated > 0 {
+		fmt.Printf("Note:     %d sprites are turned 90 degrees clockwise; their width and height are swapped\n", rotated)
+	}
+	if cfg.Pack && cfg.Trim {
+		fmt.Println("Note:     sprite sizes are shown before trimming")
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tNAME\tPAGE\tX\tY\tWIDTH\tHEIGHT\tSOURCE")
+
+	for i, sprite := range meta.Sprites {
+		source := sprite.Source
+		if source == "" {
+			source = "-"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%d\t%d\t%d\t%s\n",
+			i, sprite.Name, sprite.Page, sprite.X, sprite.Y, sprite.Width, sprite.Height, source)
+	}
+
+	return w.Flush()
+}
+
+// countRotated returns how many sprites are turned on the sheet
+func countRotated(sprites []svg2sheet.SpriteInfo) int {
+	rotated := 0
+	for _, sprite := range sprites {
+		if sprite.Rotated {
+			rotated++
+		}
+	}
+	return rotated
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// envPrefix is prepended to flag names to form environment variable names,
+// e.g. --tile-width is read from SVG2SHEET_TILE_WIDTH
+const envPrefix = "SVG2SHEET_"
+
+// envExcludedFlags lists flags that cannot be supplied through the environment
+var envExcludedFlags = map[string]bool{
+	"input":  true,
+	"output": true,
+	"config": true,
+	"help":   true,
+}
+
+// applyEnvDefaults fills flags that were not set on the command line from
+// SVG2SHEET_* environment variables. Explicit flags always take precedence,
+// and built-in defaults apply when neither is present.
+func applyEnvDefaults(cmd *cobra.Command) error {
+	var firstErr error
+
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if firstErr != nil || flag.Changed || envExcludedFlags[flag.Name] {
+			return
+		}
+
+		name := envVarName(flag.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return
+		}
+
+		if err := flag.Value.Set(value); err != nil {
+			firstErr = fmt.Errorf("invalid value %q for %s: %w", value, name, err)
+		}
+	})
+
+	return firstErr
+}
+
+// envVarName returns the environment variable name for a flag
+func envVarName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/thanhfphan/svg2sheet/internal/metadata"
+	"github.com/thanhfphan/svg2sheet/internal/utils"
+)
+
+var (
+	extractSheet  string
+	extractMeta   string
+	extractOutput string
+	extractForce  bool
+)
+
+// extractCmd slices the sprites of a spritesheet back into separate images
+var extractCmd = &cobra.Command{
+	Use:   "extract",
+	Short: "Slice the sprites of a spritesheet back into separate PNGs",
+	Long: `Write every sprite listed in a sheet's native JSON metadata as its own
+PNG named after the sprite. Sprites of a sheet packed with --trim are placed
+back at their recorded offset on a canvas of their untrimmed size, so they
+match the original images.
+
+Examples:
+  # Extract sprites to edit them
+  svg2sheet extract --sheet sheet.png --meta sheet.json --output ./sprites`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExtract()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(extractCmd)
+	extractCmd.Flags().StringVar(&extractSheet, "sheet", "", "Spritesheet image, or the --output path of a sheet split into pages (required)")
+	extractCmd.Flags().StringVar(&extractMeta, "meta", "", "Native JSON metadata written for the sheet (required)")
+	extractCmd.Flags().StringVarP(&extractOutput, "output", "o", "", "Directory to write one PNG per sprite into (required)")
+	extractCmd.Flags().BoolVar(&extractForce, "force", false, "Overwrite existing sprite files")
+	extractCmd.MarkFlagRequired("sheet")
+	extractCmd.MarkFlagRequired("meta")
+	extractCmd.MarkFlagRequired("output")
+}
+
+func runExtract() error {
+	log := cfg.NewLogger()
+	exporter := metadata.NewExporter(&cfg, log)
+
+	meta, err := exporter.LoadMetadata(extractMeta)
+	if err != nil {
+		return err
+	}
+	if err := exporter.ValidateMetadata(meta); err != nil {
+		return fmt.Errorf("invalid metadata: %w", err)
+	}
+
+	pagePaths := sheetPagePaths(extractSheet, meta)
+	pages := make([]image.Image, len(pagePaths))
+	for i, path := range pagePaths {
+		if pages[i], err = decodeSheet(path); err != nil {
+			return fmt.Errorf("failed to load %s: %w", path, err)
+		}
+	}
+
+	if err := os.MkdirAll(extractOutput, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	for _, sprite := range meta.Sprites {
+		outputFile := filepath.Join(extractOutput, sprite.Name+".png")
+		if utils.FileExists(outputFile) && !extractForce {
+			return fmt.Errorf("output file already exists: %s (use --force to overwrite)", outputFile)
+		}
+
+		if err := utils.SaveImage(extractSprite(pages[sprite.Page], sprite), outputFile, utils.EncodeOptions{}); err != nil {
+			return fmt.Errorf("failed to save sprite %s: %w", sprite.Name, err)
+		}
+
+		log.Debug("Extracted %s -> %s", sprite.Name, outputFile)
+	}
+
+	fmt.Printf("Extracted %d sprites into %s\n", len(meta.Sprites), extractOutput)
+	return nil
+}
+
+// extractSprite copies a sprite's area out of its page, turning rotated
+// sprites back upright. Trimmed sprites are drawn at their source offset on
+// a transparent canvas of the untrimmed size.
+func extractSprite(page image.Image, sprite metadata.SpriteInfo) image.Image {
+	rect := image.Rect(sprite.X, sprite.Y, sprite.X+sprite.Width, sprite.Y+sprite.Height)
+
+	// Upright size of the sprite's area
+	width, height := rect.Dx(), rect.Dy()
+	if sprite.Rotated {
+		width, height = height, width
+	}
+
+	size, offset := image.Pt(width, height), image.Point{}
+	if sprite.Trimmed {
+		size, offset = image.Pt(sprite.SourceW, sprite.SourceH), image.Pt(sprite.SourceX, sprite.SourceY)
+	}
+
+	img := image.NewNRGBA(image.Rectangle{Max: size})
+	if !sprite.Rotated {
+		draw.Draw(img, rect.Sub(rect.Min).Add(offset), page, rect.Min, draw.Src)
+		return img
+	}
+
+	// The sheet holds the sprite turned clockwise: its upright pixel (x, y)
+	// sits at (height-1-y, x) within the area
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(offset.X+x, offset.Y+y, page.At(rect.Min.X+height-1-y, rect.Min.Y+x))
+		}
+	}
+	return img
+}
+
+// decodeSheet decodes a sheet image in any format svg2sheet writes
+func decodeSheet(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	return img, err
+}
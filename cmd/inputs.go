@@ -0,0 +1,22 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+// inputs holds the --input paths, from repeating the flag or separating
+// paths with commas. It is a plain string slice flag, so usage output shows
+// no default even after the flag was parsed.
+var inputs []string
+
+// applyInputs moves the --input paths into cfg: the first becomes Input and
+// any further ones ExtraInputs. Without the flag the inputs of --config are
+// kept.
+func applyInputs(cmd *cobra.Command) {
+	if !cmd.Flags().Changed("input") {
+		return
+	}
+
+	cfg.Input, cfg.ExtraInputs = "", nil
+	if len(inputs) > 0 {
+		cfg.Input, cfg.ExtraInputs = inputs[0], inputs[1:]
+	}
+}
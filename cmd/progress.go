@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// progressBarWidth is the number of cells in the terminal progress bar
+const progressBarWidth = 30
+
+// progress reports how far a batch of files has got. On a terminal it redraws
+// a single bar with the count, current file and ETA; otherwise it prints one
+// plain line per file so piped and CI logs stay readable. It stays silent
+// with --verbose, which already logs every file, and with --quiet or
+// --log-json.
+type progress struct {
+	out      io.Writer
+	total    int
+	current  int
+	start    time.Time
+	terminal bool
+	disabled bool
+}
+
+// newProgress creates a progress reporter on stdout, or a silent one when
+// disabled
+func newProgress(disabled bool) *progress {
+	return &progress{
+		out:      os.Stdout,
+		start:    time.Now(),
+		terminal: isTerminal(os.Stdout),
+		disabled: disabled,
+	}
+}
+
+// Step reports that path, file number done+1 of total, is being processed.
+// It serves as svg2sheet.Options.Progress.
+func (p *progress) Step(done, total int, path string) {
+	if p.disabled {
+		return
+	}
+	p.current, p.total = done+1, total
+
+	if !p.terminal {
+		fmt.Fprintf(p.out, "[%d/%d] %s\n", p.current, p.total, path)
+		return
+	}
+
+	p.draw(p.current-1, filepath.Base(path))
+}
+
+// Finish completes the bar once every file is done. It is a no-op without a
+// terminal or when nothing was reported.
+func (p *progress) Finish() {
+	if p.disabled || !p.terminal || p.current == 0 {
+		return
+	}
+
+	p.draw(p.current, "")
+	fmt.Fprintln(p.out)
+}
+
+// draw redraws the bar in place for done finished files
+func (p *progress) draw(done int, name string) {
+	filled := done * progressBarWidth / p.total
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+
+	eta := ""
+	if done > 0 && done < p.total {
+		remaining := time.Since(p.start) / time.Duration(done) * time.Duration(p.total-done)
+		eta = " ETA " + remaining.Round(time.Second).String()
+	}
+
+	// \033[K clears what is left of a longer previous line
+	fmt.Fprintf(p.out, "\r[%s] %d/%d%s %s\033[K", bar, p.current, p.total, eta, name)
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
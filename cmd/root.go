@@ -1,11 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 
 	"github.com/spf13/cobra"
 	"github.com/thanhfphan/svg2sheet/internal/config"
+	"github.com/thanhfphan/svg2sheet/internal/utils"
+	"github.com/thanhfphan/svg2sheet/pkg/svg2sheet"
 )
 
 var cfg config.Config
@@ -21,6 +26,9 @@ Examples:
   # Convert single SVG to PNG
   svg2sheet --input icon.svg --output icon.png --scale 2.0
 
+  # Convert single SVG to JPEG
+  svg2sheet --input icon.svg --output icon.jpg --quality 85
+
   # Convert folder of SVGs to PNGs
   svg2sheet --input ./svg-folder --output ./png-folder
 
@@ -35,72 +43,208 @@ Examples:
   svg2sheet --input icon.svg --output icon.png --converter rsvg --scale 2.0
   svg2sheet --input icon.svg --output icon.png --converter inkscape --scale 2.0
 
+  # Convert SVG from stdin to PNG on stdout
+  cat icon.svg | svg2sheet --input - --output - > icon.png
+
   # List available converters
-  svg2sheet converters`,
+  svg2sheet converters
+
+Any flag except --input and --output can also be set through an environment
+variable named SVG2SHEET_<FLAG>, e.g. SVG2SHEET_CONVERTER=rsvg or
+SVG2SHEET_TILE_WIDTH=32. Explicit flags override the environment.
+
+Options can also be kept in a YAML or JSON file passed with --config, using
+the metadata-style key names (tile_width, max_file_bytes, ...). Flags and
+environment variables override values from the file.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyConfigFile(cmd); err != nil {
+			return err
+		}
+		applyInputs(cmd)
+		return applyEnvDefaults(cmd)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runSvg2Sheet()
+		err := runSvg2Sheet(cmd.Context())
+
+		// The failed files were already listed; usage would bury them
+		var partial *svg2sheet.PartialFailureError
+		if errors.As(err, &partial) {
+			cmd.SilenceUsage = true
+		}
+		return err
 	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
+// An interrupt signal cancels the running operation and cleans up temporary files.
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return rootCmd.ExecuteContext(ctx)
 }
 
 func init() {
 	// Input/Output flags
-	rootCmd.Flags().StringVarP(&cfg.Input, "input", "i", "", "Input SVG file or directory (required)")
-	rootCmd.Flags().StringVarP(&cfg.Output, "output", "o", "", "Output PNG file or directory (required)")
-	rootCmd.MarkFlagRequired("input")
-	rootCmd.MarkFlagRequired("output")
+	rootCmd.Flags().StringSliceVarP(&inputs, "input", "i", nil, "Input SVG file or directory, or - for stdin; repeat or separate with commas to combine directories (required)")
+	rootCmd.Flags().StringVarP(&cfg.Output, "output", "o", "", "Output PNG file or directory, or - for stdout (required)")
+	// --input and --output may also come from --config, so they are checked
+	// by Config.Validate rather than marked required
+	rootCmd.Flags().StringVar(&configFile, "config", "", "Load options from a YAML or JSON file; command-line flags take precedence")
 
 	// SVG conversion flags
 	rootCmd.Flags().Float64Var(&cfg.Scale, "scale", 0, "Scale factor for SVG conversion (e.g., 2.0)")
 	rootCmd.Flags().IntVar(&cfg.Width, "width", 0, "Target width for SVG conversion")
 	rootCmd.Flags().IntVar(&cfg.Height, "height", 0, "Target height for SVG conversion")
+	rootCmd.Flags().BoolVar(&cfg.NormalizeSize, "normalize-size", false, "Size every SVG with svg2sheet's own parser and render it at exactly that size with every backend, so switching converters keeps output sizes")
+	rootCmd.Flags().Float64Var(&cfg.DPI, "dpi", 0, "Raster density in dots per inch; SVG sizes are defined at 96, so 192 doubles the output size (default: 96)")
+
+	// Output encoding flags
+	rootCmd.Flags().IntVar(&cfg.Quality, "quality", 0, "JPEG output quality from 1 to 100 (default: 90)")
+	rootCmd.Flags().StringVar(&cfg.PNGCompression, "png-compression", "", "PNG compression level: default, none, speed, or best (default: default)")
+	rootCmd.Flags().IntVar(&cfg.Palette, "palette", 0, "Write the sheet as an indexed PNG when it has at most this many colors (2-256)")
+	rootCmd.Flags().StringVar(&cfg.Background, "background", "", "Background color: #RRGGBB, #RRGGBBAA, or a name like white or transparent")
+	rootCmd.Flags().Int64Var(&cfg.MaxFileBytes, "max-file-bytes", 0, "Maximum size of each output image in bytes; lowers JPEG quality or quantizes PNG/WebP to fit")
+	rootCmd.Flags().IntVar(&cfg.MaxMemory, "max-memory", 0, "Refuse spritesheets whose estimated memory use exceeds this many MB (default: 500)")
+	rootCmd.Flags().IntVar(&cfg.MaxFiles, "max-files", 0, "Refuse input directories with more than this many files (default: 1000)")
+	rootCmd.Flags().IntVar(&cfg.MaxSprites, "max-sprites", 0, "Refuse spritesheets with more than this many sprites (default: 256)")
 
 	// Spritesheet layout flags
 	rootCmd.Flags().IntVar(&cfg.TileWidth, "tile-width", 0, "Width of each tile in spritesheet")
 	rootCmd.Flags().IntVar(&cfg.TileHeight, "tile-height", 0, "Height of each tile in spritesheet")
+	rootCmd.Flags().BoolVar(&cfg.AutoTile, "auto-tile", false, "Size tiles to the largest rendered sprite; --tile-width/--tile-height still set their axis")
 	rootCmd.Flags().IntVar(&cfg.Cols, "cols", 0, "Number of columns in spritesheet")
 	rootCmd.Flags().IntVar(&cfg.Rows, "rows", 0, "Number of rows in spritesheet")
+	rootCmd.Flags().StringVar(&cfg.RowSpec, "row-spec", "", "Columns per row for an irregular grid, e.g. \"3,8,8\"")
+	rootCmd.Flags().BoolVar(&cfg.Pack, "pack", false, "Pack sprites at their own size into a tight atlas instead of a grid")
+	rootCmd.Flags().BoolVar(&cfg.AllowRotation, "allow-rotation", false, "Let --pack turn sprites 90 degrees clockwise where that fits them tighter")
+	rootCmd.Flags().StringVar(&cfg.Align, "align", "", "Place sprites unstretched within tiles: center, top, bottom, left, right, or e.g. bottom-left")
+	rootCmd.Flags().StringVar(&cfg.Pivot, "pivot", "", "Sprite pivot recorded in metadata: a position like center or bottom, or x,y fractions such as 0.5,1")
+	rootCmd.Flags().BoolVar(&cfg.PreserveAspect, "preserve-aspect", false, "Scale sprites to fit their tile without distortion instead of stretching")
+	rootCmd.Flags().StringVar(&cfg.Flip, "flip", "", "Mirror every sprite: none, vertical, horizontal, or both")
+	rootCmd.Flags().StringVar(&cfg.FlipSheet, "flip-sheet", "", "Mirror the composed sheet, moving sprite coordinates with it: none, vertical, horizontal, or both")
 	rootCmd.Flags().IntVar(&cfg.Padding, "padding", 0, "Padding between tiles in pixels")
+	rootCmd.Flags().IntVar(&cfg.Margin, "margin", 0, "Empty border in pixels between the sprites and the sheet edges")
+	rootCmd.Flags().IntVar(&cfg.Extrude, "extrude", 0, "Repeat each sprite's edge pixels N pixels outward into the padding")
+	rootCmd.Flags().IntVar(&cfg.MaxSheetSize, "max-sheet-size", 0, "Split the spritesheet into pages (sheet_0.png, sheet_1.png, ...) no wider or taller than this")
+	rootCmd.Flags().BoolVar(&cfg.POT, "pot", false, "Round the sheet width and height up to powers of two, leaving the extra area empty")
+	rootCmd.Flags().BoolVar(&cfg.Square, "square", false, "Make the sheet a square power of two (implies --pot)")
+	rootCmd.Flags().IntVar(&cfg.SheetWidth, "sheet-width", 0, "Fixed sheet width in pixels; the sprites must fit (default: fit the sprites)")
+	rootCmd.Flags().IntVar(&cfg.SheetHeight, "sheet-height", 0, "Fixed sheet height in pixels; the sprites must fit (default: fit the sprites)")
+	rootCmd.Flags().BoolVar(&cfg.SheetCenter, "sheet-center", false, "Center the sprites on a fixed-size sheet instead of the top-left corner")
+	rootCmd.Flags().BoolVar(&cfg.Dedupe, "dedupe", false, "Place pixel-identical sprites in one shared region of the sheet")
+	rootCmd.Flags().IntVar(&cfg.AutoPad, "auto-pad", 0, "Increase padding minimally so tile X/Y coordinates are divisible by N")
+	rootCmd.Flags().StringVar(&cfg.Scales, "scales", "", "Generate one sheet per density from the same sources, e.g. \"1,2,3\" for sheet.png, sheet@2x.png and sheet@3x.png")
 
 	// Options flags
-	rootCmd.Flags().StringVar(&cfg.Sort, "sort", "", "Sort mode: name, ctime, or manual")
+	rootCmd.Flags().StringVar(&cfg.Sort, "sort", "", "Sort mode: name, natural, ctime, manual, filesize, size, or size-desc (spritesheet only)")
+	rootCmd.Flags().StringVar(&cfg.Manifest, "manifest", "", "JSON or CSV file listing sprites in order with optional per-file tile sizes (implies --sort manual)")
+	rootCmd.Flags().StringVar(&cfg.InputList, "input-list", "", "File listing input paths or globs, one per line, in sheet order; read instead of scanning --input")
+	rootCmd.Flags().StringSliceVar(&cfg.Include, "include", nil, "Only use files of the input directory matching these globs, e.g. \"icons/*.svg\"; repeatable")
+	rootCmd.Flags().BoolVar(&cfg.NoRecursive, "no-recursive", false, "Only use the files directly in the input directory, not those in its subdirectories")
+	rootCmd.Flags().StringSliceVar(&cfg.Exclude, "exclude", nil, "Leave out files of the input directory matching these globs, e.g. \"*_draft.svg\"; wins over --include; repeatable")
 	rootCmd.Flags().StringVar(&cfg.Meta, "meta", "", "Output metadata JSON file")
+	rootCmd.Flags().StringVar(&cfg.ImagePath, "image-path", "", "Sheet path written into the metadata (default: the sheet relative to the metadata file)")
+	rootCmd.Flags().StringVar(&cfg.MetaCSV, "meta-csv", "", "Also write the sprites as a CSV table to this file, alongside --meta")
+	rootCmd.Flags().StringVar(&cfg.Aseprite, "aseprite", "", "Aseprite JSON export whose frame tags are written to the metadata as animations")
+	rootCmd.Flags().BoolVar(&cfg.GroupByPrefix, "group-by-prefix", false, "Record sprites named like walk_000, walk_001 as animations in the metadata")
+	rootCmd.Flags().StringVar(&cfg.ResizeFilter, "resize-filter", "", "Filter used to scale sprites to the tile size: nearest, bilinear, or catmullrom (default: catmullrom)")
+	rootCmd.Flags().StringVar(&cfg.MetaFormat, "meta-format", "", "Metadata format: native, texturepacker-hash, texturepacker-array, css, godot, libgdx, or starling (default: native)")
+	rootCmd.Flags().StringVar(&cfg.CSSPrefix, "css-prefix", "", "Class name prefix for --meta-format css (default: sprite-)")
+	rootCmd.Flags().StringVar(&cfg.GodotAtlasPath, "godot-atlas-path", "", "Sheet path referenced by --meta-format godot resources (default: res://<output file name>)")
+	rootCmd.Flags().BoolVar(&cfg.NoSourcePaths, "no-source-paths", false, "Leave the input file of each sprite out of the metadata")
+	rootCmd.Flags().BoolVar(&cfg.MeasureContent, "measure-content", false, "Record the bounds of each sprite's visible pixels on the sheet in the metadata, for culling or collision")
+	rootCmd.Flags().StringVar(&cfg.NamePrefix, "name-prefix", "", "Text prepended to every sprite name in the metadata")
+	rootCmd.Flags().StringVar(&cfg.NameSuffix, "name-suffix", "", "Text appended to every sprite name in the metadata")
+	rootCmd.Flags().StringVar(&cfg.NameTemplate, "name-template", "", "Go template for sprite names using {{.Name}}, {{.Index}} and {{.Dir}}, e.g. \"{{.Dir}}/{{.Name}}\"")
+	rootCmd.Flags().StringVar(&cfg.NameCase, "name-case", "", "Case of sprite names taken from file names: none, lower, snake, kebab, or camel (default: none)")
+	rootCmd.Flags().BoolVar(&cfg.NameSanitize, "name-sanitize", false, "Replace characters other than ASCII letters, digits, _ and - in sprite names with _")
 	rootCmd.Flags().BoolVar(&cfg.Trim, "trim", false, "Trim transparent edges from images")
+	rootCmd.Flags().IntVar(&cfg.TrimMargin, "trim-margin", 0, "Transparent margin in pixels to keep around trimmed content")
+	rootCmd.Flags().BoolVar(&cfg.TrimKeepTile, "trim-keep-tile", false, "Place trimmed sprites unstretched in their tile and record the trimmed area of the source")
+	rootCmd.Flags().IntVar(&cfg.TrimThreshold, "trim-threshold", 0, "Highest alpha (0-255) that trimming treats as transparent")
+	rootCmd.Flags().StringVar(&cfg.ColorKey, "color-key", "", "Make pixels of this color transparent in the inputs, e.g. #FF00FF for a magenta background")
+	rootCmd.Flags().IntVar(&cfg.KeyTolerance, "color-key-tolerance", 0, "Highest difference (0-255) per color channel that still matches --color-key")
+	rootCmd.Flags().Float64Var(&cfg.Opacity, "opacity", 0, "Multiply the alpha of every sprite by this factor up to 1, e.g. 0.5 for disabled icons (default: 1)")
+	rootCmd.Flags().StringVar(&cfg.Tint, "tint", "", "Multiply every sprite's colors by this color, e.g. #808080 to darken or #FF0000 for red")
 	rootCmd.Flags().BoolVar(&cfg.Force, "force", false, "Overwrite existing output files")
+	rootCmd.Flags().BoolVar(&cfg.DryRun, "dry-run", false, "Print the planned sheet layout or conversions without writing any files")
+	rootCmd.Flags().BoolVar(&cfg.DebugGrid, "debug-grid", false, "Outline every sprite region and write its index on the sheet, for checking layouts")
 	rootCmd.Flags().BoolVarP(&cfg.Verbose, "verbose", "v", false, "Enable verbose logging")
-	rootCmd.Flags().StringVar(&cfg.Converter, "converter", "", "SVG converter backend: oksvg, rod, rsvg, or inkscape (default: oksvg)")
+	rootCmd.Flags().BoolVarP(&cfg.Quiet, "quiet", "q", false, "Log errors only, without progress or warnings")
+	rootCmd.Flags().BoolVar(&cfg.LogJSON, "log-json", false, "Log one JSON object per message to stderr, for CI and other tools")
+	rootCmd.Flags().StringVar(&cfg.Timeout, "timeout", "", "Time limit for rendering each SVG with rod, rsvg or inkscape, e.g. 30s or 2m; 0 disables it (default: 30s)")
+	rootCmd.Flags().IntVar(&cfg.Jobs, "jobs", 0, "Number of SVGs the rod converter renders at once, each on its own page of one browser (default: 1)")
+	rootCmd.Flags().IntVar(&cfg.Frames, "frames", 0, "Capture this many frames of each animated SVG as a strip of sprites (requires --converter rod)")
+	rootCmd.Flags().StringVar(&cfg.FrameInterval, "frame-interval", "", "Animation time between captured frames, e.g. 100ms (default: 100ms)")
+	rootCmd.Flags().BoolVar(&cfg.SkipErrors, "skip-errors", false, "Report and leave out files that fail to render or time out instead of stopping")
+	rootCmd.Flags().BoolVar(&cfg.PreserveTree, "preserve-tree", false, "Mirror the input's subdirectories in the output directory when converting a directory")
+	rootCmd.Flags().StringVar(&cfg.OnCollision, "on-collision", "", "What to do with files that get the same output file or sprite name: warn, error, skip, or rename (default: warn)")
+	rootCmd.Flags().StringVar(&cfg.StdoutEncoding, "stdout-encoding", "", "How --output - writes the image: raw PNG bytes, or base64 for a JSON object with the image and sheet metadata (default: raw)")
+	rootCmd.Flags().StringVar(&cfg.Converter, "converter", "", "SVG converter backend: oksvg, rod, rsvg, inkscape, or auto for the best one installed (default: oksvg)")
+	rootCmd.Flags().BoolVar(&cfg.Sanitize, "sanitize", false, "Strip scripts, event handlers and external links from SVGs before rendering, for untrusted icon packs")
+	rootCmd.Flags().StringVar(&cfg.ViewBox, "viewbox", "", "viewBox \"x y w h\" given to SVGs that have none, so SVGs without dimensions render at a known size")
+	rootCmd.Flags().StringVar(&cfg.CacheDir, "cache-dir", "", "Directory for cached SVG renders (default: svg2sheet in the user cache directory)")
+	rootCmd.Flags().BoolVar(&cfg.NoCache, "no-cache", false, "Render every SVG without reading or writing the render cache")
+	rootCmd.Flags().BoolVar(&cfg.SkipUnchanged, "skip-unchanged", false, "Leave converted PNGs alone when their SVG and settings are unchanged since they were written")
+	rootCmd.Flags().StringVar(&cfg.KeepIntermediate, "keep-intermediate", "", "Keep the PNG rendered from each file for a spritesheet in this directory instead of deleting it")
 }
 
-func runSvg2Sheet() error {
-	// Set defaults and validate configuration
+func runSvg2Sheet(ctx context.Context) error {
 	cfg.SetDefaults()
-	if err := cfg.Validate(); err != nil {
-		return fmt.Errorf("configuration error: %w", err)
-	}
 
-	if cfg.Verbose {
-		fmt.Printf("Configuration: %+v\n", cfg)
+	// Image data owns stdout, so verbose logging goes to stderr instead
+	stdout := os.Stdout
+	if cfg.IsStdoutOutput() {
+		os.Stdout = os.Stderr
+		defer func() { os.Stdout = stdout }()
 	}
 
-	if _, err := os.Stat(cfg.Input); os.IsNotExist(err) {
-		return fmt.Errorf("input path does not exist: %s", cfg.Input)
+	bar := newProgress(cfg.Verbose || cfg.Quiet || cfg.LogJSON)
+	opts := svg2sheet.Options{
+		Config:   cfg,
+		Stdout:   stdout,
+		Progress: bar.Step,
 	}
 
-	if _, err := os.Stat(cfg.Output); err == nil && !cfg.Force {
-		return fmt.Errorf("output file already exists: %s (use --force to overwrite)", cfg.Output)
+	if svg2sheet.IsSheetInput(opts) {
+		meta, err := svg2sheet.GenerateSheet(ctx, opts)
+		bar.Finish()
+		if err != nil {
+			reportFailures(err)
+			return err
+		}
+		if cfg.DryRun {
+			return printSheetPlan(meta)
+		}
+		return nil
 	}
 
-	return executeOperation()
+	result, err := svg2sheet.Convert(ctx, opts)
+	bar.Finish()
+	if err != nil {
+		reportFailures(err)
+		return err
+	}
+	if cfg.DryRun {
+		if isDir, _ := utils.IsDirectory(cfg.Input); isDir {
+			return printConversionPlan(result.Files)
+		}
+		printFilePlan(result.Files[0])
+	}
+	return nil
 }
 
-func executeOperation() error {
-	processor, err := NewProcessor(&cfg)
-	if err != nil {
-		return fmt.Errorf("failed to create processor: %w", err)
+// reportFailures prints the files that --skip-errors left out to stderr when
+// err is a partial failure
+func reportFailures(err error) {
+	var partial *svg2sheet.PartialFailureError
+	if !errors.As(err, &partial) {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "%d of %d files failed and were skipped:\n", len(partial.Failures), partial.Total)
+	for _, failure := range partial.Failures {
+		fmt.Fprintf(os.Stderr, "  %s: %v\n", failure.Path, failure.Err)
 	}
-	return processor.Process()
 }
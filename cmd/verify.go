@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/thanhfphan/svg2sheet/internal/metadata"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	verifySheet string
+	verifyMeta  string
+)
+
+// verifyCmd checks a spritesheet image against its metadata
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check a spritesheet against its metadata",
+	Long: `Check that a spritesheet image still matches the native JSON metadata
+written with --meta: the metadata must be valid, the sheet (or every page of
+a split sheet) must have the recorded size, and every sprite must lie within
+its image. Each mismatch is reported and the command exits non-zero if any
+are found.
+
+Examples:
+  # Verify a sheet after editing its sprites by hand
+  svg2sheet verify --sheet sheet.png --meta sheet.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		problems, err := runVerify()
+		if err != nil {
+			return err
+		}
+		if problems > 0 {
+			// The mismatches were already listed; usage would bury them
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d mismatch(es) between %s and %s", problems, verifySheet, verifyMeta)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().StringVar(&verifySheet, "sheet", "", "Spritesheet image, or the --output path of a sheet split into pages (required)")
+	verifyCmd.Flags().StringVar(&verifyMeta, "meta", "", "Native JSON metadata written for the sheet (required)")
+	verifyCmd.MarkFlagRequired("sheet")
+	verifyCmd.MarkFlagRequired("meta")
+}
+
+// runVerify prints every mismatch between the sheet and its metadata and
+// returns how many were found
+func runVerify() (int, error) {
+	exporter := metadata.NewExporter(&cfg, cfg.NewLogger())
+
+	meta, err := exporter.LoadMetadata(verifyMeta)
+	if err != nil {
+		return 0, err
+	}
+
+	problems := 0
+	report := func(format string, args ...interface{}) {
+		fmt.Printf("✗ "+format+"\n", args...)
+		problems++
+	}
+
+	if err := exporter.ValidateMetadata(meta); err != nil {
+		report("invalid metadata: %v", err)
+	}
+
+	pagePaths := sheetPagePaths(verifySheet, meta)
+	pageSizes := []image.Point{image.Pt(meta.Width, meta.Height)}
+	if len(meta.Pages) > 0 {
+		pageSizes = make([]image.Point, len(meta.Pages))
+		for i, page := range meta.Pages {
+			pageSizes[i] = image.Pt(page.Width, page.Height)
+		}
+	}
+
+	// Actual image sizes, nil for pages that could not be read
+	actual := make([]*image.Point, len(pagePaths))
+	for i, path := range pagePaths {
+		size, err := imageSize(path)
+		if err != nil {
+			report("%s: %v", path, err)
+			continue
+		}
+		actual[i] = &size
+
+		if size != pageSizes[i] {
+			report("%s is %dx%d but the metadata records %dx%d", path, size.X, size.Y, pageSizes[i].X, pageSizes[i].Y)
+		}
+	}
+
+	for _, sprite := range meta.Sprites {
+		if sprite.Page < 0 || sprite.Page >= len(actual) || actual[sprite.Page] == nil {
+			continue
+		}
+
+		bounds := image.Rectangle{Max: *actual[sprite.Page]}
+		rect := image.Rect(sprite.X, sprite.Y, sprite.X+sprite.Width, sprite.Y+sprite.Height)
+		if !rect.In(bounds) {
+			report("sprite %s at (%d, %d) %dx%d lies outside %s (%dx%d)",
+				sprite.Name, sprite.X, sprite.Y, sprite.Width, sprite.Height, pagePaths[sprite.Page], bounds.Dx(), bounds.Dy())
+		}
+	}
+
+	if problems == 0 {
+		fmt.Printf("✓ %s matches %s: %d sprites on %d image(s)\n", verifySheet, verifyMeta, len(meta.Sprites), len(pagePaths))
+	}
+
+	return problems, nil
+}
+
+// sheetPagePaths returns the image file of every page of a sheet. Split
+// sheets list their page images in the metadata, named relative to the sheet.
+func sheetPagePaths(sheet string, meta *metadata.SpritesheetMetadata) []string {
+	if len(meta.Pages) == 0 {
+		return []string{sheet}
+	}
+
+	paths := make([]string, len(meta.Pages))
+	for i, page := range meta.Pages {
+		paths[i] = filepath.Join(filepath.Dir(sheet), page.Image)
+	}
+	return paths
+}
+
+// imageSize returns the pixel size of an image file without decoding its pixels
+func imageSize(path string) (image.Point, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Point{}, err
+	}
+	defer f.Close()
+
+	imgConfig, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return image.Point{}, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	return image.Pt(imgConfig.Width, imgConfig.Height), nil
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+	"github.com/thanhfphan/svg2sheet/internal/metadata"
+)
+
+// Build information, set by SetVersion from the values main receives
+// through -ldflags
+var (
+	buildVersion = "dev"
+	buildCommit  = "unknown"
+	buildTime    = "unknown"
+)
+
+var versionJSON bool
+
+// versionCmd prints the build information
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version, commit and build date",
+	Long: `Print the svg2sheet version, the git commit and the date it was built
+from, and the Go version and platform. The version is also recorded in the
+metadata of every sheet, so quote this output in bug reports.
+
+Examples:
+  svg2sheet version
+
+  # Print the build information as JSON for scripts
+  svg2sheet version --json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if versionJSON {
+			return printVersionJSON()
+		}
+		fmt.Printf("svg2sheet %s\n", buildVersion)
+		fmt.Printf("Commit:   %s\n", buildCommit)
+		fmt.Printf("Built:    %s\n", buildTime)
+		fmt.Printf("Go:       %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "Print the build information as a JSON object")
+}
+
+// SetVersion records the build's version for --version, the version command
+// and generated metadata. Values left at their defaults by a build without
+// -ldflags, such as go install, are taken from the module and VCS
+// information embedded by the Go toolchain where available.
+func SetVersion(version, commit, built string) {
+	buildVersion, buildCommit, buildTime = version, commit, built
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if buildVersion == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			buildVersion = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && buildCommit == "unknown":
+				buildCommit = setting.Value
+			case setting.Key == "vcs.time" && buildTime == "unknown":
+				buildTime = setting.Value
+			}
+		}
+	}
+
+	rootCmd.Version = fmt.Sprintf("%s (commit %s, built %s)", buildVersion, buildCommit, buildTime)
+	metadata.Version = buildVersion
+}
+
+// versionInfo is the JSON form of the version command's output
+type versionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// printVersionJSON prints the build information as an indented JSON object
+func printVersionJSON() error {
+	data, err := json.MarshalIndent(versionInfo{
+		Version:   buildVersion,
+		Commit:    buildCommit,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal version: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
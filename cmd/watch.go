@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+	"github.com/thanhfphan/svg2sheet/internal/utils"
+	"github.com/thanhfphan/svg2sheet/pkg/svg2sheet"
+)
+
+// watchDebounce is how long the input must stay unchanged before a rebuild
+var watchDebounce time.Duration
+
+// watchCmd rebuilds the sheet or PNGs whenever the input changes
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Rebuild the spritesheet or PNGs whenever the input changes",
+	Long: `Build once, then watch the input directory (or single SVG) and build again
+whenever files are added, changed, removed or renamed. Bursts of changes, such
+as an editor saving several files, are collected into a single rebuild.
+
+watch takes the same flags as a normal run and always overwrites its output.
+Together with the render cache, only SVGs that changed are rendered again.
+Press Ctrl+C to stop.
+
+Examples:
+  # Rebuild a sheet while editing its icons
+  svg2sheet watch --input ./icons --output sheet.png --cols 8 --meta sheet.json`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyConfigFile(cmd); err != nil {
+			return err
+		}
+		applyInputs(cmd)
+		return applyEnvDefaults(cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWatch(cmd.Context())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	// The conversion and layout flags are shared with the root command,
+	// whose init has already registered them
+	watchCmd.Flags().AddFlagSet(rootCmd.Flags())
+	watchCmd.Flags().DurationVar(&watchDebounce, "debounce", 300*time.Millisecond, "Wait this long after the last change before rebuilding")
+}
+
+func runWatch(ctx context.Context) error {
+	cfg.SetDefaults()
+	cfg.Force = true
+
+	if cfg.IsStdinInput() || cfg.IsStdoutOutput() {
+		return fmt.Errorf("watch needs an input and output path, not standard input or output")
+	}
+	if cfg.DryRun {
+		return fmt.Errorf("watch cannot be combined with --dry-run")
+	}
+
+	isDir, err := utils.IsDirectory(cfg.Input)
+	if err != nil {
+		return fmt.Errorf("failed to stat input: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start watching: %w", err)
+	}
+	defer watcher.Close()
+
+	// A single file is watched through its directory, so that editors that
+	// replace the file on save do not end the watch
+	roots := cfg.InputDirs()
+	if !isDir {
+		roots = []string{filepath.Dir(cfg.Input)}
+	}
+	recursive := isDir && !cfg.NoRecursive
+	for _, root := range roots {
+		if err := watchTree(watcher, root, recursive); err != nil {
+			return err
+		}
+	}
+
+	log := cfg.NewLogger()
+	rebuild(ctx, log)
+	log.Info("Watching %s for changes (Ctrl+C to stop)", strings.Join(cfg.InputDirs(), ", "))
+
+	// Fires once the input has been quiet for the debounce period
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Stopped watching")
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !watchRelevant(event, isDir) {
+				continue
+			}
+			log.Debug("Change: %s %s", event.Op, event.Name)
+			// New directories are watched too, so files added to them count
+			if recursive && event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watchTree(watcher, event.Name, true); err != nil {
+						log.Warn("%v", err)
+					}
+				}
+			}
+			timer.Reset(watchDebounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Warn("watch error: %v", err)
+
+		case <-timer.C:
+			rebuild(ctx, log)
+		}
+	}
+}
+
+// watchTree adds dir, and with recursive every directory below it, to the
+// watcher
+func watchTree(watcher *fsnotify.Watcher, dir string, recursive bool) error {
+	if !recursive {
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+		return nil
+	}
+
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// watchRelevant reports whether an event can change the output: a change to
+// an input file, or a directory of the input appearing or going away. Events
+// for the files a build writes are ignored so an output inside the input
+// directory does not trigger endless rebuilds.
+func watchRelevant(event fsnotify.Event, isDir bool) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	if isBuildOutput(event.Name) {
+		return false
+	}
+
+	if !isDir {
+		return sameFile(event.Name, cfg.Input)
+	}
+	if utils.IsInputFile(event.Name) {
+		return true
+	}
+
+	// Removed or renamed entries no longer exist to be checked, and may have
+	// been directories full of inputs
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		return true
+	}
+	info, err := os.Stat(event.Name)
+	return err == nil && info.IsDir()
+}
+
+// isBuildOutput reports whether path is written by a build: the output or
+// one of its pages, or the metadata
+func isBuildOutput(path string) bool {
+	return utils.IsOutputPath(path, cfg.Output) || (cfg.Meta != "" && sameFile(path, cfg.Meta)) ||
+		(cfg.MetaCSV != "" && sameFile(path, cfg.MetaCSV))
+}
+
+// sameFile reports whether two paths name the same location
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// rebuild runs one build and logs a timestamped line with the outcome.
+// Failures are reported and the watch goes on.
+func rebuild(ctx context.Context, log svg2sheet.Logger) {
+	start := time.Now()
+	opts := svg2sheet.Options{Config: cfg, Logger: log}
+
+	var summary string
+	var err error
+	if svg2sheet.IsSheetInput(opts) {
+		var meta *svg2sheet.Metadata
+		meta, err = svg2sheet.GenerateSheet(ctx, opts)
+		if meta != nil {
+			summary = fmt.Sprintf("%s: %dx%d, %d sprites", cfg.Output, meta.Width, meta.Height, len(meta.Sprites))
+			if len(meta.Pages) > 1 {
+				summary += fmt.Sprintf(" on %d pages", len(meta.Pages))
+			}
+		}
+	} else {
+		var result *svg2sheet.Result
+		result, err = svg2sheet.Convert(ctx, opts)
+		if result != nil {
+			summary = fmt.Sprintf("%s: %d files", cfg.Output, len(result.Files))
+		}
+	}
+
+	// An interrupt during a build is reported by the watch loop
+	if ctx.Err() != nil {
+		return
+	}
+
+	stamp := time.Now().Format("15:04:05")
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	var partial *svg2sheet.PartialFailureError
+	if err != nil && !errors.As(err, &partial) {
+		log.Error("[%s] Build failed: %v", stamp, err)
+		return
+	}
+	reportFailures(err)
+	log.Info("[%s] Built %s in %s", stamp, summary, elapsed)
+}
@@ -0,0 +1,131 @@
+// Package cache keeps rendered PNGs on disk, keyed by the content of the
+// source SVG and the settings that affect rendering, so unchanged files are
+// not rendered again on the next run.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+	"github.com/thanhfphan/svg2sheet/internal/metadata"
+	"github.com/thanhfphan/svg2sheet/internal/utils"
+)
+
+// formatVersion is part of every key; bump it when the stored files change
+const formatVersion = 1
+
+// Cache stores rendered PNGs in a directory
+type Cache struct {
+	dir     string
+	options string // rendering settings every key includes
+}
+
+// DefaultDir returns the cache directory used when --cache-dir is not set
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "svg2sheet"), nil
+}
+
+// New returns a cache in dir, creating the directory if needed, for PNGs
+// rendered by backend with the settings of cfg
+func New(dir string, cfg *config.Config, backend config.ConverterType) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	// Everything that changes the rendered pixels or their encoding; the
+	// version covers changes to the renderers themselves
+	options := fmt.Sprintf("v%d|%s|%s|scale=%g|width=%d|height=%d|dpi=%g|background=%s|quality=%d|max_bytes=%d",
+		formatVersion, metadata.Version, backend, cfg.Scale, cfg.Width, cfg.Height, cfg.DPI,
+		cfg.Background, cfg.Quality, cfg.MaxFileBytes)
+	// Added only when set, so caches of renders without them stay valid
+	if cfg.PNGCompression != "" && cfg.PNGCompression != string(config.PNGDefault) {
+		options += "|png_compression=" + cfg.PNGCompression
+	}
+	if cfg.Sanitize {
+		options += "|sanitize"
+	}
+	if cfg.NormalizeSize {
+		options += "|normalize_size"
+	}
+	// Validated by Config.Validate
+	if viewBox, _ := cfg.ViewBoxAttr(); viewBox != "" {
+		options += "|viewbox=" + viewBox
+	}
+
+	return &Cache{dir: dir, options: options}, nil
+}
+
+// Dir returns the directory holding the cached files
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Key returns the key of the SVG file at svgPath: the SHA-256 of its content
+// and the cache's rendering settings
+func (c *Cache) Key(svgPath string) (string, error) {
+	file, err := os.Open(svgPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	io.WriteString(h, c.options)
+	h.Write([]byte{0})
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Lookup returns the cached PNG stored under key and whether there is one
+func (c *Cache) Lookup(key string) (string, bool) {
+	path := c.path(key)
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	return path, true
+}
+
+// Put stores a copy of the PNG at src under key. The file is written under
+// a temporary name and renamed, so concurrent runs never see half a file.
+func (c *Cache) Put(key, src string) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	tmp.Close()
+
+	if err := utils.CopyFile(src, tmp.Name()); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to store cache file: %w", err)
+	}
+
+	return nil
+}
+
+// path returns where the PNG for key is stored, spread over subdirectories
+// named after the key's first two characters
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".png")
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Unchanged reports whether output was last written from the SVG with key,
+// as noted by Record, and has not been modified or removed since
+func (c *Cache) Unchanged(output, key string) bool {
+	recorded, err := os.ReadFile(c.recordPath(output))
+	if err != nil {
+		return false
+	}
+
+	stamp, err := outputStamp(output)
+	if err != nil {
+		return false
+	}
+	return string(recorded) == key+" "+stamp
+}
+
+// Record notes that output was just written from the SVG with key
+func (c *Cache) Record(output, key string) error {
+	stamp, err := outputStamp(output)
+	if err != nil {
+		return err
+	}
+
+	path := c.recordPath(output)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(key+" "+stamp), 0644); err != nil {
+		return fmt.Errorf("failed to record %s: %w", output, err)
+	}
+
+	return nil
+}
+
+// recordPath returns where the record of output is kept, named after the
+// SHA-256 of its absolute path
+func (c *Cache) recordPath(output string) string {
+	if abs, err := filepath.Abs(output); err == nil {
+		output = abs
+	}
+	sum := sha256.Sum256([]byte(output))
+	return filepath.Join(c.dir, "outputs", hex.EncodeToString(sum[:]))
+}
+
+// outputStamp identifies the current content of output by its size and
+// modification time, so an output replaced by something else is written
+// again
+func outputStamp(output string) (string, error) {
+	info, err := os.Stat(output)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", output)
+	}
+	return fmt.Sprintf("%d %d", info.Size(), info.ModTime().UnixNano()), nil
+}
@@ -2,36 +2,153 @@ package config
 
 import (
 	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/thanhfphan/svg2sheet/internal/logging"
 )
 
 // Config holds all configuration options for the svg2sheet tool
 type Config struct {
 	// Input/Output
-	Input  string `json:"input"`
-	Output string `json:"output"`
+	Input            string   `json:"input"`
+	Output           string   `json:"output"`
+	ExtraInputs      []string `json:"extra_inputs,omitempty"`      // further input directories whose files join those of Input
+	InputList        string   `json:"input_list,omitempty"`        // file listing input paths or globs in sheet order, read instead of scanning --input
+	Include          []string `json:"include,omitempty"`           // globs a scanned file must match, on its name or path relative to the input
+	Exclude          []string `json:"exclude,omitempty"`           // globs leaving scanned files out, winning over Include
+	NoRecursive      bool     `json:"no_recursive,omitempty"`      // scan only the top level of input directories
+	KeepIntermediate string   `json:"keep_intermediate,omitempty"` // directory receiving the per-file PNGs rendered for a spritesheet
+	Scales           string   `json:"scales,omitempty"`            // comma-separated densities, each rendered to its own sheet named like sheet@2x.png
 
 	// SVG Conversion
-	Scale  float64 `json:"scale,omitempty"`
-	Width  int     `json:"width,omitempty"`
-	Height int     `json:"height,omitempty"`
+	Scale         float64 `json:"scale,omitempty"`
+	Width         int     `json:"width,omitempty"`
+	Height        int     `json:"height,omitempty"`
+	DPI           float64 `json:"dpi,omitempty"`            // raster density; SVG sizes are defined at 96 DPI
+	NormalizeSize bool    `json:"normalize_size,omitempty"` // size every render with the shared SVG parser and pass it to each backend, so they all agree
+
+	// Input Keying
+	ColorKey     string `json:"color_key,omitempty"`           // color made transparent in inputs: #RRGGBB or a color name
+	KeyTolerance int    `json:"color_key_tolerance,omitempty"` // highest per-channel difference still matching the color key
+
+	// Sprite Adjustment
+	Opacity float64 `json:"opacity,omitempty"` // multiplies the alpha of every sprite, up to 1; 0 leaves it
+	Tint    string  `json:"tint,omitempty"`    // color multiplied into every sprite: #RRGGBB or a color name
+
+	// Output Encoding
+	Quality        int    `json:"quality,omitempty"`         // lossy encoder quality, 1-100
+	MaxFileBytes   int64  `json:"max_file_bytes,omitempty"`  // byte budget per output image
+	Background     string `json:"background,omitempty"`      // fill color: #RRGGBB, #RRGGBBAA or a color name
+	PNGCompression string `json:"png_compression,omitempty"` // PNG compression: default, none, speed, best
+	Palette        int    `json:"palette,omitempty"`         // write sheets as indexed PNGs of at most this many colors
 
 	// Spritesheet Layout
-	TileWidth  int `json:"tile_width,omitempty"`
-	TileHeight int `json:"tile_height,omitempty"`
-	Cols       int `json:"cols,omitempty"`
-	Rows       int `json:"rows,omitempty"`
-	Padding    int `json:"padding,omitempty"`
+	TileWidth      int    `json:"tile_width,omitempty"`
+	TileHeight     int    `json:"tile_height,omitempty"`
+	AutoTile       bool   `json:"auto_tile,omitempty"` // size tile axes left unset to the largest rendered sprite
+	Cols           int    `json:"cols,omitempty"`
+	Rows           int    `json:"rows,omitempty"`
+	Padding        int    `json:"padding,omitempty"`
+	Margin         int    `json:"margin,omitempty"`          // empty border between the sprites and the sheet edges
+	Extrude        int    `json:"extrude,omitempty"`         // repeat sprite edge pixels outward into the padding
+	AutoPad        int    `json:"auto_pad,omitempty"`        // expand padding so tile coordinates are divisible by N
+	RowSpec        string `json:"row_spec,omitempty"`        // comma-separated column count per row, e.g. "3,8,8"
+	Pack           bool   `json:"pack,omitempty"`            // bin-pack sprites at their own size instead of a grid
+	AllowRotation  bool   `json:"allow_rotation,omitempty"`  // let packing turn sprites 90 degrees clockwise
+	Align          string `json:"align,omitempty"`           // place sprites unstretched within tiles, e.g. center or bottom-left
+	Pivot          string `json:"pivot,omitempty"`           // sprite pivot recorded in metadata, e.g. bottom or 0.5,0.9
+	PreserveAspect bool   `json:"preserve_aspect,omitempty"` // scale sprites to fit tiles without distortion
+	Flip           string `json:"flip,omitempty"`            // mirror every sprite: none, vertical, horizontal, both
+	FlipSheet      string `json:"flip_sheet,omitempty"`      // mirror the composed sheet: none, vertical, horizontal, both
+	MaxSheetSize   int    `json:"max_sheet_size,omitempty"`  // split the sheet into pages no wider or taller than this
+	Dedupe         bool   `json:"dedupe,omitempty"`          // place pixel-identical sprites in one shared region
+	POT            bool   `json:"pot,omitempty"`             // round sheet sizes up to powers of two
+	Square         bool   `json:"square,omitempty"`          // make sheets square powers of two
+	SheetWidth     int    `json:"sheet_width,omitempty"`     // fixed sheet width, 0 to fit the sprites
+	SheetHeight    int    `json:"sheet_height,omitempty"`    // fixed sheet height, 0 to fit the sprites
+	SheetCenter    bool   `json:"sheet_center,omitempty"`    // center the sprites on a fixed sheet instead of the top-left corner
 
 	// Options
-	Sort      string `json:"sort,omitempty"`      // name, ctime, manual
-	Meta      string `json:"meta,omitempty"`      // metadata output file
-	Trim      bool   `json:"trim,omitempty"`      // trim transparent edges
-	Force     bool   `json:"force,omitempty"`     // overwrite existing files
-	Verbose   bool   `json:"verbose,omitempty"`   // verbose logging
-	Converter string `json:"converter,omitempty"` // SVG converter backend
+	Sort           string `json:"sort,omitempty"`             // name, natural, ctime, manual, filesize
+	Manifest       string `json:"manifest,omitempty"`         // JSON or CSV file giving the manual order and per-file tile sizes
+	Meta           string `json:"meta,omitempty"`             // metadata output file
+	MetaCSV        string `json:"meta_csv,omitempty"`         // CSV table of the sprites, written alongside --meta
+	ImagePath      string `json:"image_path,omitempty"`       // sheet path written into the metadata instead of the path relative to it
+	Aseprite       string `json:"aseprite,omitempty"`         // Aseprite JSON export whose frame tags become animations
+	GroupByPrefix  bool   `json:"group_by_prefix,omitempty"`  // record sprites named like walk_000, walk_001 as animations
+	MetaFormat     string `json:"meta_format,omitempty"`      // native, texturepacker-hash, texturepacker-array, css, godot, libgdx, starling
+	CSSPrefix      string `json:"css_prefix,omitempty"`       // class name prefix for css metadata
+	GodotAtlasPath string `json:"godot_atlas_path,omitempty"` // sheet path in the Godot project, e.g. res://sprites/sheet.png
+	NoSourcePaths  bool   `json:"no_source_paths,omitempty"`  // leave the input file of each sprite out of the metadata
+	MeasureContent bool   `json:"measure_content,omitempty"`  // record the bounds of each sprite's visible pixels on the sheet
+	NamePrefix     string `json:"name_prefix,omitempty"`      // prepended to every sprite name
+	NameSuffix     string `json:"name_suffix,omitempty"`      // appended to every sprite name
+	NameTemplate   string `json:"name_template,omitempty"`    // text/template building sprite names, e.g. {{.Dir}}/{{.Name}}
+	NameCase       string `json:"name_case,omitempty"`        // none, lower, snake, kebab, camel
+	NameSanitize   bool   `json:"name_sanitize,omitempty"`    // replace characters other than ASCII letters, digits, _ and - in sprite names
+	Trim           bool   `json:"trim,omitempty"`             // trim transparent edges
+	TrimMargin     int    `json:"trim_margin,omitempty"`      // transparent margin kept around trimmed content
+	TrimThreshold  int    `json:"trim_threshold,omitempty"`   // highest alpha trimming treats as transparent
+	TrimKeepTile   bool   `json:"trim_keep_tile,omitempty"`   // place trimmed sprites unstretched in their tile and record the trim area
+	ResizeFilter   string `json:"resize_filter,omitempty"`    // nearest, bilinear, catmullrom
+	Force          bool   `json:"force,omitempty"`            // overwrite existing files
+	DryRun         bool   `json:"dry_run,omitempty"`          // report planned actions without writing files
+	DebugGrid      bool   `json:"debug_grid,omitempty"`       // outline and number every sprite region on the sheet
+	Verbose        bool   `json:"verbose,omitempty"`          // verbose logging
+	Quiet          bool   `json:"quiet,omitempty"`            // log errors only
+	LogJSON        bool   `json:"log_json,omitempty"`         // log one JSON object per message to stderr
+	Converter      string `json:"converter,omitempty"`        // SVG converter backend
+	Sanitize       bool   `json:"sanitize,omitempty"`         // strip scripts, event handlers and external links from SVGs before rendering
+	ViewBox        string `json:"viewbox,omitempty"`          // "x y w h" viewBox given to SVGs that lack a usable one
+	Timeout        string `json:"timeout,omitempty"`          // time limit per SVG render, e.g. 30s; 0 disables it
+	Jobs           int    `json:"jobs,omitempty"`             // SVGs the rod converter renders at once, each on its own browser page
+	Frames         int    `json:"frames,omitempty"`           // frames captured from each animated SVG; 0 renders a still image
+	FrameInterval  string `json:"frame_interval,omitempty"`   // animation time between captured frames, e.g. 100ms
+	SkipErrors     bool   `json:"skip_errors,omitempty"`      // leave out files that fail to render instead of aborting
+	PreserveTree   bool   `json:"preserve_tree,omitempty"`    // mirror the input's subdirectories in the output directory
+	OnCollision    string `json:"on_collision,omitempty"`     // warn, error, skip, rename
+	StdoutEncoding string `json:"stdout_encoding,omitempty"`  // raw or base64, for an output of "-"
+	CacheDir       string `json:"cache_dir,omitempty"`        // where rendered PNGs are cached; the user cache directory when empty
+	NoCache        bool   `json:"no_cache,omitempty"`         // render every SVG without reading or writing the cache
+	SkipUnchanged  bool   `json:"skip_unchanged,omitempty"`   // leave converted outputs whose SVG and settings match the last run
+	MaxMemory      int    `json:"max_memory,omitempty"`       // limit in MB on the estimated memory of a spritesheet
+	MaxFiles       int    `json:"max_files,omitempty"`        // limit on the input files of a directory
+	MaxSprites     int    `json:"max_sprites,omitempty"`      // limit on the sprites of a spritesheet
 }
 
+// DefaultTimeout is the time limit for rendering a single SVG
+const DefaultTimeout = "30s"
+
+// DefaultFrameInterval is the animation time between frames captured with
+// --frames
+const DefaultFrameInterval = "100ms"
+
+// DefaultMaxMemory is the limit in MB on the estimated memory needed to
+// generate a spritesheet
+const DefaultMaxMemory = 500
+
+// DefaultMaxFiles is the limit on the number of input files of a directory
+const DefaultMaxFiles = 1000
+
+// DefaultMaxSprites is the limit on the number of sprites in a spritesheet
+const DefaultMaxSprites = 256
+
+// MaxDPI is the highest raster density accepted for --dpi
+const MaxDPI = 2400
+
+// StdioPath is the --input or --output value that selects standard input or
+// standard output
+const StdioPath = "-"
+
 // SortMode represents different sorting options
 type SortMode string
 
@@ -39,6 +156,124 @@ const (
 	SortByName  SortMode = "name"
 	SortByCTime SortMode = "ctime"
 	SortManual  SortMode = "manual"
+
+	// SortNatural orders by filename comparing digit runs as numbers,
+	// so frame_2 comes before frame_10
+	SortNatural SortMode = "natural"
+
+	// SortByFileSize orders sprites by converted PNG size, heaviest last.
+	// It is applied after conversion and only affects spritesheet mode.
+	SortByFileSize SortMode = "filesize"
+
+	// SortBySize orders sprites by pixel area, trimmed with --trim, smallest
+	// first, and SortBySizeDesc largest first. Like SortByFileSize they are
+	// applied after conversion.
+	SortBySize     SortMode = "size"
+	SortBySizeDesc SortMode = "size-desc"
+)
+
+// IsRenderedSort reports whether the mode orders sprites by their rendered
+// PNGs, so that it can only be applied after conversion
+func (m SortMode) IsRenderedSort() bool {
+	return m == SortByFileSize || m == SortBySize || m == SortBySizeDesc
+}
+
+// MetaFormat represents the metadata file formats
+type MetaFormat string
+
+const (
+	MetaNative             MetaFormat = "native"
+	MetaTexturePackerHash  MetaFormat = "texturepacker-hash"
+	MetaTexturePackerArray MetaFormat = "texturepacker-array"
+	MetaCSS                MetaFormat = "css"
+
+	// MetaGodot writes one AtlasTexture .tres per sprite into the
+	// directory named by --meta
+	MetaGodot MetaFormat = "godot"
+
+	MetaLibGDX MetaFormat = "libgdx"
+
+	// MetaStarling writes a Starling/Sparrow XML TextureAtlas
+	MetaStarling MetaFormat = "starling"
+)
+
+// ResizeFilter selects the sampling used when scaling sprites to the tile size
+type ResizeFilter string
+
+const (
+	// ResizeNearest keeps hard pixel edges, for pixel art
+	ResizeNearest    ResizeFilter = "nearest"
+	ResizeBilinear   ResizeFilter = "bilinear"
+	ResizeCatmullRom ResizeFilter = "catmullrom"
+)
+
+// PNGCompression trades PNG encoding time for file size
+type PNGCompression string
+
+const (
+	PNGDefault PNGCompression = "default"
+	PNGNone    PNGCompression = "none"
+	PNGSpeed   PNGCompression = "speed"
+	PNGBest    PNGCompression = "best"
+)
+
+// Level returns the encoder compression level of the setting
+func (c PNGCompression) Level() png.CompressionLevel {
+	switch c {
+	case PNGNone:
+		return png.NoCompression
+	case PNGSpeed:
+		return png.BestSpeed
+	case PNGBest:
+		return png.BestCompression
+	default:
+		return png.DefaultCompression
+	}
+}
+
+// FlipMode selects the axes an image is mirrored across
+type FlipMode string
+
+const (
+	FlipNone       FlipMode = "none"
+	FlipVertical   FlipMode = "vertical" // upside down, as OpenGL expects texture rows bottom-up
+	FlipHorizontal FlipMode = "horizontal"
+	FlipBoth       FlipMode = "both"
+)
+
+// Axes reports whether the mode mirrors left-right and top-bottom
+func (m FlipMode) Axes() (horizontal, vertical bool) {
+	return m == FlipHorizontal || m == FlipBoth, m == FlipVertical || m == FlipBoth
+}
+
+// NameCase is how the words of file-derived sprite names are joined and cased
+type NameCase string
+
+const (
+	NameCaseNone  NameCase = "none"
+	NameCaseLower NameCase = "lower"
+	NameCaseSnake NameCase = "snake" // my_icon_v2
+	NameCaseKebab NameCase = "kebab" // my-icon-v2
+	NameCaseCamel NameCase = "camel" // myIconV2
+)
+
+// CollisionPolicy decides what happens to input files that would get the same
+// output file, or the same sprite name in a sheet
+type CollisionPolicy string
+
+const (
+	CollisionWarn   CollisionPolicy = "warn" // keep going; later files overwrite earlier ones
+	CollisionError  CollisionPolicy = "error"
+	CollisionSkip   CollisionPolicy = "skip"   // keep only the first file
+	CollisionRename CollisionPolicy = "rename" // add _1, _2, ... to later files
+)
+
+// StdoutEncoding is how an image written to standard output is framed
+type StdoutEncoding string
+
+const (
+	StdoutRaw    StdoutEncoding = "raw"    // the PNG bytes alone
+	StdoutBase64 StdoutEncoding = "base64" // a JSON object with the base64 PNG and the sheet metadata
 )
 
 // ConverterType represents different SVG converter backends
@@ -49,6 +284,9 @@ const (
 	ConverterRod      ConverterType = "rod"
 	ConverterRSVG     ConverterType = "rsvg"
 	ConverterInkscape ConverterType = "inkscape"
+
+	// ConverterAuto picks the best backend available on the system
+	ConverterAuto ConverterType = "auto"
 )
 
 // Validate checks if the configuration is valid
@@ -62,10 +300,6 @@ func (c *Config) Validate() error {
 	}
 
 	// Validate scale and dimensions
-	if c.Scale != 0 && (c.Width != 0 || c.Height != 0) {
-		return fmt.Errorf("cannot specify both scale and width/height")
-	}
-
 	if c.Scale < 0 {
 		return fmt.Errorf("scale must be positive")
 	}
@@ -74,6 +308,102 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("width and height must be positive")
 	}
 
+	if c.DPI < 0 || c.DPI > MaxDPI {
+		return fmt.Errorf("dpi must be between 1 and %d", MaxDPI)
+	}
+
+	if c.Quality < 0 || c.Quality > 100 {
+		return fmt.Errorf("quality must be between 1 and 100")
+	}
+
+	switch PNGCompression(c.PNGCompression) {
+	case "", PNGDefault, PNGNone, PNGSpeed, PNGBest:
+		// valid
+	default:
+		return fmt.Errorf("invalid png-compression: %s (must be default, none, speed, or best)", c.PNGCompression)
+	}
+
+	if c.Palette != 0 && (c.Palette < 2 || c.Palette > 256) {
+		return fmt.Errorf("palette must be between 2 and 256 colors")
+	}
+
+	if c.Palette > 0 && c.GetOutputExt() != ".png" {
+		return fmt.Errorf("palette applies to PNG output only, not %s", c.GetOutputExt())
+	}
+
+	if c.MaxFileBytes < 0 {
+		return fmt.Errorf("max-file-bytes must be non-negative")
+	}
+
+	if c.MaxMemory < 0 {
+		return fmt.Errorf("max-memory must be non-negative")
+	}
+
+	if c.MaxFiles < 0 {
+		return fmt.Errorf("max-files must be non-negative")
+	}
+
+	if c.MaxSprites < 0 {
+		return fmt.Errorf("max-sprites must be non-negative")
+	}
+
+	if c.Jobs < 0 {
+		return fmt.Errorf("jobs must be non-negative")
+	}
+
+	if c.Quiet && c.Verbose {
+		return fmt.Errorf("quiet cannot be combined with --verbose")
+	}
+
+	if _, err := c.BackgroundColor(); err != nil {
+		return err
+	}
+
+	if _, err := c.RenderTimeout(); err != nil {
+		return err
+	}
+
+	if _, err := c.ViewBoxAttr(); err != nil {
+		return err
+	}
+
+	if c.SkipUnchanged && c.NoCache {
+		return fmt.Errorf("skip-unchanged cannot be combined with --no-cache, which disables the records it compares against")
+	}
+
+	if c.Frames < 0 {
+		return fmt.Errorf("frames must be non-negative")
+	}
+
+	// Only a browser plays SMIL and CSS animations
+	if c.Frames > 0 && ConverterType(c.Converter) != ConverterRod {
+		return fmt.Errorf("frames requires --converter rod")
+	}
+
+	if c.Frames > 0 && (c.IsStdinInput() || c.IsStdoutOutput()) {
+		return fmt.Errorf("frames cannot be used with standard input or output")
+	}
+
+	if _, err := c.FrameIntervalDuration(); err != nil {
+		return err
+	}
+
+	if c.IsGIFOutput() && c.Frames < 2 {
+		return fmt.Errorf("gif output is an animation and requires --frames of 2 or more")
+	}
+
+	if c.IsGIFOutput() && (c.Meta != "" || c.MetaCSV != "") {
+		return fmt.Errorf("gif output has no sprite regions to write --meta for")
+	}
+
+	if c.MetaCSV != "" && strings.ToLower(filepath.Ext(c.MetaCSV)) != ".csv" {
+		return fmt.Errorf("meta-csv must have a .csv extension: %s", c.MetaCSV)
+	}
+
+	if c.MetaCSV != "" && filepath.Clean(c.MetaCSV) == filepath.Clean(c.Meta) {
+		return fmt.Errorf("meta-csv and --meta cannot be the same file: %s", c.MetaCSV)
+	}
+
 	// Validate spritesheet dimensions
 	if c.TileWidth < 0 || c.TileHeight < 0 {
 		return fmt.Errorf("tile dimensions must be positive")
@@ -83,31 +413,296 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("cols and rows must be positive")
 	}
 
-	if c.Cols > 0 && c.Rows > 0 {
-		return fmt.Errorf("cannot specify both cols and rows")
+	// Both give a fixed grid, which a split would break up
+	if c.Cols > 0 && c.Rows > 0 && c.MaxSheetSize > 0 {
+		return fmt.Errorf("max-sheet-size cannot split a fixed grid of both cols and rows")
+	}
+
+	if c.RowSpec != "" {
+		if c.Cols > 0 || c.Rows > 0 {
+			return fmt.Errorf("cannot specify row-spec together with cols or rows")
+		}
+		if _, err := c.RowSpecCols(); err != nil {
+			return err
+		}
+	}
+
+	if c.Pack && (c.Cols > 0 || c.Rows > 0 || c.RowSpec != "") {
+		return fmt.Errorf("cannot specify pack together with cols, rows, or row-spec")
+	}
+
+	if c.AllowRotation {
+		if !c.Pack {
+			return fmt.Errorf("allow-rotation requires --pack")
+		}
+		switch MetaFormat(c.MetaFormat) {
+		case MetaCSS, MetaGodot, MetaLibGDX, MetaStarling:
+			return fmt.Errorf("allow-rotation cannot be combined with %s metadata, which cannot describe rotated sprites", c.MetaFormat)
+		}
+	}
+
+	if c.PreserveAspect && c.Pack {
+		return fmt.Errorf("preserve-aspect cannot be combined with pack")
+	}
+
+	if c.Align != "" {
+		if c.Pack {
+			return fmt.Errorf("align cannot be combined with pack")
+		}
+		if _, _, err := c.AlignFractions(); err != nil {
+			return err
+		}
+	}
+
+	if _, _, _, err := c.PivotFractions(); err != nil {
+		return err
+	}
+
+	if _, err := c.SpriteNameTemplate(); err != nil {
+		return err
 	}
 
 	if c.Padding < 0 {
 		return fmt.Errorf("padding must be non-negative")
 	}
 
+	if c.Margin < 0 {
+		return fmt.Errorf("margin must be non-negative")
+	}
+
+	if c.Extrude < 0 {
+		return fmt.Errorf("extrude must be non-negative")
+	}
+
+	if c.Extrude > 0 && c.Padding < 2*c.Extrude {
+		return fmt.Errorf("extrude %d needs a padding of at least %d so neighboring sprites do not overlap", c.Extrude, 2*c.Extrude)
+	}
+
+	if c.MaxSheetSize < 0 {
+		return fmt.Errorf("max-sheet-size must be non-negative")
+	}
+
+	// A page rounded up to a power of two only stays within a limit that is
+	// itself a power of two
+	if (c.POT || c.Square) && c.MaxSheetSize > 0 && c.MaxSheetSize&(c.MaxSheetSize-1) != 0 {
+		return fmt.Errorf("max-sheet-size must be a power of two with --pot or --square, got %d", c.MaxSheetSize)
+	}
+
+	if c.SheetWidth < 0 || c.SheetHeight < 0 {
+		return fmt.Errorf("sheet-width and sheet-height must be non-negative")
+	}
+
+	if c.SheetWidth > 0 || c.SheetHeight > 0 {
+		if c.POT || c.Square {
+			return fmt.Errorf("sheet-width and sheet-height cannot be combined with --pot or --square")
+		}
+		if c.MaxSheetSize > 0 {
+			return fmt.Errorf("sheet-width and sheet-height cannot be combined with max-sheet-size")
+		}
+	} else if c.SheetCenter {
+		return fmt.Errorf("sheet-center requires sheet-width or sheet-height")
+	}
+
+	if c.Dedupe && c.RowSpec != "" {
+		return fmt.Errorf("dedupe cannot be combined with row-spec, whose rows count every sprite")
+	}
+
+	if c.MaxSheetSize > 0 {
+		if c.RowSpec != "" {
+			return fmt.Errorf("max-sheet-size cannot be combined with row-spec")
+		}
+		if !c.Pack && (c.TileWidth > c.MaxSheetSize || c.TileHeight > c.MaxSheetSize) {
+			return fmt.Errorf("tile size %dx%d exceeds max-sheet-size %d", c.TileWidth, c.TileHeight, c.MaxSheetSize)
+		}
+		switch MetaFormat(c.MetaFormat) {
+		case MetaTexturePackerHash, MetaTexturePackerArray:
+			return fmt.Errorf("max-sheet-size cannot be combined with %s metadata, which describes a single image", c.MetaFormat)
+		}
+	}
+
+	if c.AutoPad < 0 {
+		return fmt.Errorf("auto-pad must be non-negative")
+	}
+
+	if c.AutoPad > 0 && c.Pack {
+		return fmt.Errorf("auto-pad cannot be combined with pack")
+	}
+
+	if c.AutoTile && c.Pack {
+		return fmt.Errorf("auto-tile cannot be combined with pack, which places sprites at their own size")
+	}
+
+	if c.AutoPad > 1 && c.Margin%c.AutoPad != 0 {
+		return fmt.Errorf("margin %d must be a multiple of auto-pad %d to keep tiles aligned", c.Margin, c.AutoPad)
+	}
+
+	if c.AutoPad > 1 && (c.TileWidth-c.TileHeight)%c.AutoPad != 0 {
+		return fmt.Errorf("auto-pad %d requires tile width and height to differ by a multiple of %d", c.AutoPad, c.AutoPad)
+	}
+
+	if c.TrimMargin < 0 {
+		return fmt.Errorf("trim-margin must be non-negative")
+	}
+
+	if c.TrimMargin > 0 && !c.Trim {
+		return fmt.Errorf("trim-margin requires --trim")
+	}
+
+	if c.TrimThreshold < 0 || c.TrimThreshold > 255 {
+		return fmt.Errorf("trim-threshold must be between 0 and 255")
+	}
+
+	if c.TrimThreshold > 0 && !c.Trim {
+		return fmt.Errorf("trim-threshold requires --trim")
+	}
+
+	if _, err := c.KeyColor(); err != nil {
+		return err
+	}
+
+	if c.KeyTolerance < 0 || c.KeyTolerance > 255 {
+		return fmt.Errorf("color-key-tolerance must be between 0 and 255")
+	}
+
+	if c.KeyTolerance > 0 && c.ColorKey == "" {
+		return fmt.Errorf("color-key-tolerance requires --color-key")
+	}
+
+	if c.Opacity < 0 || c.Opacity > 1 {
+		return fmt.Errorf("opacity must be between 0 and 1")
+	}
+
+	if _, err := c.TintColor(); err != nil {
+		return err
+	}
+
+	if c.TrimKeepTile && !c.Trim {
+		return fmt.Errorf("trim-keep-tile requires --trim")
+	}
+
+	if c.TrimKeepTile && c.Pack {
+		return fmt.Errorf("trim-keep-tile cannot be combined with pack, which places trimmed sprites at their own size")
+	}
+
 	// Validate sort mode
 	if c.Sort != "" {
 		switch SortMode(c.Sort) {
-		case SortByName, SortByCTime, SortManual:
+		case SortByName, SortNatural, SortByCTime, SortManual, SortByFileSize, SortBySize, SortBySizeDesc:
 			// valid
 		default:
-			return fmt.Errorf("invalid sort mode: %s (must be name, ctime, or manual)", c.Sort)
+			return fmt.Errorf("invalid sort mode: %s (must be name, natural, ctime, manual, filesize, size, or size-desc)", c.Sort)
+		}
+	}
+
+	if c.Manifest != "" && SortMode(c.Sort) != SortManual {
+		return fmt.Errorf("manifest requires --sort manual, got %s", c.Sort)
+	}
+
+	if c.GroupByPrefix && c.Aseprite != "" {
+		return fmt.Errorf("group-by-prefix cannot be combined with --aseprite, which names the animations itself")
+	}
+
+	if len(c.ExtraInputs) > 0 && (c.InputList != "" || c.IsStdinInput()) {
+		return fmt.Errorf("several inputs cannot be combined with --input-list or standard input")
+	}
+
+	for _, pattern := range append(append([]string{}, c.Include...), c.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid include or exclude pattern: %s", pattern)
+		}
+	}
+
+	if c.InputList != "" && (len(c.Include) > 0 || len(c.Exclude) > 0 || c.NoRecursive) {
+		return fmt.Errorf("include, exclude and no-recursive apply to scanned directories and cannot be combined with --input-list")
+	}
+
+	if c.Scales != "" {
+		if _, err := c.SheetScales(); err != nil {
+			return err
+		}
+		if c.IsStdoutOutput() {
+			return fmt.Errorf("scales writes one sheet per density and cannot write to standard output")
+		}
+	}
+
+	if c.InputList != "" && SortMode(c.Sort) != SortManual {
+		return fmt.Errorf("input-list requires --sort manual, got %s", c.Sort)
+	}
+
+	// Validate metadata format
+	if c.MetaFormat != "" {
+		switch MetaFormat(c.MetaFormat) {
+		case MetaNative, MetaTexturePackerHash, MetaTexturePackerArray, MetaCSS, MetaGodot, MetaLibGDX, MetaStarling:
+			// valid
+		default:
+			return fmt.Errorf("invalid meta format: %s (must be native, texturepacker-hash, texturepacker-array, css, godot, libgdx, or starling)", c.MetaFormat)
+		}
+	}
+
+	if MetaFormat(c.MetaFormat) == MetaStarling && c.MaxSheetSize > 0 {
+		return fmt.Errorf("max-sheet-size cannot be combined with starling metadata, which describes a single image")
+	}
+
+	// Validate resize filter
+	if c.ResizeFilter != "" {
+		switch ResizeFilter(c.ResizeFilter) {
+		case ResizeNearest, ResizeBilinear, ResizeCatmullRom:
+			// valid
+		default:
+			return fmt.Errorf("invalid resize filter: %s (must be nearest, bilinear, or catmullrom)", c.ResizeFilter)
+		}
+	}
+
+	// Validate flips
+	for _, flip := range []struct{ name, value string }{{"flip", c.Flip}, {"flip-sheet", c.FlipSheet}} {
+		switch FlipMode(flip.value) {
+		case "", FlipNone, FlipVertical, FlipHorizontal, FlipBoth:
+			// valid
+		default:
+			return fmt.Errorf("invalid %s: %s (must be none, vertical, horizontal, or both)", flip.name, flip.value)
+		}
+	}
+
+	// Validate name case
+	if c.NameCase != "" {
+		switch NameCase(c.NameCase) {
+		case NameCaseNone, NameCaseLower, NameCaseSnake, NameCaseKebab, NameCaseCamel:
+			// valid
+		default:
+			return fmt.Errorf("invalid name-case: %s (must be none, lower, snake, kebab, or camel)", c.NameCase)
+		}
+	}
+
+	// Validate collision policy
+	if c.OnCollision != "" {
+		switch CollisionPolicy(c.OnCollision) {
+		case CollisionWarn, CollisionError, CollisionSkip, CollisionRename:
+			// valid
+		default:
+			return fmt.Errorf("invalid on-collision: %s (must be warn, error, skip, or rename)", c.OnCollision)
+		}
+	}
+
+	// Validate stdout encoding
+	if c.StdoutEncoding != "" {
+		switch StdoutEncoding(c.StdoutEncoding) {
+		case StdoutRaw, StdoutBase64:
+			// valid
+		default:
+			return fmt.Errorf("invalid stdout-encoding: %s (must be raw or base64)", c.StdoutEncoding)
+		}
+		if !c.IsStdoutOutput() {
+			return fmt.Errorf("stdout-encoding requires --output -")
 		}
 	}
 
 	// Validate converter type
 	if c.Converter != "" {
 		switch ConverterType(c.Converter) {
-		case ConverterOkSVG, ConverterRod, ConverterRSVG, ConverterInkscape:
+		case ConverterOkSVG, ConverterRod, ConverterRSVG, ConverterInkscape, ConverterAuto:
 			// valid
 		default:
-			return fmt.Errorf("invalid converter: %s (must be oksvg, rod, rsvg, or inkscape)", c.Converter)
+			return fmt.Errorf("invalid converter: %s (must be oksvg, rod, rsvg, inkscape, or auto)", c.Converter)
 		}
 	}
 
@@ -120,42 +715,526 @@ func (c *Config) SetDefaults() {
 		c.Scale = 1.0
 	}
 
+	if c.DPI == 0 {
+		c.DPI = 96
+	}
+
+	if c.Quality == 0 {
+		c.Quality = 90
+	}
+
+	// Listed files are taken relative to the current directory
+	if c.Input == "" && c.InputList != "" {
+		c.Input = "."
+	}
+
 	if c.Sort == "" {
-		c.Sort = string(SortByName)
+		// A manifest or input list spells out the order itself
+		if c.Manifest != "" || c.InputList != "" {
+			c.Sort = string(SortManual)
+		} else {
+			c.Sort = string(SortByName)
+		}
 	}
 
 	if c.Converter == "" {
 		c.Converter = string(ConverterOkSVG)
 	}
 
-	if c.TileWidth == 0 {
+	if c.OnCollision == "" {
+		c.OnCollision = string(CollisionWarn)
+	}
+
+	if c.MaxMemory == 0 {
+		c.MaxMemory = DefaultMaxMemory
+	}
+
+	if c.MaxFiles == 0 {
+		c.MaxFiles = DefaultMaxFiles
+	}
+
+	if c.MaxSprites == 0 {
+		c.MaxSprites = DefaultMaxSprites
+	}
+
+	if c.StdoutEncoding == "" && c.IsStdoutOutput() {
+		c.StdoutEncoding = string(StdoutRaw)
+	}
+
+	if c.Timeout == "" {
+		c.Timeout = DefaultTimeout
+	}
+
+	if c.Jobs == 0 {
+		c.Jobs = 1
+	}
+
+	if c.FrameInterval == "" {
+		c.FrameInterval = DefaultFrameInterval
+	}
+
+	if c.MetaFormat == "" {
+		c.MetaFormat = string(MetaNative)
+	}
+
+	if c.ResizeFilter == "" {
+		c.ResizeFilter = string(ResizeCatmullRom)
+	}
+
+	if c.PNGCompression == "" {
+		c.PNGCompression = string(PNGDefault)
+	}
+
+	// --auto-tile measures the axes left unset once the sprites are rendered
+	if c.TileWidth == 0 && !c.AutoTile {
 		c.TileWidth = 64
 	}
 
-	if c.TileHeight == 0 {
+	if c.TileHeight == 0 && !c.AutoTile {
 		c.TileHeight = 64
 	}
 
-	if c.Cols == 0 && c.Rows == 0 {
-		c.Cols = 8
+	if c.Cols == 0 && c.Rows == 0 && c.RowSpec == "" && !c.Pack {
+		// Animation frames go in a strip, one row per SVG
+		if c.Frames > 0 {
+			c.Cols = c.Frames
+		} else {
+			c.Cols = 8
+		}
 	}
 }
 
 // IsSpritesheetMode returns true if we're generating a spritesheet
 func (c *Config) IsSpritesheetMode() bool {
-	return c.TileWidth > 0 && c.TileHeight > 0 && (c.Cols > 0 || c.Rows > 0)
+	if c.Pack {
+		return true
+	}
+	tiles := c.AutoTile || c.TileWidth > 0 && c.TileHeight > 0
+	return tiles && (c.Cols > 0 || c.Rows > 0 || c.RowSpec != "")
+}
+
+// InputDirs returns every input path: Input followed by ExtraInputs
+func (c *Config) InputDirs() []string {
+	return append([]string{c.Input}, c.ExtraInputs...)
+}
+
+// AutoTileAxes reports which tile axes --auto-tile measures: those without
+// an explicit size
+func (c *Config) AutoTileAxes() (width, height bool) {
+	if !c.AutoTile {
+		return false, false
+	}
+	return c.TileWidth == 0, c.TileHeight == 0
+}
+
+// RowSpecCols parses the row spec into the number of columns for each row
+func (c *Config) RowSpecCols() ([]int, error) {
+	if c.RowSpec == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(c.RowSpec, ",")
+	cols := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid row-spec %q: %s is not a number", c.RowSpec, part)
+		}
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid row-spec %q: column counts must be positive", c.RowSpec)
+		}
+		cols = append(cols, n)
+	}
+
+	return cols, nil
+}
+
+// SheetScales parses the scales option into the density of each sheet, in
+// the order given. A trailing x is allowed, so "1x,2x" equals "1,2".
+func (c *Config) SheetScales() ([]float64, error) {
+	if c.Scales == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(c.Scales, ",")
+	scales := make([]float64, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSuffix(strings.TrimSpace(part), "x")
+		scale, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scales %q: %s is not a number", c.Scales, part)
+		}
+		if !(scale > 0) || math.IsInf(scale, 0) {
+			return nil, fmt.Errorf("invalid scales %q: scales must be positive", c.Scales)
+		}
+		for _, seen := range scales {
+			if seen == scale {
+				return nil, fmt.Errorf("invalid scales %q: %s is listed twice", c.Scales, part)
+			}
+		}
+		scales = append(scales, scale)
+	}
+
+	return scales, nil
+}
+
+// AtScale returns a copy of the configuration for the sheet of one density
+// of --scales. The render scale and every pixel size of the layout are
+// multiplied by density, and the files written get an @Nx suffix, such as
+// sheet@2x.png and sheet@2x.json, except at density 1.
+func (c *Config) AtScale(density float64) Config {
+	scaled := *c
+	scaled.Scales = ""
+
+	if scaled.Scale == 0 {
+		scaled.Scale = 1
+	}
+	scaled.Scale *= density
+	for _, size := range []*int{
+		&scaled.TileWidth, &scaled.TileHeight, &scaled.Padding, &scaled.Margin, &scaled.Extrude,
+		&scaled.TrimMargin, &scaled.SheetWidth, &scaled.SheetHeight, &scaled.MaxSheetSize,
+	} {
+		*size = ScaleSize(*size, density)
+	}
+
+	for _, path := range []*string{&scaled.Output, &scaled.Meta, &scaled.MetaCSV, &scaled.ImagePath, &scaled.KeepIntermediate} {
+		*path = ScaledPath(*path, density)
+	}
+
+	return scaled
+}
+
+// ScaleSize returns a pixel size given at 1x for a sheet of density,
+// rounded to whole pixels
+func ScaleSize(size int, density float64) int {
+	return int(math.Round(float64(size) * density))
+}
+
+// ScaledPath returns the name of path in the files of a sheet of density:
+// @Nx is inserted before the extension, or appended to a path without one.
+// Density 1 and an empty path are left as they are.
+func ScaledPath(path string, density float64) string {
+	if path == "" || density == 1 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "@" + strconv.FormatFloat(density, 'f', -1, 64) + "x" + ext
+}
+
+// AlignFractions parses the align option into horizontal and vertical
+// positions within the tile: 0 for left/top, 0.5 for center, 1 for
+// right/bottom. Each axis not named is centered, so "bottom" is bottom-center,
+// and an empty option centers on both axes.
+func (c *Config) AlignFractions() (float64, float64, error) {
+	if c.Align == "" {
+		return 0.5, 0.5, nil
+	}
+	return parsePosition("align", c.Align)
+}
+
+// SpriteNameData is what a name template is executed with
+type SpriteNameData struct {
+	Name  string // file name without extension, or the frame name
+	Index int    // position of the sprite in the sheet
+	Dir   string // directory of the file relative to the input, with forward slashes; empty at the top
+}
+
+// SpriteNameTemplate parses the name template, or returns nil when none is
+// set. Templates are tried on sample data so that unknown fields are
+// reported before any file is processed.
+func (c *Config) SpriteNameTemplate() (*template.Template, error) {
+	if c.NameTemplate == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("name").Parse(c.NameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name-template: %w", err)
+	}
+	if err := tmpl.Execute(io.Discard, SpriteNameData{Name: "sprite", Dir: "dir"}); err != nil {
+		return nil, fmt.Errorf("invalid name-template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// PivotFractions parses the pivot option into fractions of the sprite's
+// width and height. It accepts the position names of --align or "x,y"
+// fractions between 0 and 1. The bool is false when no pivot is set.
+func (c *Config) PivotFractions() (float64, float64, bool, error) {
+	if c.Pivot == "" {
+		return 0, 0, false, nil
+	}
+
+	if xs, ys, found := strings.Cut(c.Pivot, ","); found {
+		x, errX := strconv.ParseFloat(strings.TrimSpace(xs), 64)
+		y, errY := strconv.ParseFloat(strings.TrimSpace(ys), 64)
+		if errX != nil || errY != nil {
+			return 0, 0, false, fmt.Errorf("invalid pivot %q (use a position name or x,y fractions such as 0.5,1)", c.Pivot)
+		}
+		if !(x >= 0 && x <= 1 && y >= 0 && y <= 1) {
+			return 0, 0, false, fmt.Errorf("invalid pivot %q: fractions must be between 0 and 1", c.Pivot)
+		}
+		return x, y, true, nil
+	}
+
+	x, y, err := parsePosition("pivot", c.Pivot)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	return x, y, true, nil
+}
+
+// parsePosition parses a position name such as center, bottom or top-left
+// into horizontal and vertical fractions. option names the flag in errors.
+func parsePosition(option, value string) (float64, float64, error) {
+	x, y := 0.5, 0.5
+	xSet, ySet := false, false
+
+	for _, part := range strings.Split(strings.ToLower(value), "-") {
+		switch part {
+		case "left", "right":
+			if xSet {
+				return 0, 0, fmt.Errorf("invalid %s %q: horizontal position given twice", option, value)
+			}
+			x, xSet = 0, true
+			if part == "right" {
+				x = 1
+			}
+		case "top", "bottom":
+			if ySet {
+				return 0, 0, fmt.Errorf("invalid %s %q: vertical position given twice", option, value)
+			}
+			y, ySet = 0, true
+			if part == "bottom" {
+				y = 1
+			}
+		case "center":
+			// centered unless the other part names the axis
+		default:
+			return 0, 0, fmt.Errorf("invalid %s %q (use center, top, bottom, left, right, or combinations like bottom-left)", option, value)
+		}
+	}
+
+	return x, y, nil
+}
+
+// namedColors lists the color names accepted for the background
+var namedColors = map[string]color.NRGBA{
+	"transparent": {},
+	"white":       {R: 255, G: 255, B: 255, A: 255},
+	"black":       {A: 255},
+	"red":         {R: 255, A: 255},
+	"green":       {G: 128, A: 255},
+	"blue":        {B: 255, A: 255},
+	"gray":        {R: 128, G: 128, B: 128, A: 255},
+	"magenta":     {R: 255, B: 255, A: 255},
+}
+
+// BackgroundColor parses the background option. It returns nil when no
+// background is set or the background is transparent.
+func (c *Config) BackgroundColor() (color.Color, error) {
+	if c.Background == "" {
+		return nil, nil
+	}
+
+	bg, err := ParseColor(c.Background)
+	if err != nil {
+		return nil, fmt.Errorf("invalid background: %w", err)
+	}
+	if bg.A == 0 {
+		return nil, nil
+	}
+
+	return bg, nil
+}
+
+// KeyColor parses the color key option. It returns nil when no color key is
+// set. Only the red, green and blue of the key are compared, so its alpha is
+// ignored.
+func (c *Config) KeyColor() (color.Color, error) {
+	if c.ColorKey == "" {
+		return nil, nil
+	}
+
+	key, err := ParseColor(c.ColorKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color-key: %w", err)
+	}
+
+	return key, nil
+}
+
+// TintColor returns the parsed tint color, or nil when no tint is set
+func (c *Config) TintColor() (color.Color, error) {
+	if c.Tint == "" {
+		return nil, nil
+	}
+
+	tint, err := ParseColor(c.Tint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tint: %w", err)
+	}
+
+	return tint, nil
+}
+
+// LogLevel returns the lowest level logged: debug with --verbose, errors
+// only with --quiet and info otherwise
+func (c *Config) LogLevel() logging.Level {
+	switch {
+	case c.Verbose:
+		return logging.LevelDebug
+	case c.Quiet:
+		return logging.LevelError
+	default:
+		return logging.LevelInfo
+	}
+}
+
+// NewLogger returns the command-line logger for the configured level, in
+// JSON with --log-json
+func (c *Config) NewLogger() logging.Logger {
+	return logging.New(c.LogLevel(), c.LogJSON)
+}
+
+// RenderTimeout parses the timeout option, e.g. 30s or 2m. Zero means
+// renders are not limited.
+func (c *Config) RenderTimeout() (time.Duration, error) {
+	if c.Timeout == "" || c.Timeout == "0" {
+		return 0, nil
+	}
+
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout: %s (use a duration such as 30s or 2m)", c.Timeout)
+	}
+	if timeout < 0 {
+		return 0, fmt.Errorf("timeout cannot be negative: %s", c.Timeout)
+	}
+
+	return timeout, nil
+}
+
+// ViewBoxAttr parses the viewbox option, four numbers "x y w h" separated by
+// spaces or commas as in SVG, and returns it in the form written to the
+// viewBox attribute. It returns "" when no viewBox is set.
+func (c *Config) ViewBoxAttr() (string, error) {
+	if c.ViewBox == "" {
+		return "", nil
+	}
+
+	parts := strings.FieldsFunc(c.ViewBox, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	if len(parts) != 4 {
+		return "", fmt.Errorf("invalid viewbox: %q (use four numbers \"x y w h\")", c.ViewBox)
+	}
+
+	values := make([]string, 4)
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("invalid viewbox: %q is not a number", part)
+		}
+		if i >= 2 && v <= 0 {
+			return "", fmt.Errorf("viewbox width and height must be positive: %s", c.ViewBox)
+		}
+		values[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	return strings.Join(values, " "), nil
+}
+
+// FrameIntervalDuration parses the frame interval option, e.g. 100ms or 0.5s
+func (c *Config) FrameIntervalDuration() (time.Duration, error) {
+	if c.FrameInterval == "" {
+		return time.ParseDuration(DefaultFrameInterval)
+	}
+
+	interval, err := time.ParseDuration(c.FrameInterval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid frame-interval: %s (use a duration such as 100ms or 0.5s)", c.FrameInterval)
+	}
+	if interval <= 0 {
+		return 0, fmt.Errorf("frame-interval must be positive: %s", c.FrameInterval)
+	}
+
+	return interval, nil
+}
+
+// ParseColor parses #RRGGBB, #RRGGBBAA or a color name such as white or transparent
+func ParseColor(s string) (color.NRGBA, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	if named, ok := namedColors[s]; ok {
+		return named, nil
+	}
+
+	hex, ok := strings.CutPrefix(s, "#")
+	if !ok || (len(hex) != 6 && len(hex) != 8) {
+		return color.NRGBA{}, fmt.Errorf("%q is not a color (use #RRGGBB, #RRGGBBAA or a name like white or transparent)", s)
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("%q is not a valid hex color", s)
+	}
+
+	if len(hex) == 6 {
+		value = value<<8 | 0xff
+	}
+
+	return color.NRGBA{
+		R: uint8(value >> 24),
+		G: uint8(value >> 16),
+		B: uint8(value >> 8),
+		A: uint8(value),
+	}, nil
+}
+
+// IsStdinInput returns true if the SVG is read from standard input
+func (c *Config) IsStdinInput() bool {
+	return c.Input == StdioPath
+}
+
+// SingleDimension reports whether only one of --width and --height is set,
+// so that SVGs are rendered at their own aspect ratio
+func (c *Config) SingleDimension() bool {
+	return (c.Width > 0) != (c.Height > 0)
+}
+
+// IsStdoutOutput returns true if the PNG is written to standard output
+func (c *Config) IsStdoutOutput() bool {
+	return c.Output == StdioPath
 }
 
 // IsSVGInput returns true if input appears to be SVG file(s)
 func (c *Config) IsSVGInput() bool {
+	if c.IsStdinInput() {
+		return true
+	}
 	ext := filepath.Ext(c.Input)
 	return ext == ".svg"
 }
 
-// GetOutputExt returns the expected output file extension
+// GetOutputExt returns the lower-case extension of the image output. Standard
+// output and output directories hold PNGs.
 func (c *Config) GetOutputExt() string {
-	if c.Meta != "" {
-		return ".json"
+	ext := strings.ToLower(filepath.Ext(c.Output))
+	if c.IsStdoutOutput() || ext == "" {
+		return ".png"
 	}
-	return ".png"
+	return ext
+}
+
+// IsGIFOutput returns true if the captured frames are written as an animated
+// GIF instead of a spritesheet
+func (c *Config) IsGIFOutput() bool {
+	return !c.IsStdoutOutput() && c.GetOutputExt() == ".gif"
+}
+
+// GetMetaExt returns the lower-case extension of the metadata file, or an
+// empty string when no metadata is written
+func (c *Config) GetMetaExt() string {
+	return strings.ToLower(filepath.Ext(c.Meta))
 }
@@ -0,0 +1,61 @@
+package config
+
+import "testing"
+
+func TestGetOutputExt(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{output: "sheet.png", want: ".png"},
+		{output: "sheet.PNG", want: ".png"},
+		{output: "sheet.jpg", want: ".jpg"},
+		{output: "sheet.jpeg", want: ".jpeg"},
+		{output: "sheet.webp", want: ".webp"},
+		{output: "anim.gif", want: ".gif"},
+		{output: "out/dir/icon.Png", want: ".png"},
+		{output: "out/dir", want: ".png"},
+		{output: StdioPath, want: ".png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			cfg := Config{Output: tt.output, Meta: "sheet.csv"}
+			if got := cfg.GetOutputExt(); got != tt.want {
+				t.Errorf("GetOutputExt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMetaExt(t *testing.T) {
+	tests := []struct {
+		meta   string
+		format MetaFormat
+		want   string
+	}{
+		{meta: "", format: MetaNative, want: ""},
+		{meta: "sheet.json", format: MetaNative, want: ".json"},
+		{meta: "sheet.csv", format: MetaNative, want: ".csv"},
+		{meta: "SHEET.JSON", format: MetaNative, want: ".json"},
+		{meta: "sheet.json", format: MetaTexturePackerHash, want: ".json"},
+		{meta: "sheet.json", format: MetaTexturePackerArray, want: ".json"},
+		{meta: "sheet.css", format: MetaCSS, want: ".css"},
+		{meta: "res/sprites", format: MetaGodot, want: ""},
+		{meta: "sheet.atlas", format: MetaLibGDX, want: ".atlas"},
+		{meta: "sheet.xml", format: MetaStarling, want: ".xml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format)+"/"+tt.meta, func(t *testing.T) {
+			// The image extension never leaks into the metadata one
+			cfg := Config{Output: "sheet.jpg", Meta: tt.meta, MetaFormat: string(tt.format)}
+			if got := cfg.GetMetaExt(); got != tt.want {
+				t.Errorf("GetMetaExt() = %q, want %q", got, tt.want)
+			}
+			if got := cfg.GetOutputExt(); got != ".jpg" {
+				t.Errorf("GetOutputExt() = %q, want .jpg", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+// Package logging provides the leveled logger through which the converters,
+// the generator, the exporter and the runner report progress and warnings.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Level is the severity of a log message
+type Level int
+
+const (
+	// LevelDebug is for the step-by-step detail shown with --verbose
+	LevelDebug Level = iota
+	// LevelInfo is for messages shown by default
+	LevelInfo
+	// LevelWarn is for problems that do not stop the run
+	LevelWarn
+	// LevelError is for failures; it is the only level left with --quiet
+	LevelError
+)
+
+// Logger receives messages formatted like fmt.Printf. Implementations must be
+// safe for concurrent use.
+type Logger interface {
+	Debug(format string, args ...any)
+	Info(format string, args ...any)
+	Warn(format string, args ...any)
+	Error(format string, args ...any)
+}
+
+// New returns the logger used by the command line: plain text, with debug
+// and info messages on stdout and warnings and errors on stderr, or one JSON
+// object per message on stderr with json
+func New(level Level, json bool) Logger {
+	if json {
+		return NewJSON(os.Stderr, level)
+	}
+	return NewText(os.Stdout, os.Stderr, level)
+}
+
+// textLogger writes messages as plain lines
+type textLogger struct {
+	out    io.Writer
+	errOut io.Writer
+	level  Level
+}
+
+// NewText returns a logger that writes debug and info messages as plain lines
+// to out, and warnings and errors prefixed with "Warning:" or "Error:" to
+// errOut. Messages below level are dropped.
+func NewText(out, errOut io.Writer, level Level) Logger {
+	return &textLogger{out: out, errOut: errOut, level: level}
+}
+
+func (l *textLogger) Debug(format string, args ...any) {
+	l.log(LevelDebug, l.out, "", format, args)
+}
+
+func (l *textLogger) Info(format string, args ...any) {
+	l.log(LevelInfo, l.out, "", format, args)
+}
+
+func (l *textLogger) Warn(format string, args ...any) {
+	l.log(LevelWarn, l.errOut, "Warning: ", format, args)
+}
+
+func (l *textLogger) Error(format string, args ...any) {
+	l.log(LevelError, l.errOut, "Error: ", format, args)
+}
+
+func (l *textLogger) log(level Level, w io.Writer, prefix, format string, args []any) {
+	if level < l.level {
+		return
+	}
+	fmt.Fprintf(w, prefix+format+"\n", args...)
+}
+
+// jsonLogger writes messages as JSON objects through slog
+type jsonLogger struct {
+	logger *slog.Logger
+}
+
+// NewJSON returns a logger that writes every message at or above level to w
+// as a JSON object with time, level and msg fields, one per line
+func NewJSON(w io.Writer, level Level) Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slogLevel(level)})
+	return &jsonLogger{logger: slog.New(handler)}
+}
+
+func (l *jsonLogger) Debug(format string, args ...any) {
+	l.log(LevelDebug, format, args)
+}
+
+func (l *jsonLogger) Info(format string, args ...any) {
+	l.log(LevelInfo, format, args)
+}
+
+func (l *jsonLogger) Warn(format string, args ...any) {
+	l.log(LevelWarn, format, args)
+}
+
+func (l *jsonLogger) Error(format string, args ...any) {
+	l.log(LevelError, format, args)
+}
+
+func (l *jsonLogger) log(level Level, format string, args []any) {
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, slogLevel(level)) {
+		return
+	}
+	l.logger.Log(ctx, slogLevel(level), fmt.Sprintf(format, args...))
+}
+
+// slogLevel maps a Level to the matching slog level
+func slogLevel(level Level) slog.Level {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// discard drops every message
+type discard struct{}
+
+// Discard returns a logger that drops every message
+func Discard() Logger {
+	return discard{}
+}
+
+func (discard) Debug(string, ...any) {}
+func (discard) Info(string, ...any)  {}
+func (discard) Warn(string, ...any)  {}
+func (discard) Error(string, ...any) {}
@@ -0,0 +1,113 @@
+package metadata
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Animation is a range of sprites that an engine plays as one animation,
+// from an Aseprite frame tag or --group-by-prefix
+type Animation struct {
+	Name      string `json:"name"`
+	From      int    `json:"from"`      // index of the first sprite
+	To        int    `json:"to"`        // index of the last sprite, inclusive
+	Direction string `json:"direction"` // forward, reverse, pingpong or pingpong_reverse
+}
+
+// AnimationDirections lists the playback directions Aseprite writes
+var AnimationDirections = []string{"forward", "reverse", "pingpong", "pingpong_reverse"}
+
+// GroupByPrefix infers animations from sprite names that end in a frame
+// number, such as walk_000, walk_001 and idle_000: sprites sharing the name
+// before the number, and a separator _ - . or space, form the animation of
+// that name. Only prefixes with two or more frames count. The frames of an
+// animation must sit next to each other in ascending frame order, as
+// --sort natural places them; prefixes whose frames do not are returned in
+// scattered and left out, so that no animation plays the wrong sprites.
+func GroupByPrefix(sprites []SpriteInfo) (animations []Animation, scattered []string) {
+	ordered := make([]SpriteInfo, len(sprites))
+	copy(ordered, sprites)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Index < ordered[j].Index })
+
+	type group struct {
+		indexes []int
+		numbers []int
+	}
+	groups := make(map[string]*group)
+	var names []string
+	for _, sprite := range ordered {
+		prefix, number, ok := splitFrameNumber(sprite.Name)
+		if !ok {
+			continue
+		}
+		g, seen := groups[prefix]
+		if !seen {
+			g = &group{}
+			groups[prefix] = g
+			names = append(names, prefix)
+		}
+		g.indexes = append(g.indexes, sprite.Index)
+		g.numbers = append(g.numbers, number)
+	}
+
+	for _, name := range names {
+		g := groups[name]
+		if len(g.indexes) < 2 {
+			continue
+		}
+		if !contiguousAscending(g.indexes, g.numbers) {
+			scattered = append(scattered, name)
+			continue
+		}
+		animations = append(animations, Animation{
+			Name:      name,
+			From:      g.indexes[0],
+			To:        g.indexes[len(g.indexes)-1],
+			Direction: "forward",
+		})
+	}
+
+	return animations, scattered
+}
+
+// splitFrameNumber splits a sprite name such as walk_012 into its prefix and
+// frame number. ok is false for names without a number or a prefix.
+func splitFrameNumber(name string) (prefix string, number int, ok bool) {
+	digits := len(name)
+	for digits > 0 && name[digits-1] >= '0' && name[digits-1] <= '9' {
+		digits--
+	}
+	// Overlong numbers are ids rather than frame numbers
+	if digits == len(name) || len(name)-digits > 9 {
+		return "", 0, false
+	}
+
+	number, err := strconv.Atoi(name[digits:])
+	if err != nil {
+		return "", 0, false
+	}
+	prefix = strings.TrimRight(name[:digits], "_-. ")
+	return prefix, number, prefix != ""
+}
+
+// contiguousAscending reports whether the sprite indexes follow each other
+// without gaps and the frame numbers increase with them
+func contiguousAscending(indexes, numbers []int) bool {
+	for i := 1; i < len(indexes); i++ {
+		if indexes[i] != indexes[i-1]+1 || numbers[i] <= numbers[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// validDirection reports whether direction is one of AnimationDirections
+func validDirection(direction string) bool {
+	for _, valid := range AnimationDirections {
+		if direction == valid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package metadata
+
+import (
+	"reflect"
+	"testing"
+)
+
+// namedSprites returns sprites with the given names, indexed in order
+func namedSprites(names ...string) []SpriteInfo {
+	sprites := make([]SpriteInfo, len(names))
+	for i, name := range names {
+		sprites[i] = SpriteInfo{Name: name, Index: i}
+	}
+	return sprites
+}
+
+func TestGroupByPrefix(t *testing.T) {
+	tests := []struct {
+		name          string
+		sprites       []SpriteInfo
+		wantAnims     []Animation
+		wantScattered []string
+	}{
+		{
+			name:    "groups in natural order",
+			sprites: namedSprites("idle_000", "idle_001", "walk_000", "walk_001", "walk_002"),
+			wantAnims: []Animation{
+				{Name: "idle", From: 0, To: 1, Direction: "forward"},
+				{Name: "walk", From: 2, To: 4, Direction: "forward"},
+			},
+		},
+		{
+			name:          "interleaved prefixes",
+			sprites:       namedSprites("walk_000", "idle_000", "walk_001", "idle_001"),
+			wantScattered: []string{"walk", "idle"},
+		},
+		{
+			name:    "mixed prefixes, one interleaved",
+			sprites: namedSprites("run-1", "run-2", "run-3", "jump_0", "logo", "jump_1", "fly.1", "fly.2"),
+			wantAnims: []Animation{
+				{Name: "run", From: 0, To: 2, Direction: "forward"},
+				{Name: "fly", From: 6, To: 7, Direction: "forward"},
+			},
+			wantScattered: []string{"jump"},
+		},
+		{
+			name:          "frames out of order",
+			sprites:       namedSprites("spin_2", "spin_1", "spin_3"),
+			wantScattered: []string{"spin"},
+		},
+		{
+			name:    "single frames, plain names and ids are not animations",
+			sprites: namedSprites("logo", "star_000", "123", "hash_1234567890", "hash_1234567891"),
+		},
+		{
+			name: "sprite order comes from the index",
+			sprites: []SpriteInfo{
+				{Name: "blink_1", Index: 1},
+				{Name: "other", Index: 2},
+				{Name: "blink_0", Index: 0},
+			},
+			wantAnims: []Animation{{Name: "blink", From: 0, To: 1, Direction: "forward"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			animations, scattered := GroupByPrefix(tt.sprites)
+			if !reflect.DeepEqual(animations, tt.wantAnims) {
+				t.Errorf("animations = %+v, want %+v", animations, tt.wantAnims)
+			}
+			if !reflect.DeepEqual(scattered, tt.wantScattered) {
+				t.Errorf("scattered = %q, want %q", scattered, tt.wantScattered)
+			}
+		})
+	}
+}
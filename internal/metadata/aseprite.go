@@ -0,0 +1,47 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// asepriteExport is the part of an Aseprite JSON export that is read
+type asepriteExport struct {
+	Meta struct {
+		FrameTags []Animation `json:"frameTags"`
+	} `json:"meta"`
+}
+
+// LoadAsepriteTags reads the frame tags of an Aseprite JSON export (hash or
+// array) as animations. Aseprite frame numbers are taken as sprite indexes,
+// so the frames must be placed on the sheet in Aseprite's order. A tag
+// without a direction plays forward.
+func LoadAsepriteTags(path string) ([]Animation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Aseprite file: %w", err)
+	}
+
+	var export asepriteExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("failed to parse Aseprite file %s: %w", path, err)
+	}
+
+	animations := export.Meta.FrameTags
+	for i, tag := range animations {
+		if tag.Name == "" {
+			return nil, fmt.Errorf("aseprite file %s: frame tag %d has no name", path, i+1)
+		}
+		if tag.From < 0 || tag.To < tag.From {
+			return nil, fmt.Errorf("aseprite file %s: frame tag %s has an invalid range %d-%d", path, tag.Name, tag.From, tag.To)
+		}
+		if tag.Direction == "" {
+			animations[i].Direction = "forward"
+		} else if !validDirection(tag.Direction) {
+			return nil, fmt.Errorf("aseprite file %s: frame tag %s has an unknown direction %s", path, tag.Name, tag.Direction)
+		}
+	}
+
+	return animations, nil
+}
@@ -0,0 +1,87 @@
+package metadata
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultCSSPrefix is prepended to sprite names to form CSS class names
+const DefaultCSSPrefix = "sprite-"
+
+// ExportCSS writes a stylesheet with one class per sprite that shows it via
+// background-position on the spritesheet
+func (e *Exporter) ExportCSS(metadata *SpritesheetMetadata, outputPath string) error {
+	e.log.Debug("Exporting CSS stylesheet to: %s", outputPath)
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	prefix := e.config.CSSPrefix
+	if prefix == "" {
+		prefix = DefaultCSSPrefix
+	}
+
+	var b strings.Builder
+	b.WriteString("/* Generated by svg2sheet */\n")
+
+	used := make(map[string]int)
+	for _, sprite := range metadata.Sprites {
+		class := cssIdentifier(prefix + sprite.Name)
+
+		// Names that sanitize to the same class get a numeric suffix
+		used[class]++
+		if n := used[class]; n > 1 {
+			class = fmt.Sprintf("%s-%d", class, n)
+		}
+
+		image := e.imagePath(metadata, outputPath, sprite.Page)
+		fmt.Fprintf(&b, ".%s { width: %dpx; height: %dpx; background: url(%s) -%dpx -%dpx;",
+			class, sprite.Width, sprite.Height, cssURL(image), sprite.X, sprite.Y)
+		if sprite.Pivot != nil {
+			fmt.Fprintf(&b, " transform-origin: %g%% %g%%;", sprite.Pivot.X*100, sprite.Pivot.Y*100)
+		}
+		b.WriteString(" }\n")
+	}
+
+	if err := os.WriteFile(outputPath, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write CSS file: %w", err)
+	}
+
+	return nil
+}
+
+// cssIdentifier turns a name into a valid CSS class identifier by replacing
+// unsupported characters with dashes and avoiding a leading digit
+func cssIdentifier(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r > 0x7f:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('-')
+		}
+	}
+
+	id := b.String()
+	if id == "" {
+		return "_"
+	}
+	// Identifiers cannot start with a digit or a dash followed by a digit
+	if id[0] >= '0' && id[0] <= '9' || len(id) > 1 && id[0] == '-' && id[1] >= '0' && id[1] <= '9' {
+		id = "_" + id
+	}
+	return id
+}
+
+// cssURL quotes a path for use in url() when it contains characters that
+// would end an unquoted URL
+func cssURL(path string) string {
+	if strings.ContainsAny(path, " \t\"'()\\") {
+		return `"` + strings.ReplaceAll(strings.ReplaceAll(path, `\`, `\\`), `"`, `\"`) + `"`
+	}
+	return path
+}
@@ -1,36 +1,66 @@
 package metadata
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/thanhfphan/svg2sheet/internal/config"
+	"github.com/thanhfphan/svg2sheet/internal/logging"
 )
 
+// Version is the svg2sheet version recorded in metadata, set at startup
+var Version = "dev"
+
 // Exporter handles metadata export
 type Exporter struct {
 	config *config.Config
+	log    logging.Logger
 }
 
-// NewExporter creates a new metadata exporter
-func NewExporter(cfg *config.Config) *Exporter {
+// NewExporter creates a new metadata exporter that reports through log
+func NewExporter(cfg *config.Config, log logging.Logger) *Exporter {
 	return &Exporter{
 		config: cfg,
+		log:    log,
 	}
 }
 
 // SpritesheetMetadata contains information about the generated spritesheet
 type SpritesheetMetadata struct {
-	Width      int          `json:"width"`
-	Height     int          `json:"height"`
-	TileWidth  int          `json:"tile_width"`
-	TileHeight int          `json:"tile_height"`
-	Cols       int          `json:"cols"`
-	Rows       int          `json:"rows"`
-	Padding    int          `json:"padding"`
-	Sprites    []SpriteInfo `json:"sprites"`
+	Image         string       `json:"image,omitempty"` // sheet image relative to the metadata file, or --image-path; set when exported
+	Width         int          `json:"width"`
+	Height        int          `json:"height"`
+	ContentWidth  int          `json:"content_width,omitempty"`  // sprite area before --pot/--square or a fixed sheet size grew the sheet
+	ContentHeight int          `json:"content_height,omitempty"` // sprite area before --pot/--square or a fixed sheet size grew the sheet
+	TileWidth     int          `json:"tile_width"`
+	TileHeight    int          `json:"tile_height"`
+	Cols          int          `json:"cols"`
+	Rows          int          `json:"rows"`
+	Padding       int          `json:"padding"`
+	Margin        int          `json:"margin,omitempty"`       // empty border around the sprites on every page edge
+	RowCols       []int        `json:"row_cols,omitempty"`     // columns per row for irregular grids
+	Packed        bool         `json:"packed,omitempty"`       // sprites are bin-packed at their own size, no grid
+	Flip          string       `json:"flip,omitempty"`         // axes the whole sheet was mirrored across by --flip-sheet
+	Density       float64      `json:"density,omitempty"`      // pixel density of the sheet among those of --scales, e.g. 2 for sheet@2x.png
+	Pages         []PageInfo   `json:"pages,omitempty"`        // page images when the sheet was split by --max-sheet-size
+	Hash          string       `json:"hash,omitempty"`         // hex SHA-256 of the sheet file, per page in Pages when split
+	Version       string       `json:"version,omitempty"`      // svg2sheet version that generated the sheet
+	GeneratedAt   string       `json:"generated_at,omitempty"` // RFC 3339 UTC time, or SOURCE_DATE_EPOCH when set
+	Animations    []Animation  `json:"animations,omitempty"`   // sprite ranges played as animations, from --aseprite
+	Sprites       []SpriteInfo `json:"sprites"`
+}
+
+// PageInfo describes one page image of a spritesheet split into pages
+type PageInfo struct {
+	Image  string `json:"image"` // file name of the page image
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Hash   string `json:"hash,omitempty"` // hex SHA-256 of the page image file
 }
 
 // SpriteInfo contains information about individual sprites
@@ -41,20 +71,102 @@ type SpriteInfo struct {
 	Width  int    `json:"width"`
 	Height int    `json:"height"`
 	Index  int    `json:"index"`
+	Page   int    `json:"page,omitempty"` // index into Pages, 0 for a single sheet
+
+	// Aliases names the sprites that --dedupe placed in this sprite's
+	// region. They are still listed with the same coordinates.
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Rotated sprites are stored turned 90 degrees clockwise: X, Y, Width
+	// and Height describe the turned area of the sheet, and consumers turn
+	// it back counterclockwise. Content, Pivot and trim offsets refer to the
+	// sprite as it was before turning.
+	Rotated bool `json:"rotated,omitempty"`
+
+	Source string `json:"source,omitempty"` // input file the sprite was made from, relative to the input directory
+
+	Content *Rect  `json:"content,omitempty"` // content area relative to the sprite, set with --trim-margin, --align, --preserve-aspect or --trim-keep-tile
+	Pivot   *Pivot `json:"pivot,omitempty"`   // set with --pivot
+
+	// With --trim-keep-tile the sprite is its whole tile and Trim is the
+	// area of the untrimmed SourceW x SourceH image that was cut out and
+	// placed at Content. Content is smaller than Trim when the trimmed
+	// pixels were shrunk to fit the tile.
+	Trim *Rect `json:"trim,omitempty"`
+
+	// Trim offsets for packed sheets: the sprite's top-left corner sits at
+	// (SourceX, SourceY) within the untrimmed SourceW x SourceH source image.
+	// SourceW and SourceH are also set along with Trim.
+	Trimmed bool `json:"trimmed,omitempty"`
+	SourceX int  `json:"source_x,omitempty"`
+	SourceY int  `json:"source_y,omitempty"`
+	SourceW int  `json:"source_w,omitempty"`
+	SourceH int  `json:"source_h,omitempty"`
+
+	// With --measure-content, the bounds of the sprite's non-transparent
+	// pixels on the sheet, in the coordinates of X and Y. They are left
+	// out for a sprite without visible pixels.
+	ContentX int `json:"content_x,omitempty"`
+	ContentY int `json:"content_y,omitempty"`
+	ContentW int `json:"content_w,omitempty"`
+	ContentH int `json:"content_h,omitempty"`
 }
 
-// Export saves the metadata to a JSON file
+// Pivot is the point a sprite is positioned and rotated around, as
+// fractions of its untrimmed width and height from the top-left corner.
+// Sprites hold it by pointer rather than as flat fields so a top-left pivot
+// of 0,0 is still written while sheets without --pivot leave it out.
+type Pivot struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Rect describes a rectangular region in pixels
+type Rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Export saves the metadata in the configured format
 func (e *Exporter) Export(metadata *SpritesheetMetadata, outputPath string) error {
-	if e.config.Verbose {
-		fmt.Printf("Exporting metadata to: %s\n", outputPath)
+	switch config.MetaFormat(e.config.MetaFormat) {
+	case config.MetaTexturePackerHash:
+		return e.ExportTexturePacker(metadata, outputPath, false)
+	case config.MetaTexturePackerArray:
+		return e.ExportTexturePacker(metadata, outputPath, true)
+	case config.MetaCSS:
+		return e.ExportCSS(metadata, outputPath)
+	case config.MetaGodot:
+		return e.ExportGodot(metadata, outputPath)
+	case config.MetaLibGDX:
+		return e.ExportLibGDX(metadata, outputPath)
+	case config.MetaStarling:
+		return e.ExportStarling(metadata, outputPath)
+	default:
+		// Native metadata is also available as a flat table of sprites
+		if strings.ToLower(filepath.Ext(outputPath)) == ".csv" {
+			return e.ExportCSV(metadata, outputPath)
+		}
+		return e.ExportJSON(metadata, outputPath)
 	}
+}
+
+// ExportJSON saves the metadata to a JSON file in the native format
+func (e *Exporter) ExportJSON(metadata *SpritesheetMetadata, outputPath string) error {
+	e.log.Debug("Exporting metadata to: %s", outputPath)
 
 	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	// The image path depends on where this file is written
+	withImage := *metadata
+	withImage.Image = e.imagePath(metadata, outputPath, 0)
+
 	// Marshal to JSON with pretty formatting
-	jsonData, err := json.MarshalIndent(metadata, "", "  ")
+	jsonData, err := json.MarshalIndent(&withImage, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
@@ -63,37 +175,92 @@ func (e *Exporter) Export(metadata *SpritesheetMetadata, outputPath string) erro
 		return fmt.Errorf("failed to write metadata file: %w", err)
 	}
 
-	if e.config.Verbose {
-		fmt.Printf("Metadata exported successfully with %d sprites\n", len(metadata.Sprites))
-	}
+	e.log.Debug("Metadata exported successfully with %d sprites", len(metadata.Sprites))
 
 	return nil
 }
 
 // ExportCSV exports metadata in CSV format (alternative format)
 func (e *Exporter) ExportCSV(metadata *SpritesheetMetadata, outputPath string) error {
-	if e.config.Verbose {
-		fmt.Printf("Exporting metadata to CSV: %s\n", outputPath)
-	}
+	e.log.Debug("Exporting metadata to CSV: %s", outputPath)
 
 	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	// Create CSV content
-	csvContent := "name,x,y,width,height,index\n"
+	// Create CSV content; pivot columns are added when --pivot was set, a
+	// rotated column when any sprite was rotated and a source column unless
+	// --no-source-paths left the sources out
+	withPivot := len(metadata.Sprites) > 0 && metadata.Sprites[0].Pivot != nil
+	withSource := len(metadata.Sprites) > 0 && metadata.Sprites[0].Source != ""
+	withRotation := false
+	for _, sprite := range metadata.Sprites {
+		withRotation = withRotation || sprite.Rotated
+	}
+
+	header := []string{"name", "x", "y", "width", "height", "index"}
+	if withPivot {
+		header = append(header, "pivot_x", "pivot_y")
+	}
+	if withRotation {
+		header = append(header, "rotated")
+	}
+	if withSource {
+		header = append(header, "source")
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to write CSV file: %w", err)
+	}
+	defer file.Close()
+
+	// encoding/csv quotes names holding commas, quotes or newlines
+	writer := csv.NewWriter(file)
+	writer.Write(header)
 	for _, sprite := range metadata.Sprites {
-		csvContent += fmt.Sprintf("%s,%d,%d,%d,%d,%d\n",
-			sprite.Name, sprite.X, sprite.Y, sprite.Width, sprite.Height, sprite.Index)
+		record := []string{
+			csvText(sprite.Name),
+			strconv.Itoa(sprite.X),
+			strconv.Itoa(sprite.Y),
+			strconv.Itoa(sprite.Width),
+			strconv.Itoa(sprite.Height),
+			strconv.Itoa(sprite.Index),
+		}
+		if withPivot {
+			record = append(record, strconv.FormatFloat(sprite.Pivot.X, 'g', -1, 64), strconv.FormatFloat(sprite.Pivot.Y, 'g', -1, 64))
+		}
+		if withRotation {
+			record = append(record, strconv.FormatBool(sprite.Rotated))
+		}
+		if withSource {
+			record = append(record, csvText(sprite.Source))
+		}
+		writer.Write(record)
 	}
 
-	if err := os.WriteFile(outputPath, []byte(csvContent), 0644); err != nil {
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV file: %w", err)
+	}
+	if err := file.Close(); err != nil {
 		return fmt.Errorf("failed to write CSV file: %w", err)
 	}
 
 	return nil
 }
 
+// csvText makes a text cell safe to open in a spreadsheet: a value starting
+// with a formula character (=, +, -, @, or a tab or carriage return that
+// some spreadsheets skip) gets a leading single quote so it is shown as
+// text instead of being evaluated
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
 // LoadMetadata loads metadata from a JSON file
 func (e *Exporter) LoadMetadata(inputPath string) (*SpritesheetMetadata, error) {
 	data, err := os.ReadFile(inputPath)
@@ -115,12 +282,14 @@ func (e *Exporter) ValidateMetadata(metadata *SpritesheetMetadata) error {
 		return fmt.Errorf("invalid spritesheet dimensions: %dx%d", metadata.Width, metadata.Height)
 	}
 
-	if metadata.TileWidth <= 0 || metadata.TileHeight <= 0 {
-		return fmt.Errorf("invalid tile dimensions: %dx%d", metadata.TileWidth, metadata.TileHeight)
-	}
+	if !metadata.Packed {
+		if metadata.TileWidth <= 0 || metadata.TileHeight <= 0 {
+			return fmt.Errorf("invalid tile dimensions: %dx%d", metadata.TileWidth, metadata.TileHeight)
+		}
 
-	if metadata.Cols <= 0 || metadata.Rows <= 0 {
-		return fmt.Errorf("invalid grid dimensions: %dx%d", metadata.Cols, metadata.Rows)
+		if metadata.Cols <= 0 || metadata.Rows <= 0 {
+			return fmt.Errorf("invalid grid dimensions: %dx%d", metadata.Cols, metadata.Rows)
+		}
 	}
 
 	if len(metadata.Sprites) == 0 {
@@ -142,7 +311,14 @@ func (e *Exporter) ValidateMetadata(metadata *SpritesheetMetadata) error {
 		}
 
 		// Check if sprite is within spritesheet bounds
-		if sprite.X+sprite.Width > metadata.Width || sprite.Y+sprite.Height > metadata.Height {
+		width, height := metadata.Width, metadata.Height
+		if len(metadata.Pages) > 0 {
+			if sprite.Page < 0 || sprite.Page >= len(metadata.Pages) {
+				return fmt.Errorf("sprite %s is on unknown page %d", sprite.Name, sprite.Page)
+			}
+			width, height = metadata.Pages[sprite.Page].Width, metadata.Pages[sprite.Page].Height
+		}
+		if sprite.X+sprite.Width > width || sprite.Y+sprite.Height > height {
 			return fmt.Errorf("sprite %s extends beyond spritesheet bounds", sprite.Name)
 		}
 	}
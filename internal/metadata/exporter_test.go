@@ -0,0 +1,76 @@
+package metadata
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+	"github.com/thanhfphan/svg2sheet/internal/logging"
+)
+
+// newTestExporter returns an exporter for cfg that logs nowhere
+func newTestExporter(cfg config.Config) *Exporter {
+	return NewExporter(&cfg, logging.Discard())
+}
+
+func TestExportCSVEscaping(t *testing.T) {
+	names := []struct {
+		name string
+		want string
+	}{
+		{name: "plain", want: "plain"},
+		{name: "a,b", want: "a,b"},
+		{name: `say "hi"`, want: `say "hi"`},
+		{name: "two\nlines", want: "two\nlines"},
+		{name: "=SUM(A1:A2)", want: "'=SUM(A1:A2)"},
+		{name: "+1", want: "'+1"},
+		{name: "-1", want: "'-1"},
+		{name: "@cmd", want: "'@cmd"},
+		{name: "\tindent", want: "'\tindent"},
+		{name: "mid=dle", want: "mid=dle"},
+	}
+
+	metadata := &SpritesheetMetadata{}
+	for i, n := range names {
+		metadata.Sprites = append(metadata.Sprites, SpriteInfo{
+			Name: n.name, X: i * 8, Width: 8, Height: 8, Index: i,
+			Source: "=icons/" + n.name + ".svg",
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "sheet.csv")
+	if err := newTestExporter(config.Config{}).ExportCSV(metadata, path); err != nil {
+		t.Fatalf("ExportCSV: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// Reading the file back must give one record per sprite with the
+	// commas, quotes and newlines intact
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV: %v", err)
+	}
+	if len(records) != len(names)+1 {
+		t.Fatalf("got %d records, want a header and %d sprites", len(records), len(names))
+	}
+	if header := records[0]; header[0] != "name" || header[len(header)-1] != "source" {
+		t.Errorf("header = %q, want name first and source last", header)
+	}
+
+	for i, n := range names {
+		record := records[i+1]
+		if record[0] != n.want {
+			t.Errorf("name %q is written as %q, want %q", n.name, record[0], n.want)
+		}
+		if want := "'=icons/" + n.name + ".svg"; record[len(record)-1] != want {
+			t.Errorf("source of %q is written as %q, want %q", n.name, record[len(record)-1], want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package metadata
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thanhfphan/svg2sheet/internal/utils"
+)
+
+// ExportGodot writes one Godot 4 AtlasTexture resource (.tres) per sprite
+// into outputDir. Each resource references the sheet at the configured
+// Godot atlas path, or res://<sheet file name> when none is set; pages of a
+// split sheet get the same _N suffix as their image files.
+func (e *Exporter) ExportGodot(metadata *SpritesheetMetadata, outputDir string) error {
+	e.log.Debug("Exporting Godot AtlasTexture resources to: %s", outputDir)
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	atlasPath := e.config.GodotAtlasPath
+	if atlasPath == "" {
+		atlasPath = "res://" + filepath.Base(e.config.Output)
+	}
+
+	for _, sprite := range metadata.Sprites {
+		atlasPath := atlasPath
+		if len(metadata.Pages) > 0 {
+			atlasPath = utils.PagePath(atlasPath, sprite.Page)
+		}
+
+		path := filepath.Join(outputDir, godotFileName(sprite.Name)+".tres")
+		if err := os.WriteFile(path, []byte(godotAtlasTexture(sprite, atlasPath)), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	e.log.Debug("Exported %d AtlasTexture resources", len(metadata.Sprites))
+
+	return nil
+}
+
+// godotAtlasTexture returns the .tres text of an AtlasTexture for a sprite.
+// Trimmed sprites get a margin so Godot restores their untrimmed size.
+func godotAtlasTexture(sprite SpriteInfo, atlasPath string) string {
+	var b strings.Builder
+
+	b.WriteString("[gd_resource type=\"AtlasTexture\" load_steps=2 format=3]\n\n")
+	fmt.Fprintf(&b, "[ext_resource type=\"Texture2D\" path=%q id=\"1_atlas\"]\n\n", atlasPath)
+	b.WriteString("[resource]\n")
+	b.WriteString("atlas = ExtResource(\"1_atlas\")\n")
+	fmt.Fprintf(&b, "region = Rect2(%d, %d, %d, %d)\n", sprite.X, sprite.Y, sprite.Width, sprite.Height)
+
+	if sprite.Trimmed {
+		fmt.Fprintf(&b, "margin = Rect2(%d, %d, %d, %d)\n",
+			sprite.SourceX, sprite.SourceY, sprite.SourceW-sprite.Width, sprite.SourceH-sprite.Height)
+	}
+
+	return b.String()
+}
+
+// godotFileName makes a sprite name safe to use as a file name
+func godotFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,103 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+)
+
+func TestExportGodot(t *testing.T) {
+	metadata := &SpritesheetMetadata{
+		Width:  96,
+		Height: 32,
+		Sprites: []SpriteInfo{
+			{Name: "icons/play", X: 0, Y: 0, Width: 32, Height: 32},
+			{
+				Name: "stop", X: 34, Y: 2, Width: 20, Height: 24, Index: 1,
+				Trimmed: true, SourceX: 6, SourceY: 4, SourceW: 32, SourceH: 32,
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		atlasPath string
+		wantPath  string
+	}{
+		{name: "default atlas path", wantPath: "res://sheet.png"},
+		{name: "configured atlas path", atlasPath: "res://art/ui/sheet.png", wantPath: "res://art/ui/sheet.png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			e := newTestExporter(config.Config{
+				Output:         filepath.Join(dir, "out", "sheet.png"),
+				GodotAtlasPath: tt.atlasPath,
+			})
+
+			if err := e.ExportGodot(metadata, dir); err != nil {
+				t.Fatalf("ExportGodot: %v", err)
+			}
+
+			want := map[string]string{
+				"icons_play.tres": `[gd_resource type="AtlasTexture" load_steps=2 format=3]
+
+[ext_resource type="Texture2D" path="` + tt.wantPath + `" id="1_atlas"]
+
+[resource]
+atlas = ExtResource("1_atlas")
+region = Rect2(0, 0, 32, 32)
+`,
+				"stop.tres": `[gd_resource type="AtlasTexture" load_steps=2 format=3]
+
+[ext_resource type="Texture2D" path="` + tt.wantPath + `" id="1_atlas"]
+
+[resource]
+atlas = ExtResource("1_atlas")
+region = Rect2(34, 2, 20, 24)
+margin = Rect2(6, 4, 12, 8)
+`,
+			}
+
+			for file, text := range want {
+				got, err := os.ReadFile(filepath.Join(dir, file))
+				if err != nil {
+					t.Fatalf("reading %s: %v", file, err)
+				}
+				if string(got) != text {
+					t.Errorf("%s:\n%s\nwant:\n%s", file, got, text)
+				}
+			}
+		})
+	}
+}
+
+func TestExportGodotPages(t *testing.T) {
+	dir := t.TempDir()
+	e := newTestExporter(config.Config{Output: "sheet.png", GodotAtlasPath: "res://sheet.png"})
+
+	metadata := &SpritesheetMetadata{
+		Pages: []PageInfo{{Image: "sheet_0.png"}, {Image: "sheet_1.png"}},
+		Sprites: []SpriteInfo{
+			{Name: "a", Width: 8, Height: 8},
+			{Name: "b", Width: 8, Height: 8, Index: 1, Page: 1},
+		},
+	}
+	if err := e.ExportGodot(metadata, dir); err != nil {
+		t.Fatalf("ExportGodot: %v", err)
+	}
+
+	for file, path := range map[string]string{"a.tres": "res://sheet_0.png", "b.tres": "res://sheet_1.png"} {
+		got, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `path="` + path + `"`; !strings.Contains(string(got), want) {
+			t.Errorf("%s does not reference %s:\n%s", file, path, got)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package metadata
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+)
+
+// ExportLibGDX writes the metadata as a LibGDX TextureAtlas (.atlas) file
+func (e *Exporter) ExportLibGDX(metadata *SpritesheetMetadata, outputPath string) error {
+	e.log.Debug("Exporting LibGDX atlas to: %s", outputPath)
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	if err := os.WriteFile(outputPath, []byte(e.libGDXAtlas(metadata, outputPath)), 0644); err != nil {
+		return fmt.Errorf("failed to write atlas file: %w", err)
+	}
+
+	return nil
+}
+
+// libGDXAtlas returns the atlas text: for every page a page header followed
+// by one region per sprite on that page
+func (e *Exporter) libGDXAtlas(metadata *SpritesheetMetadata, outputPath string) string {
+	// Nearest-neighbor resizing is used for pixel art, which should not be
+	// smoothed when rendered either
+	filter := "Linear"
+	if config.ResizeFilter(e.config.ResizeFilter) == config.ResizeNearest {
+		filter = "Nearest"
+	}
+
+	var b strings.Builder
+	for page := 0; page < max(1, len(metadata.Pages)); page++ {
+		width, height := metadata.Width, metadata.Height
+		if len(metadata.Pages) > 0 {
+			width, height = metadata.Pages[page].Width, metadata.Pages[page].Height
+		}
+
+		fmt.Fprintf(&b, "\n%s\n", e.imagePath(metadata, outputPath, page))
+		fmt.Fprintf(&b, "size: %d,%d\n", width, height)
+		b.WriteString("format: RGBA8888\n")
+		fmt.Fprintf(&b, "filter: %s,%s\n", filter, filter)
+		b.WriteString("repeat: none\n")
+
+		for _, sprite := range metadata.Sprites {
+			if sprite.Page == page {
+				writeLibGDXRegion(&b, sprite)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// writeLibGDXRegion appends the region entry of a sprite to an atlas
+func writeLibGDXRegion(b *strings.Builder, sprite SpriteInfo) {
+	name, index := libGDXRegionName(sprite.Name)
+
+	origW, origH := sprite.Width, sprite.Height
+	offsetX, offsetY := 0, 0
+	if sprite.Trimmed {
+		// LibGDX offsets are measured from the bottom-left corner
+		origW, origH = sprite.SourceW, sprite.SourceH
+		offsetX = sprite.SourceX
+		offsetY = sprite.SourceH - sprite.SourceY - sprite.Height
+	}
+
+	fmt.Fprintf(b, "%s\n", name)
+	b.WriteString("  rotate: false\n")
+	fmt.Fprintf(b, "  xy: %d, %d\n", sprite.X, sprite.Y)
+	fmt.Fprintf(b, "  size: %d, %d\n", sprite.Width, sprite.Height)
+	fmt.Fprintf(b, "  orig: %d, %d\n", origW, origH)
+	fmt.Fprintf(b, "  offset: %d, %d\n", offsetX, offsetY)
+	fmt.Fprintf(b, "  index: %d\n", index)
+}
+
+// libGDXRegionName splits a trailing _N frame number off a sprite name the
+// way the LibGDX texture packer does, so "walk_2" becomes region "walk" with
+// index 2. Names without a frame number get index -1.
+func libGDXRegionName(name string) (string, int) {
+	sep := strings.LastIndexByte(name, '_')
+	if sep <= 0 || sep == len(name)-1 {
+		return name, -1
+	}
+
+	index, err := strconv.Atoi(name[sep+1:])
+	if err != nil || index < 0 {
+		return name, -1
+	}
+
+	return name[:sep], index
+}
@@ -0,0 +1,135 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+)
+
+func TestExportLibGDX(t *testing.T) {
+	metadata := &SpritesheetMetadata{
+		Width:  128,
+		Height: 64,
+		Sprites: []SpriteInfo{
+			{Name: "idle", X: 0, Y: 0, Width: 32, Height: 32},
+			{Name: "walk_2", X: 34, Y: 0, Width: 32, Height: 32, Index: 1},
+			{
+				Name: "coin", X: 68, Y: 4, Width: 20, Height: 24, Index: 2,
+				Trimmed: true, SourceX: 6, SourceY: 3, SourceW: 32, SourceH: 32,
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		want   string
+	}{
+		{
+			name: "linear",
+			want: `
+sheet.png
+size: 128,64
+format: RGBA8888
+filter: Linear,Linear
+repeat: none
+idle
+  rotate: false
+  xy: 0, 0
+  size: 32, 32
+  orig: 32, 32
+  offset: 0, 0
+  index: -1
+walk
+  rotate: false
+  xy: 34, 0
+  size: 32, 32
+  orig: 32, 32
+  offset: 0, 0
+  index: 2
+coin
+  rotate: false
+  xy: 68, 4
+  size: 20, 24
+  orig: 32, 32
+  offset: 6, 5
+  index: -1
+`,
+		},
+		{
+			name:   "nearest",
+			filter: string(config.ResizeNearest),
+			want: `
+sheet.png
+size: 128,64
+format: RGBA8888
+filter: Nearest,Nearest
+repeat: none
+idle
+  rotate: false
+  xy: 0, 0
+  size: 32, 32
+  orig: 32, 32
+  offset: 0, 0
+  index: -1
+walk
+  rotate: false
+  xy: 34, 0
+  size: 32, 32
+  orig: 32, 32
+  offset: 0, 0
+  index: 2
+coin
+  rotate: false
+  xy: 68, 4
+  size: 20, 24
+  orig: 32, 32
+  offset: 6, 5
+  index: -1
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			e := newTestExporter(config.Config{
+				Output:       filepath.Join(dir, "sheet.png"),
+				ResizeFilter: tt.filter,
+			})
+
+			path := filepath.Join(dir, "sheet.atlas")
+			if err := e.ExportLibGDX(metadata, path); err != nil {
+				t.Fatalf("ExportLibGDX: %v", err)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("atlas:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLibGDXRejectsRotation(t *testing.T) {
+	// Regions are always written unrotated, so rotated packing must not reach them
+	cfg := config.Config{
+		Input:         ".",
+		Output:        "sheet.png",
+		Pack:          true,
+		AllowRotation: true,
+		MetaFormat:    string(config.MetaLibGDX),
+	}
+	cfg.SetDefaults()
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "allow-rotation") {
+		t.Errorf("Validate = %v, want allow-rotation rejected with LibGDX metadata", err)
+	}
+}
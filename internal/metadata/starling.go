@@ -0,0 +1,73 @@
+package metadata
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// starlingAtlas is the root element of a Starling/Sparrow texture atlas
+type starlingAtlas struct {
+	XMLName     xml.Name             `xml:"TextureAtlas"`
+	ImagePath   string               `xml:"imagePath,attr"`
+	SubTextures []starlingSubTexture `xml:"SubTexture"`
+}
+
+// starlingSubTexture is the region of one sprite. The frame attributes are
+// only written for trimmed sprites: frameX and frameY are the negated
+// position of the trimmed area within the untrimmed frame, and default to 0.
+type starlingSubTexture struct {
+	Name        string `xml:"name,attr"`
+	X           int    `xml:"x,attr"`
+	Y           int    `xml:"y,attr"`
+	Width       int    `xml:"width,attr"`
+	Height      int    `xml:"height,attr"`
+	FrameX      int    `xml:"frameX,attr,omitempty"`
+	FrameY      int    `xml:"frameY,attr,omitempty"`
+	FrameWidth  int    `xml:"frameWidth,attr,omitempty"`
+	FrameHeight int    `xml:"frameHeight,attr,omitempty"`
+}
+
+// ExportStarling writes the metadata as a Starling/Sparrow XML texture atlas
+func (e *Exporter) ExportStarling(metadata *SpritesheetMetadata, outputPath string) error {
+	e.log.Debug("Exporting Starling atlas to: %s", outputPath)
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	atlas := starlingAtlas{
+		ImagePath:   e.imagePath(metadata, outputPath, 0),
+		SubTextures: make([]starlingSubTexture, len(metadata.Sprites)),
+	}
+	for i, sprite := range metadata.Sprites {
+		texture := starlingSubTexture{
+			Name:   sprite.Name,
+			X:      sprite.X,
+			Y:      sprite.Y,
+			Width:  sprite.Width,
+			Height: sprite.Height,
+		}
+		if sprite.Trimmed {
+			texture.FrameX = -sprite.SourceX
+			texture.FrameY = -sprite.SourceY
+			texture.FrameWidth = sprite.SourceW
+			texture.FrameHeight = sprite.SourceH
+		}
+		atlas.SubTextures[i] = texture
+	}
+
+	// encoding/xml escapes names holding <, &, quotes and the like
+	data, err := xml.MarshalIndent(atlas, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal atlas: %w", err)
+	}
+
+	data = append([]byte(xml.Header), data...)
+	if err := os.WriteFile(outputPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write atlas file: %w", err)
+	}
+
+	return nil
+}
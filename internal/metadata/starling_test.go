@@ -0,0 +1,53 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+)
+
+func TestExportStarling(t *testing.T) {
+	dir := t.TempDir()
+	metadata := &SpritesheetMetadata{
+		Width:  64,
+		Height: 32,
+		Sprites: []SpriteInfo{
+			{Name: "full", X: 0, Y: 0, Width: 32, Height: 32},
+			{
+				Name: "coin", X: 34, Y: 2, Width: 20, Height: 24, Index: 1,
+				Trimmed: true, SourceX: 6, SourceY: 3, SourceW: 32, SourceH: 32,
+			},
+			{
+				Name: "edge", X: 56, Y: 0, Width: 8, Height: 30, Index: 2,
+				Trimmed: true, SourceX: 0, SourceY: 2, SourceW: 8, SourceH: 32,
+			},
+			{Name: `a<b & "c"`, X: 0, Y: 0, Width: 32, Height: 32, Index: 3},
+		},
+	}
+
+	path := filepath.Join(dir, "sheet.xml")
+	e := newTestExporter(config.Config{Output: filepath.Join(dir, "sheet.png")})
+	if err := e.ExportStarling(metadata, path); err != nil {
+		t.Fatalf("ExportStarling: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// frameX and frameY are the negated trim offset and are left out when 0
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<TextureAtlas imagePath="sheet.png">
+    <SubTexture name="full" x="0" y="0" width="32" height="32"></SubTexture>
+    <SubTexture name="coin" x="34" y="2" width="20" height="24" frameX="-6" frameY="-3" frameWidth="32" frameHeight="32"></SubTexture>
+    <SubTexture name="edge" x="56" y="0" width="8" height="30" frameY="-2" frameWidth="8" frameHeight="32"></SubTexture>
+    <SubTexture name="a&lt;b &amp; &#34;c&#34;" x="0" y="0" width="32" height="32"></SubTexture>
+</TextureAtlas>
+`
+	if string(got) != want {
+		t.Errorf("atlas:\n%s\nwant:\n%s", got, want)
+	}
+}
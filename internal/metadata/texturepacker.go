@@ -0,0 +1,203 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/thanhfphan/svg2sheet/internal/utils"
+)
+
+// tpRect is a rectangle in TexturePacker JSON
+type tpRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// tpSize is a size in TexturePacker JSON
+type tpSize struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// tpFrame describes one sprite in TexturePacker JSON
+type tpFrame struct {
+	Filename         string   `json:"filename,omitempty"` // array format only
+	Frame            tpRect   `json:"frame"`
+	Rotated          bool     `json:"rotated"`
+	Trimmed          bool     `json:"trimmed"`
+	SpriteSourceSize tpRect   `json:"spriteSourceSize"`
+	SourceSize       tpSize   `json:"sourceSize"`
+	Pivot            *Pivot   `json:"pivot,omitempty"`
+	Aliases          []string `json:"aliases,omitempty"` // frames sharing this frame's region
+}
+
+// tpMeta is the meta block of TexturePacker JSON
+type tpMeta struct {
+	App     string `json:"app"`
+	Version string `json:"version"`
+	Image   string `json:"image"`
+	Format  string `json:"format"`
+	Size    tpSize `json:"size"`
+	Scale   string `json:"scale"`
+
+	// Aseprite's name for animations, which Phaser's createFromAseprite reads
+	FrameTags []Animation `json:"frameTags,omitempty"`
+}
+
+// tpFrameHash keeps frames keyed by name in sheet order, which a Go map
+// would lose when marshaled
+type tpFrameHash struct {
+	names  []string
+	frames []tpFrame
+}
+
+// MarshalJSON writes the frames as a JSON object in sheet order
+func (h tpFrameHash) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range h.names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		frame, err := json.Marshal(h.frames[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(frame)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// ExportTexturePacker saves the metadata in TexturePacker's JSON format, as
+// read by Phaser, PixiJS and other engines. With asArray the frames are a
+// list carrying their names ("JSON (Array)"); otherwise they are an object
+// keyed by name ("JSON (Hash)").
+func (e *Exporter) ExportTexturePacker(metadata *SpritesheetMetadata, outputPath string, asArray bool) error {
+	e.log.Debug("Exporting TexturePacker metadata to: %s", outputPath)
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	meta := tpMeta{
+		App:     "svg2sheet",
+		Version: "1.0",
+		Image:   e.imagePath(metadata, outputPath, 0),
+		Format:  "RGBA8888",
+		Size:    tpSize{W: metadata.Width, H: metadata.Height},
+		Scale:   "1",
+
+		FrameTags: metadata.Animations,
+	}
+	if metadata.Density > 0 {
+		meta.Scale = strconv.FormatFloat(metadata.Density, 'f', -1, 64)
+	}
+
+	frames := make([]tpFrame, 0, len(metadata.Sprites))
+	names := make([]string, 0, len(metadata.Sprites))
+	for _, sprite := range metadata.Sprites {
+		// TexturePacker gives rotated frames their unrotated size
+		width, height := sprite.Width, sprite.Height
+		if sprite.Rotated {
+			width, height = height, width
+		}
+
+		frame := tpFrame{
+			Frame:            tpRect{X: sprite.X, Y: sprite.Y, W: width, H: height},
+			Rotated:          sprite.Rotated,
+			Trimmed:          sprite.Trimmed,
+			SpriteSourceSize: tpRect{W: width, H: height},
+			SourceSize:       tpSize{W: width, H: height},
+			Pivot:            sprite.Pivot,
+			Aliases:          sprite.Aliases,
+		}
+		if sprite.Trimmed {
+			frame.SpriteSourceSize.X = sprite.SourceX
+			frame.SpriteSourceSize.Y = sprite.SourceY
+			frame.SourceSize = tpSize{W: sprite.SourceW, H: sprite.SourceH}
+		}
+		if asArray {
+			frame.Filename = sprite.Name
+		}
+
+		frames = append(frames, frame)
+		names = append(names, sprite.Name)
+	}
+
+	var doc interface{}
+	if asArray {
+		doc = struct {
+			Frames []tpFrame `json:"frames"`
+			Meta   tpMeta    `json:"meta"`
+		}{frames, meta}
+	} else {
+		doc = struct {
+			Frames tpFrameHash `json:"frames"`
+			Meta   tpMeta      `json:"meta"`
+		}{tpFrameHash{names: names, frames: frames}, meta}
+	}
+
+	jsonData, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	if err := os.WriteFile(outputPath, jsonData, 0644); err != nil {
+		return fmt.Errorf("failed to write metadata file: %w", err)
+	}
+
+	return nil
+}
+
+// sheetFile returns the path of the spritesheet image holding a page
+func (e *Exporter) sheetFile(metadata *SpritesheetMetadata, page int) string {
+	if len(metadata.Pages) == 0 {
+		return e.config.Output
+	}
+	return utils.PagePath(e.config.Output, page)
+}
+
+// imagePath returns the path metadata written to metaPath uses for the image
+// of a page: --image-path when set, with the page suffix of split sheets, or
+// else the image relative to the metadata file. A sheet written to standard
+// output has no path, so it is "" then.
+func (e *Exporter) imagePath(metadata *SpritesheetMetadata, metaPath string, page int) string {
+	if e.config.ImagePath == "" && e.config.IsStdoutOutput() {
+		return ""
+	}
+	if e.config.ImagePath == "" {
+		return e.sheetPathFrom(metaPath, e.sheetFile(metadata, page))
+	}
+	if len(metadata.Pages) == 0 {
+		return e.config.ImagePath
+	}
+	return utils.PagePath(e.config.ImagePath, page)
+}
+
+// sheetPathFrom returns a spritesheet image path relative to the directory
+// of a metadata file, falling back to the image's file name
+func (e *Exporter) sheetPathFrom(metaPath, sheetPath string) string {
+	// Rel cannot relate an absolute path to a relative one
+	if filepath.IsAbs(metaPath) != filepath.IsAbs(sheetPath) {
+		metaPath, _ = filepath.Abs(metaPath)
+		sheetPath, _ = filepath.Abs(sheetPath)
+	}
+	rel, err := filepath.Rel(filepath.Dir(metaPath), sheetPath)
+	if err != nil {
+		return filepath.Base(sheetPath)
+	}
+	return filepath.ToSlash(rel)
+}
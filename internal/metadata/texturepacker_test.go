@@ -0,0 +1,102 @@
+package metadata
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+)
+
+func TestImagePath(t *testing.T) {
+	abs := filepath.Join(t.TempDir(), "build")
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		output    string
+		meta      string
+		imagePath string
+		pages     int
+		want      []string // image path of each page
+	}{
+		{name: "next to the metadata", output: "out/sheet.png", meta: "out/sheet.json", want: []string{"sheet.png"}},
+		{name: "metadata in a subdirectory", output: "out/sheet.png", meta: "out/meta/sheet.json", want: []string{"../sheet.png"}},
+		{name: "image in a sibling directory", output: "out/img/sheet.png", meta: "out/data/sheet.json", want: []string{"../img/sheet.png"}},
+		{name: "both absolute", output: filepath.Join(abs, "img", "sheet.png"), meta: filepath.Join(abs, "sheet.json"), want: []string{"img/sheet.png"}},
+		{name: "absolute image, relative metadata", output: filepath.Join(cwd, "out", "sheet.png"), meta: "out/sheet.json", want: []string{"sheet.png"}},
+		{name: "relative image, absolute metadata", output: "out/sheet.png", meta: filepath.Join(cwd, "meta", "sheet.json"), want: []string{"../out/sheet.png"}},
+		{name: "pages", output: "out/sheet.png", meta: "out/sheet.json", pages: 2, want: []string{"sheet_0.png", "sheet_1.png"}},
+		{name: "image path override", output: "out/sheet.png", meta: "out/sheet.json", imagePath: "/static/sprites.png", want: []string{"/static/sprites.png"}},
+		{name: "image path URL with pages", output: "out/sheet.png", meta: "out/sheet.json", imagePath: "https://cdn.example.com/s.png", pages: 2,
+			want: []string{"https://cdn.example.com/s_0.png", "https://cdn.example.com/s_1.png"}},
+		{name: "standard output", output: config.StdioPath, meta: "out/sheet.json", want: []string{""}},
+		{name: "standard output with image path", output: config.StdioPath, meta: "out/sheet.json", imagePath: "sheet.png", want: []string{"sheet.png"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestExporter(config.Config{Output: tt.output, Meta: tt.meta, ImagePath: tt.imagePath})
+			metadata := &SpritesheetMetadata{}
+			for i := 0; i < tt.pages; i++ {
+				metadata.Pages = append(metadata.Pages, PageInfo{})
+			}
+
+			for page, want := range tt.want {
+				if got := e.imagePath(metadata, tt.meta, page); got != want {
+					t.Errorf("page %d image path = %q, want %q", page, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExportedImagePath(t *testing.T) {
+	dir := t.TempDir()
+	metadata := &SpritesheetMetadata{Width: 8, Height: 8, Sprites: []SpriteInfo{{Name: "a", Width: 8, Height: 8}}}
+
+	for _, format := range []config.MetaFormat{config.MetaNative, config.MetaTexturePackerHash} {
+		t.Run(string(format), func(t *testing.T) {
+			metaPath := filepath.Join(dir, "data", string(format)+".json")
+			e := newTestExporter(config.Config{
+				Output:     filepath.Join(dir, "img", "sheet.png"),
+				Meta:       metaPath,
+				MetaFormat: string(format),
+			})
+			if err := e.Export(metadata, metaPath); err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+
+			data, err := os.ReadFile(metaPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var written struct {
+				Image string `json:"image"`
+				Meta  struct {
+					Image string `json:"image"`
+				} `json:"meta"`
+			}
+			if err := json.Unmarshal(data, &written); err != nil {
+				t.Fatal(err)
+			}
+
+			got := written.Image
+			if format != config.MetaNative {
+				got = written.Meta.Image
+			}
+			if got != "../img/sheet.png" {
+				t.Errorf("image = %q, want ../img/sheet.png", got)
+			}
+		})
+	}
+
+	// The caller's metadata keeps no path of its own
+	if metadata.Image != "" {
+		t.Errorf("Export set Image on the caller's metadata to %q", metadata.Image)
+	}
+}
@@ -1,62 +1,163 @@
 package spritesheet
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
-	"image/png"
+	"io"
 	"math"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/thanhfphan/svg2sheet/internal/config"
+	"github.com/thanhfphan/svg2sheet/internal/logging"
 	"github.com/thanhfphan/svg2sheet/internal/metadata"
 	"github.com/thanhfphan/svg2sheet/internal/utils"
 )
 
 // Generator handles spritesheet generation
 type Generator struct {
-	config *config.Config
+	config       *config.Config
+	log          logging.Logger
+	nameTemplate *template.Template // parsed --name-template, nil when unset
 }
 
-// NewGenerator creates a new spritesheet generator
-func NewGenerator(cfg *config.Config) *Generator {
+// NewGenerator creates a new spritesheet generator that reports through log
+func NewGenerator(cfg *config.Config, log logging.Logger) *Generator {
+	// Validated by Config.Validate
+	nameTemplate, _ := cfg.SpriteNameTemplate()
+
 	return &Generator{
-		config: cfg,
+		config:       cfg,
+		log:          log,
+		nameTemplate: nameTemplate,
 	}
 }
 
-// Generate creates a spritesheet from the given PNG files
-func (g *Generator) Generate(fileMappings []utils.FileMapping, outputPath string) (*metadata.SpritesheetMetadata, error) {
-	if len(fileMappings) == 0 {
-		return nil, fmt.Errorf("no PNG files provided")
+// Generate creates a spritesheet from the given PNG files.
+// Cancelling ctx stops loading images and returns ctx.Err().
+func (g *Generator) Generate(ctx context.Context, fileMappings []utils.FileMapping, outputPath string) (*metadata.SpritesheetMetadata, error) {
+	pages, metadata, err := g.build(ctx, fileMappings)
+	if err != nil {
+		return nil, err
+	}
+
+	// Save spritesheet, one file per page when it was split
+	if len(pages) == 1 {
+		if err := g.saveSpritesheet(pages[0], outputPath); err != nil {
+			return nil, fmt.Errorf("failed to save spritesheet: %w", err)
+		}
+		if metadata.Hash, err = utils.FileSHA256(outputPath); err != nil {
+			return nil, fmt.Errorf("failed to hash spritesheet: %w", err)
+		}
+		return metadata, nil
+	}
+
+	pagePaths := make([]string, len(pages))
+	for i := range pages {
+		pagePaths[i] = utils.PagePath(outputPath, i)
+		if utils.FileExists(pagePaths[i]) && !g.config.Force {
+			return nil, fmt.Errorf("output file already exists: %s (use --force to overwrite)", pagePaths[i])
+		}
+	}
+
+	for i, page := range pages {
+		if err := g.saveSpritesheet(page, pagePaths[i]); err != nil {
+			return nil, fmt.Errorf("failed to save spritesheet page %d: %w", i, err)
+		}
+		metadata.Pages[i].Image = filepath.Base(pagePaths[i])
+		if metadata.Pages[i].Hash, err = utils.FileSHA256(pagePaths[i]); err != nil {
+			return nil, fmt.Errorf("failed to hash spritesheet page %d: %w", i, err)
+		}
+
+		g.log.Debug("Saved page %d: %s", i, pagePaths[i])
+	}
+
+	return metadata, nil
+}
+
+// GenerateTo is like Generate but writes the spritesheet to w as a PNG
+// instead of saving it. Split sheets have several images, so the sheet must
+// fit on one page.
+func (g *Generator) GenerateTo(ctx context.Context, fileMappings []utils.FileMapping, w io.Writer) (*metadata.SpritesheetMetadata, error) {
+	pages, metadata, err := g.build(ctx, fileMappings)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pages) > 1 {
+		return nil, fmt.Errorf("spritesheet was split into %d pages, which cannot be written to one stream", len(pages))
 	}
 
-	if g.config.Verbose {
-		fmt.Printf("Generating spritesheet from %d files\n", len(fileMappings))
+	hash := sha256.New()
+	if err := g.writeSpritesheet(pages[0], io.MultiWriter(w, hash)); err != nil {
+		return nil, fmt.Errorf("failed to write spritesheet: %w", err)
+	}
+	metadata.Hash = hex.EncodeToString(hash.Sum(nil))
+
+	return metadata, nil
+}
+
+// build loads the images, lays them out and draws the sheet pages
+func (g *Generator) build(ctx context.Context, fileMappings []utils.FileMapping) ([]image.Image, *metadata.SpritesheetMetadata, error) {
+	if len(fileMappings) == 0 {
+		return nil, nil, fmt.Errorf("no PNG files provided")
 	}
 
+	g.log.Debug("Generating spritesheet from %d files", len(fileMappings))
+
 	// Load and process images
-	images, err := g.loadImages(fileMappings)
+	images, err := g.loadImages(ctx, fileMappings)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load images: %w", err)
+		return nil, nil, fmt.Errorf("failed to load images: %w", err)
 	}
 
+	// Identical sprites share a region, so only the distinct ones are laid out
+	regions, distinct := g.dedupeImages(images)
+
 	// Calculate layout
-	layout := g.calculateLayout(len(images))
+	var layout *Layout
+	if g.config.Pack {
+		layout, err = g.packLayout(distinct)
+	} else {
+		layout, err = g.calculateLayout(imageSizes(distinct))
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to calculate layout: %w", err)
+	}
+	g.roundToPowerOfTwo(layout)
+	if err := g.fixSheetSize(layout); err != nil {
+		return nil, nil, err
+	}
 
 	// Create spritesheet
-	spritesheet, metadata, err := g.createSpritesheet(images, layout)
+	pages, metadata, err := g.createSpritesheet(images, regions, layout)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create spritesheet: %w", err)
+		return nil, nil, fmt.Errorf("failed to create spritesheet: %w", err)
 	}
 
-	// Save spritesheet
-	if err := g.saveSpritesheet(spritesheet, outputPath); err != nil {
-		return nil, fmt.Errorf("failed to save spritesheet: %w", err)
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
 	}
 
-	return metadata, nil
+	return pages, metadata, nil
+}
+
+// generatedAt returns the generation time recorded in metadata. A
+// SOURCE_DATE_EPOCH environment variable pins it for reproducible builds.
+func generatedAt() time.Time {
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		return time.Unix(epoch, 0).UTC()
+	}
+	return time.Now().UTC()
 }
 
 // ImageInfo holds information about a loaded image
@@ -64,48 +165,133 @@ type ImageInfo struct {
 	Image        image.Image
 	Filename     string
 	OriginalPath string
+	Content      image.Rectangle // content area within the processed image
+	Source       image.Rectangle // processed image area relative to the untrimmed source, set when trimmed
+	SourceWidth  int             // untrimmed source width
+	SourceHeight int             // untrimmed source height
+	TileSize     image.Point     // tile size from --manifest, zero for the configured one
 	Width        int
 	Height       int
 }
 
-// Layout holds spritesheet layout information
+// Layout holds spritesheet layout information. When the sheet is split into
+// pages, Width and Height are those of the first (largest) page and tile
+// positions are relative to the tile's page.
 type Layout struct {
 	Cols       int
 	Rows       int
 	TileWidth  int
 	TileHeight int
 	Padding    int
+	Margin     int // empty border on every page edge, included in the sizes and positions
 	Width      int
 	Height     int
+	RowCols    []int             // columns per row for irregular grids, nil for uniform grids
+	Rects      []image.Rectangle // sprite areas for packed sheets, nil for grids
+	Rotated    []bool            // packed sprites turned 90 degrees clockwise, nil without --allow-rotation
+	PageOf     []int             // page of each tile when split into pages, nil for a single sheet
+	PageSizes  []image.Point     // size of each page when split into pages
+	PerPage    int               // tiles per page of a split grid
+	Offset     image.Point       // shift of a grid within a larger fixed sheet for --sheet-center
+
+	// Size of the sprite area before --pot, --square, --sheet-width or
+	// --sheet-height grew the sheet, zero when the sheet kept its size
+	ContentWidth  int
+	ContentHeight int
+}
+
+// PageCount returns the number of sheet pages
+func (l *Layout) PageCount() int {
+	if l.PageOf == nil {
+		return 1
+	}
+	return len(l.PageSizes)
+}
+
+// Page returns the page holding the tile at index
+func (l *Layout) Page(index int) int {
+	if l.PageOf == nil {
+		return 0
+	}
+	return l.PageOf[index]
+}
+
+// PageSize returns the pixel size of a page
+func (l *Layout) PageSize(page int) image.Point {
+	if l.PageOf == nil {
+		return image.Pt(l.Width, l.Height)
+	}
+	return l.PageSizes[page]
+}
+
+// IsRotated reports whether the sprite at index is turned 90 degrees
+// clockwise, so that its tile has the sprite's width and height swapped
+func (l *Layout) IsRotated(index int) bool {
+	return l.Rotated != nil && l.Rotated[index]
+}
+
+// TileRect returns the area of the tile's page covered by the tile at index
+func (l *Layout) TileRect(index int) image.Rectangle {
+	if l.Rects != nil {
+		return l.Rects[index]
+	}
+
+	x, y := l.TilePosition(index)
+	return image.Rect(x, y, x+l.TileWidth, y+l.TileHeight)
+}
+
+// TilePosition returns the top-left pixel position of the tile at index
+// within its page
+func (l *Layout) TilePosition(index int) (int, int) {
+	if l.Rects != nil {
+		return l.Rects[index].Min.X, l.Rects[index].Min.Y
+	}
+
+	if l.PerPage > 0 {
+		index %= l.PerPage
+	}
+
+	col, row := index%l.Cols, index/l.Cols
+
+	if l.RowCols != nil {
+		col, row = index, 0
+		for row < len(l.RowCols)-1 && col >= l.RowCols[row] {
+			col -= l.RowCols[row]
+			row++
+		}
+	}
+
+	return l.Offset.X + l.Margin + col*(l.TileWidth+l.Padding), l.Offset.Y + l.Margin + row*(l.TileHeight+l.Padding)
 }
 
 // loadImages loads all PNG files and returns image information
-func (g *Generator) loadImages(fileMappings []utils.FileMapping) ([]*ImageInfo, error) {
+func (g *Generator) loadImages(ctx context.Context, fileMappings []utils.FileMapping) ([]*ImageInfo, error) {
 	var images []*ImageInfo
 
 	for _, mapping := range fileMappings {
-		if g.config.Verbose {
-			fmt.Printf("Loading image: %s\n", mapping.PNGPath)
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
 
+		g.log.Debug("Loading image: %s", mapping.PNGPath)
+
 		img, err := g.loadImage(mapping.PNGPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load %s: %w", mapping.PNGPath, err)
 		}
 
 		// Process image (resize, trim if needed)
-		processedImg := g.processImage(img)
-
-		// Use original filename for sprite naming
-		originalName := filepath.Base(mapping.OriginalPath)
-		if ext := filepath.Ext(originalName); ext != "" {
-			originalName = originalName[:len(originalName)-len(ext)]
-		}
+		processedImg, content, source := g.processImage(img, mapping.TileSize)
 
 		images = append(images, &ImageInfo{
 			Image:        processedImg,
-			Filename:     originalName,
+			Filename:     mapping.SpriteName(),
 			OriginalPath: mapping.OriginalPath,
+			Content:      content,
+			Source:       source,
+			SourceWidth:  img.Bounds().Dx(),
+			SourceHeight: img.Bounds().Dy(),
+			TileSize:     mapping.TileSize,
 			Width:        processedImg.Bounds().Dx(),
 			Height:       processedImg.Bounds().Dy(),
 		})
@@ -114,42 +300,195 @@ func (g *Generator) loadImages(fileMappings []utils.FileMapping) ([]*ImageInfo,
 	return images, nil
 }
 
-// loadImage loads a single PNG file
+// loadImage loads a single PNG, JPEG or GIF file
 func (g *Generator) loadImage(filename string) (image.Image, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
+	return utils.DecodeImageFile(filename)
+}
+
+// processImage processes an image (color key, resize, trim, flip, tint,
+// etc.) and returns it along with the area its content occupies in the
+// processed image and, for trimmed images, the area the processed image
+// covers in the source image. tileSize is the sprite's size from --manifest, zero to use the configured
+// tile.
+func (g *Generator) processImage(img image.Image, tileSize image.Point) (image.Image, image.Rectangle, image.Rectangle) {
+	// Validated by Config.Validate
+	if key, _ := g.config.KeyColor(); key != nil {
+		img = utils.KeyColorToAlpha(img, key, uint8(g.config.KeyTolerance))
 	}
-	defer file.Close()
 
-	img, err := png.Decode(file)
-	if err != nil {
-		return nil, err
+	sourceSize := img.Bounds().Size()
+	source := image.Rect(0, 0, sourceSize.X, sourceSize.Y)
+	if g.config.Trim {
+		img, source = utils.TrimTransparentRect(img, uint8(g.config.TrimThreshold))
 	}
 
-	return img, nil
+	content := image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy())
+
+	if g.config.TrimMargin > 0 {
+		img = utils.PadImage(img, g.config.TrimMargin)
+		content = content.Add(image.Pt(g.config.TrimMargin, g.config.TrimMargin))
+		source = source.Inset(-g.config.TrimMargin)
+	}
+
+	img, content = g.fitTile(img, content, tileSize)
+
+	// Content and source areas follow the mirrored pixels
+	horizontal, vertical := config.FlipMode(g.config.Flip).Axes()
+	if horizontal || vertical {
+		size := img.Bounds().Size()
+		img = utils.FlipImage(img, horizontal, vertical)
+		content = mirrorRect(content, size, horizontal, vertical)
+		source = mirrorRect(source, sourceSize, horizontal, vertical)
+	}
+
+	// Applied last, so trimming and placement see the original alpha.
+	// Validated by Config.Validate.
+	if tint, _ := g.config.TintColor(); tint != nil || g.config.Opacity > 0 {
+		opacity := g.config.Opacity
+		if opacity == 0 {
+			opacity = 1
+		}
+		img = utils.AdjustColors(img, opacity, tint)
+	}
+
+	return img, content, source
 }
 
-// processImage processes an image (resize, trim, etc.)
-func (g *Generator) processImage(img image.Image) image.Image {
-	if g.config.Trim {
-		img = utils.TrimTransparent(img)
+// fitTile scales or places an image for its tile and moves content
+// accordingly
+func (g *Generator) fitTile(img image.Image, content image.Rectangle, tileSize image.Point) (image.Image, image.Rectangle) {
+	// Packed sheets keep each sprite at its own size unless the manifest
+	// gives it one
+	if g.config.Pack && tileSize == (image.Point{}) {
+		return img, content
+	}
+
+	tileWidth, tileHeight := g.tileSize(tileSize)
+
+	// Only one of --width and --height keeps the aspect ratio of the SVGs,
+	// so stretching them to the tile would undo it
+	if g.letterboxes() {
+		return g.alignImage(img, content, tileWidth, tileHeight)
 	}
 
 	// Resize to tile dimensions if they don't match
 	bounds := img.Bounds()
-	if bounds.Dx() != g.config.TileWidth || bounds.Dy() != g.config.TileHeight {
-		img = utils.ResizeImage(img, g.config.TileWidth, g.config.TileHeight)
+	if bounds.Dx() != tileWidth || bounds.Dy() != tileHeight {
+		if distortsAspect(bounds.Dx(), bounds.Dy(), tileWidth, tileHeight) {
+			g.log.Warn("stretching %dx%d image to %dx%d tile distorts its aspect ratio (use --preserve-aspect to keep it)",
+				bounds.Dx(), bounds.Dy(), tileWidth, tileHeight)
+		}
+		img = utils.ResizeImageFilter(img, tileWidth, tileHeight, config.ResizeFilter(g.config.ResizeFilter))
+		content = scaleRect(content, bounds.Dx(), bounds.Dy(), tileWidth, tileHeight)
+	}
+
+	return img, content
+}
+
+// letterboxes reports whether sprites are placed within their tiles without
+// distortion instead of being stretched to the tile size
+func (g *Generator) letterboxes() bool {
+	return g.config.Align != "" || g.config.PreserveAspect || g.config.TrimKeepTile || g.config.SingleDimension()
+}
+
+// mirrorRect mirrors r within an area of the given size across the chosen
+// axes
+func mirrorRect(r image.Rectangle, size image.Point, horizontal, vertical bool) image.Rectangle {
+	if horizontal {
+		r.Min.X, r.Max.X = size.X-r.Max.X, size.X-r.Min.X
+	}
+	if vertical {
+		r.Min.Y, r.Max.Y = size.Y-r.Max.Y, size.Y-r.Min.Y
+	}
+	return r
+}
+
+// tileSize returns the tile a sprite is fitted to: its size from --manifest,
+// with the configured tile size filling in axes the manifest left out
+func (g *Generator) tileSize(size image.Point) (int, int) {
+	width, height := size.X, size.Y
+	if width == 0 {
+		width = g.config.TileWidth
+	}
+	if height == 0 {
+		height = g.config.TileHeight
+	}
+	return width, height
+}
+
+// alignImage places an image within the tile without distorting it and moves
+// content accordingly. With --preserve-aspect the image is scaled to fit the
+// tile; otherwise it keeps its natural size and is only shrunk if too large.
+func (g *Generator) alignImage(img image.Image, content image.Rectangle, tileWidth, tileHeight int) (image.Image, image.Rectangle) {
+	bounds := img.Bounds()
+
+	if g.config.PreserveAspect || bounds.Dx() > tileWidth || bounds.Dy() > tileHeight {
+		img = utils.ResizeImageWithAspectRatio(img, tileWidth, tileHeight, config.ResizeFilter(g.config.ResizeFilter))
+		content = scaleRect(content, bounds.Dx(), bounds.Dy(), img.Bounds().Dx(), img.Bounds().Dy())
 	}
 
-	return img
+	// Validated by Config.Validate
+	alignX, alignY, _ := g.config.AlignFractions()
+	img, offset := utils.PlaceImage(img, tileWidth, tileHeight, alignX, alignY)
+
+	return img, content.Add(offset)
+}
+
+// distortsAspect reports whether scaling srcW x srcH to dstW x dstH changes
+// the aspect ratio by more than one percent
+func distortsAspect(srcW, srcH, dstW, dstH int) bool {
+	src := float64(srcW) / float64(srcH)
+	dst := float64(dstW) / float64(dstH)
+	return math.Abs(src-dst)/dst > 0.01
+}
+
+// scaleRect maps a rectangle from a srcW x srcH space into a dstW x dstH space
+func scaleRect(r image.Rectangle, srcW, srcH, dstW, dstH int) image.Rectangle {
+	return image.Rect(
+		r.Min.X*dstW/srcW,
+		r.Min.Y*dstH/srcH,
+		r.Max.X*dstW/srcW,
+		r.Max.Y*dstH/srcH,
+	)
 }
 
-// calculateLayout determines the spritesheet layout
-func (g *Generator) calculateLayout(imageCount int) *Layout {
+// calculateLayout determines the grid layout for sprites of the given sizes.
+// Every cell is as large as the largest sprite, so sprites with a smaller
+// tile from --manifest sit in the top-left corner of their cell.
+func (g *Generator) calculateLayout(sizes []image.Point) (*Layout, error) {
 	var cols, rows int
+	imageCount := len(sizes)
+
+	var tileWidth, tileHeight int
+	for _, size := range sizes {
+		tileWidth, tileHeight = max(tileWidth, size.X), max(tileHeight, size.Y)
+	}
 
-	if g.config.Cols > 0 {
+	rowCols, err := g.config.RowSpecCols()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowCols != nil {
+		total := 0
+		for _, n := range rowCols {
+			total += n
+			if n > cols {
+				cols = n
+			}
+		}
+		if total != imageCount {
+			return nil, fmt.Errorf("row-spec %q describes %d sprites but %d were found", g.config.RowSpec, total, imageCount)
+		}
+		rows = len(rowCols)
+	} else if g.config.Cols > 0 && g.config.Rows > 0 {
+		// A fixed grid, filled row by row, with the cells after the last
+		// sprite left empty
+		cols, rows = g.config.Cols, g.config.Rows
+		if cols*rows < imageCount {
+			return nil, fmt.Errorf("a %dx%d grid of cols and rows has %d cells but %d sprites were found", cols, rows, cols*rows, imageCount)
+		}
+	} else if g.config.Cols > 0 {
 		cols = g.config.Cols
 		rows = int(math.Ceil(float64(imageCount) / float64(cols)))
 	} else if g.config.Rows > 0 {
@@ -161,85 +500,720 @@ func (g *Generator) calculateLayout(imageCount int) *Layout {
 		rows = int(math.Ceil(float64(imageCount) / float64(cols)))
 	}
 
-	width := cols*g.config.TileWidth + (cols-1)*g.config.Padding
-	height := rows*g.config.TileHeight + (rows-1)*g.config.Padding
+	padding := g.alignedPadding(tileWidth, tileHeight)
+	margin := g.config.Margin
 
-	return &Layout{
+	width := cols*tileWidth + (cols-1)*padding + 2*margin
+	height := rows*tileHeight + (rows-1)*padding + 2*margin
+
+	layout := &Layout{
 		Cols:       cols,
 		Rows:       rows,
-		TileWidth:  g.config.TileWidth,
-		TileHeight: g.config.TileHeight,
-		Padding:    g.config.Padding,
+		TileWidth:  tileWidth,
+		TileHeight: tileHeight,
+		Padding:    padding,
+		Margin:     margin,
 		Width:      width,
 		Height:     height,
+		RowCols:    rowCols,
+	}
+
+	maxSize := g.config.MaxSheetSize
+	if maxSize > 0 && (tileWidth+2*margin > maxSize || tileHeight+2*margin > maxSize) {
+		if margin > 0 {
+			return nil, fmt.Errorf("tile size %dx%d with margin %d exceeds max-sheet-size %d", tileWidth, tileHeight, margin, maxSize)
+		}
+		return nil, fmt.Errorf("tile size %dx%d exceeds max-sheet-size %d", tileWidth, tileHeight, maxSize)
+	}
+	if maxSize > 0 && (width > maxSize || height > maxSize) {
+		g.splitGrid(layout, imageCount, maxSize)
 	}
+
+	return layout, nil
 }
 
-// createSpritesheet creates the actual spritesheet image and metadata
-func (g *Generator) createSpritesheet(images []*ImageInfo, layout *Layout) (image.Image, *metadata.SpritesheetMetadata, error) {
-	spritesheet := image.NewRGBA(image.Rect(0, 0, layout.Width, layout.Height))
+// splitGrid spreads a grid layout that exceeds maxSize over several pages.
+// Every page keeps the same columns, capped to what fits in maxSize, and is
+// filled row by row before the next page starts.
+func (g *Generator) splitGrid(layout *Layout, imageCount, maxSize int) {
+	// calculateLayout ensures that at least one tile fits on a page
+	inner := maxSize - 2*layout.Margin
+	maxCols := (inner + layout.Padding) / (layout.TileWidth + layout.Padding)
+	maxRows := (inner + layout.Padding) / (layout.TileHeight + layout.Padding)
 
-	// Create metadata
-	meta := &metadata.SpritesheetMetadata{
-		Width:      layout.Width,
-		Height:     layout.Height,
-		TileWidth:  layout.TileWidth,
-		TileHeight: layout.TileHeight,
-		Cols:       layout.Cols,
-		Rows:       layout.Rows,
-		Padding:    layout.Padding,
-		Sprites:    make([]metadata.SpriteInfo, 0, len(images)),
+	cols := min(layout.Cols, maxCols)
+	rowsPerPage := maxRows
+	if g.config.Rows > 0 {
+		rowsPerPage = min(g.config.Rows, maxRows)
+	}
+	perPage := cols * rowsPerPage
+
+	pageCount := (imageCount + perPage - 1) / perPage
+	layout.PageOf = make([]int, imageCount)
+	layout.PageSizes = make([]image.Point, pageCount)
+	for i := range layout.PageOf {
+		layout.PageOf[i] = i / perPage
+	}
+
+	width := cols*layout.TileWidth + (cols-1)*layout.Padding + 2*layout.Margin
+	for page := range layout.PageSizes {
+		count := min(perPage, imageCount-page*perPage)
+		rows := (count + cols - 1) / cols
+		layout.PageSizes[page] = image.Pt(width, rows*layout.TileHeight+(rows-1)*layout.Padding+2*layout.Margin)
+	}
+
+	layout.Cols = cols
+	layout.Rows = min(rowsPerPage, (imageCount+cols-1)/cols)
+	layout.PerPage = perPage
+	layout.Width, layout.Height = layout.PageSizes[0].X, layout.PageSizes[0].Y
+
+	g.log.Debug("Split %d sprites into %d pages of up to %d cols x %d rows", imageCount, pageCount, cols, rowsPerPage)
+}
+
+// PlanLayout computes the layout for sprites of the given sizes without
+// loading or drawing any image. Grid layouts size every cell for the
+// largest sprite.
+func (g *Generator) PlanLayout(sizes []image.Point) (*Layout, error) {
+	if len(sizes) == 0 {
+		return nil, fmt.Errorf("no sprites to lay out")
+	}
+
+	var layout *Layout
+	var err error
+	if g.config.Pack {
+		layout, err = g.packSizes(sizes)
+	} else {
+		layout, err = g.calculateLayout(sizes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	g.roundToPowerOfTwo(layout)
+	if err := g.fixSheetSize(layout); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+// PlanMetadata returns the metadata a sheet of the given sprites would get,
+// with sizes[i] the size of the sprite made from sprites[i], without loading
+// or drawing any image. Only the names and original paths of the mappings
+// are used. Hashes are left empty.
+func (g *Generator) PlanMetadata(sprites []utils.FileMapping, sizes []image.Point) (*metadata.SpritesheetMetadata, error) {
+	layout, err := g.PlanLayout(sizes)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := g.newMetadata(layout)
+	for i, sprite := range sprites {
+		size := sizes[i]
+		if layout.IsRotated(i) {
+			size = image.Pt(size.Y, size.X)
+		}
+		rect := layout.TileRect(i)
+
+		name, err := g.SpriteName(sprite, i)
+		if err != nil {
+			return nil, err
+		}
+
+		meta.Sprites = append(meta.Sprites, metadata.SpriteInfo{
+			Name:    name,
+			X:       rect.Min.X,
+			Y:       rect.Min.Y,
+			Width:   size.X,
+			Height:  size.Y,
+			Index:   i,
+			Page:    layout.Page(i),
+			Rotated: layout.IsRotated(i),
+			Source:  g.SourcePath(sprite.OriginalPath),
+		})
+	}
+
+	g.flipSprites(meta, layout)
+	return meta, nil
+}
+
+// flipSprites moves every sprite to where --flip-sheet mirrors it on its page
+// and mirrors its content, trim offset and pivot along with its pixels
+func (g *Generator) flipSprites(meta *metadata.SpritesheetMetadata, layout *Layout) {
+	horizontal, vertical := config.FlipMode(g.config.FlipSheet).Axes()
+	if !horizontal && !vertical {
+		return
+	}
+	meta.Flip = g.config.FlipSheet
+
+	for i := range meta.Sprites {
+		sprite := &meta.Sprites[i]
+		page := layout.PageSize(sprite.Page)
+		region := image.Rect(sprite.X, sprite.Y, sprite.X+sprite.Width, sprite.Y+sprite.Height)
+		region = mirrorRect(region, page, horizontal, vertical)
+		sprite.X, sprite.Y = region.Min.X, region.Min.Y
+
+		// The other fields describe rotated sprites upright, where the
+		// sheet's axes are swapped
+		h, v, size := horizontal, vertical, image.Pt(sprite.Width, sprite.Height)
+		if sprite.Rotated {
+			h, v, size = vertical, horizontal, image.Pt(size.Y, size.X)
+		}
+
+		if sprite.Content != nil {
+			content := image.Rect(sprite.Content.X, sprite.Content.Y,
+				sprite.Content.X+sprite.Content.Width, sprite.Content.Y+sprite.Content.Height)
+			content = mirrorRect(content, size, h, v)
+			sprite.Content.X, sprite.Content.Y = content.Min.X, content.Min.Y
+		}
+		if sprite.Trim != nil {
+			trim := image.Rect(sprite.Trim.X, sprite.Trim.Y, sprite.Trim.X+sprite.Trim.Width, sprite.Trim.Y+sprite.Trim.Height)
+			trim = mirrorRect(trim, image.Pt(sprite.SourceW, sprite.SourceH), h, v)
+			sprite.Trim.X, sprite.Trim.Y = trim.Min.X, trim.Min.Y
+		}
+		if sprite.Trimmed {
+			source := image.Rect(sprite.SourceX, sprite.SourceY, sprite.SourceX+size.X, sprite.SourceY+size.Y)
+			source = mirrorRect(source, image.Pt(sprite.SourceW, sprite.SourceH), h, v)
+			sprite.SourceX, sprite.SourceY = source.Min.X, source.Min.Y
+		}
+		if sprite.ContentW > 0 {
+			content := image.Rect(sprite.ContentX, sprite.ContentY, sprite.ContentX+sprite.ContentW, sprite.ContentY+sprite.ContentH)
+			content = mirrorRect(content, page, horizontal, vertical)
+			sprite.ContentX, sprite.ContentY = content.Min.X, content.Min.Y
+		}
+		if sprite.Pivot != nil {
+			if h {
+				sprite.Pivot.X = 1 - sprite.Pivot.X
+			}
+			if v {
+				sprite.Pivot.Y = 1 - sprite.Pivot.Y
+			}
+		}
+	}
+}
+
+// roundToPowerOfTwo grows the sheet and each page to power-of-two sizes for
+// --pot, and to square ones for --square. Sprites keep their positions and
+// the added area is left empty; the sprite area is kept as ContentWidth and
+// ContentHeight.
+func (g *Generator) roundToPowerOfTwo(layout *Layout) {
+	if !g.config.POT && !g.config.Square {
+		return
+	}
+
+	layout.ContentWidth, layout.ContentHeight = layout.Width, layout.Height
+	layout.Width, layout.Height = g.powerOfTwoSize(layout.Width, layout.Height)
+	for i, size := range layout.PageSizes {
+		width, height := g.powerOfTwoSize(size.X, size.Y)
+		layout.PageSizes[i] = image.Pt(width, height)
+	}
+
+	g.log.Debug("Rounded %dx%d sheet up to %dx%d", layout.ContentWidth, layout.ContentHeight, layout.Width, layout.Height)
+}
+
+// fixSheetSize grows the sheet to the canvas given by --sheet-width and
+// --sheet-height, keeping the computed size on axes without one. The sprite
+// area stays in the top-left corner, or in the middle with --sheet-center,
+// and is kept as ContentWidth and ContentHeight. Sprites that do not fit are
+// an error telling how many pixels are missing.
+func (g *Generator) fixSheetSize(layout *Layout) error {
+	if g.config.SheetWidth == 0 && g.config.SheetHeight == 0 {
+		return nil
+	}
+
+	width, height := layout.Width, layout.Height
+	if g.config.SheetWidth > 0 {
+		width = g.config.SheetWidth
+	}
+	if g.config.SheetHeight > 0 {
+		height = g.config.SheetHeight
+	}
+
+	var shortfall []string
+	if layout.Width > width {
+		shortfall = append(shortfall, fmt.Sprintf("%d px too narrow", layout.Width-width))
+	}
+	if layout.Height > height {
+		shortfall = append(shortfall, fmt.Sprintf("%d px too short", layout.Height-height))
+	}
+	if shortfall != nil {
+		return fmt.Errorf("sprites need a %dx%d sheet but the fixed size is %dx%d: %s",
+			layout.Width, layout.Height, width, height, strings.Join(shortfall, ", "))
+	}
+
+	if g.config.SheetCenter {
+		offset := image.Pt((width-layout.Width)/2, (height-layout.Height)/2)
+		if layout.Rects != nil {
+			for i := range layout.Rects {
+				layout.Rects[i] = layout.Rects[i].Add(offset)
+			}
+		} else {
+			layout.Offset = offset
+		}
+	}
+
+	layout.ContentWidth, layout.ContentHeight = layout.Width, layout.Height
+	layout.Width, layout.Height = width, height
+
+	g.log.Debug("Placed %dx%d sprite area on a fixed %dx%d sheet", layout.ContentWidth, layout.ContentHeight, width, height)
+	return nil
+}
+
+// powerOfTwoSize rounds width and height up to powers of two, using the
+// larger one for both with --square
+func (g *Generator) powerOfTwoSize(width, height int) (int, int) {
+	width, height = nextPowerOfTwo(width), nextPowerOfTwo(height)
+	if g.config.Square {
+		side := max(width, height)
+		return side, side
 	}
+	return width, height
+}
+
+// nextPowerOfTwo returns the smallest power of two that is at least n
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
 
-	// Place images on the spritesheet
+// packLayout bin-packs the images at their own size into a tight sheet
+func (g *Generator) packLayout(images []*ImageInfo) (*Layout, error) {
+	return g.packSizes(imageSizes(images))
+}
+
+// imageSizes returns the size of each processed image
+func imageSizes(images []*ImageInfo) []image.Point {
+	sizes := make([]image.Point, len(images))
 	for i, imgInfo := range images {
-		col := i % layout.Cols
-		row := i / layout.Cols
+		sizes[i] = image.Pt(imgInfo.Width, imgInfo.Height)
+	}
+	return sizes
+}
+
+// packSizes bin-packs rectangles of the given sizes into a tight sheet, or
+// into several pages when a single sheet would exceed --max-sheet-size
+func (g *Generator) packSizes(sizes []image.Point) (*Layout, error) {
+	margin := g.config.Margin
+
+	if g.config.MaxSheetSize <= 0 {
+		rects, rotated, width, height := packRects(sizes, g.config.Padding, g.config.AllowRotation)
+		offsetRects(rects, margin)
+		width, height = width+2*margin, height+2*margin
+
+		g.log.Debug("Packed %d sprites into %dx%d", len(sizes), width, height)
+
+		return &Layout{
+			Padding: g.config.Padding,
+			Margin:  margin,
+			Width:   width,
+			Height:  height,
+			Rects:   rects,
+			Rotated: rotated,
+		}, nil
+	}
+
+	// The margin is taken off every page before packing
+	maxSize := g.config.MaxSheetSize - 2*margin
+	if margin > 0 {
+		for i, size := range sizes {
+			if size.X > maxSize || size.Y > maxSize {
+				return nil, fmt.Errorf("sprite %d is %dx%d, too large for max-sheet-size %d with margin %d", i, size.X, size.Y, g.config.MaxSheetSize, margin)
+			}
+		}
+	}
+
+	rects, rotated, pageOf, pageSizes, err := packPages(sizes, g.config.Padding, maxSize, g.config.AllowRotation)
+	if err != nil {
+		return nil, err
+	}
+	offsetRects(rects, margin)
+	for i := range pageSizes {
+		pageSizes[i] = pageSizes[i].Add(image.Pt(2*margin, 2*margin))
+	}
+
+	g.log.Debug("Packed %d sprites into %d page(s), the first %dx%d", len(sizes), len(pageSizes), pageSizes[0].X, pageSizes[0].Y)
+
+	return &Layout{
+		Padding:   g.config.Padding,
+		Margin:    margin,
+		Width:     pageSizes[0].X,
+		Height:    pageSizes[0].Y,
+		Rects:     rects,
+		Rotated:   rotated,
+		PageOf:    pageOf,
+		PageSizes: pageSizes,
+	}, nil
+}
+
+// offsetRects moves packed rectangles inside a margin on the top and left
+func offsetRects(rects []image.Rectangle, margin int) {
+	for i := range rects {
+		rects[i] = rects[i].Add(image.Pt(margin, margin))
+	}
+}
+
+// alignedPadding returns the smallest padding (not less than the configured one)
+// that keeps every X/Y coordinate of tileWidth x tileHeight tiles divisible
+// by the auto-pad value
+func (g *Generator) alignedPadding(tileWidth, tileHeight int) int {
+	padding := g.config.Padding
+	n := g.config.AutoPad
+	if n <= 1 {
+		return padding
+	}
+
+	// Tile origins are multiples of (tile size + padding), so it is enough to
+	// round that stride up to a multiple of n on both axes
+	for (tileWidth+padding)%n != 0 || (tileHeight+padding)%n != 0 {
+		padding++
+		if padding-g.config.Padding >= n {
+			// Config.Validate rules this out for the configured tile size,
+			// but not for the largest tile of a --manifest
+			g.log.Warn("auto-pad %d cannot align %dx%d tiles; keeping padding %d", n, tileWidth, tileHeight, g.config.Padding)
+			return g.config.Padding
+		}
+	}
+
+	if padding != g.config.Padding {
+		g.log.Debug("Auto-pad: adjusted padding from %d to %d to align tiles to %d pixels", g.config.Padding, padding, n)
+	}
 
-		x := col * (layout.TileWidth + layout.Padding)
-		y := row * (layout.TileHeight + layout.Padding)
+	return padding
+}
 
-		destRect := image.Rect(x, y, x+layout.TileWidth, y+layout.TileHeight)
-		draw.Draw(spritesheet, destRect, imgInfo.Image, image.Point{}, draw.Over)
+// dedupeImages returns the layout region of each image and the images that
+// get a region of their own. With --dedupe pixel-identical images share the
+// region of the first of them; otherwise image i has region i.
+func (g *Generator) dedupeImages(images []*ImageInfo) ([]int, []*ImageInfo) {
+	regions := make([]int, len(images))
+	if !g.config.Dedupe {
+		for i := range regions {
+			regions[i] = i
+		}
+		return regions, images
+	}
+
+	var distinct []*ImageInfo
+	seen := make(map[[sha256.Size]byte]int)
+	for i, imgInfo := range images {
+		hash := hashImage(imgInfo.Image)
+		region, ok := seen[hash]
+		if !ok {
+			region = len(distinct)
+			seen[hash] = region
+			distinct = append(distinct, imgInfo)
+		}
+		regions[i] = region
+	}
+
+	g.log.Debug("Dedupe: %d sprites share %d distinct regions", len(images), len(distinct))
+
+	return regions, distinct
+}
+
+// hashImage returns the SHA-256 of an image's size and RGBA pixels
+func hashImage(img image.Image) [sha256.Size]byte {
+	bounds := img.Bounds()
+	rgba, ok := img.(*image.RGBA)
+	if !ok || rgba.Stride != 4*bounds.Dx() {
+		rgba = image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%dx%d:", bounds.Dx(), bounds.Dy())
+	h.Write(rgba.Pix[:4*bounds.Dx()*bounds.Dy()])
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// createSpritesheet creates the spritesheet image of every page and the
+// metadata. Image i is drawn in layout region regions[i]; images sharing a
+// region are drawn once and listed with the same coordinates.
+func (g *Generator) createSpritesheet(images []*ImageInfo, regions []int, layout *Layout) ([]image.Image, *metadata.SpritesheetMetadata, error) {
+	// Validated by Config.Validate
+	background, _ := g.config.BackgroundColor()
+
+	pages := make([]*image.RGBA, layout.PageCount())
+	for i := range pages {
+		size := layout.PageSize(i)
+		pages[i] = image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+		if background != nil {
+			draw.Draw(pages[i], pages[i].Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+		}
+	}
+
+	meta := g.newMetadata(layout)
+
+	// Validated by Config.Validate
+	pivotX, pivotY, hasPivot, _ := g.config.PivotFractions()
+	// The pivot marks the same point of sprites mirrored by --flip
+	if horizontal, vertical := config.FlipMode(g.config.Flip).Axes(); horizontal || vertical {
+		if horizontal {
+			pivotX = 1 - pivotX
+		}
+		if vertical {
+			pivotY = 1 - pivotY
+		}
+	}
+
+	// Place images on the spritesheet, remembering the sprite drawn in each
+	// region so that those sharing it are recorded as its aliases
+	drawn := make(map[int]int)
+	for i, imgInfo := range images {
+		region := regions[i]
+		page := layout.Page(region)
+		// Sprites smaller than their cell fill it from the top-left corner
+		size := image.Pt(imgInfo.Width, imgInfo.Height)
+		rotated := layout.IsRotated(region)
+		if rotated {
+			size = image.Pt(size.Y, size.X)
+		}
+		destRect := layout.TileRect(region)
+		destRect.Max = destRect.Min.Add(size)
+		x, y := destRect.Min.X, destRect.Min.Y
+
+		name, err := g.getSpriteName(imgInfo.Filename, imgInfo.OriginalPath, i)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		first, shared := drawn[region]
+		if !shared {
+			src := imgInfo.Image
+			if rotated {
+				src = utils.RotateClockwise(src)
+			}
+			draw.Draw(pages[page], destRect, src, image.Point{}, draw.Over)
+			if g.config.Extrude > 0 {
+				utils.ExtrudeEdges(pages[page], destRect, g.config.Extrude)
+			}
+			drawn[region] = len(meta.Sprites)
+		}
 
 		sprite := metadata.SpriteInfo{
-			Name:   g.getSpriteName(imgInfo.Filename),
-			X:      x,
-			Y:      y,
-			Width:  layout.TileWidth,
-			Height: layout.TileHeight,
-			Index:  i,
+			Name:    name,
+			X:       x,
+			Y:       y,
+			Width:   destRect.Dx(),
+			Height:  destRect.Dy(),
+			Index:   i,
+			Page:    page,
+			Rotated: rotated,
+			Source:  g.SourcePath(imgInfo.OriginalPath),
+		}
+		if hasPivot {
+			sprite.Pivot = &metadata.Pivot{X: pivotX, Y: pivotY}
+		}
+		if g.config.TrimMargin > 0 || g.letterboxes() {
+			sprite.Content = &metadata.Rect{
+				X:      imgInfo.Content.Min.X,
+				Y:      imgInfo.Content.Min.Y,
+				Width:  imgInfo.Content.Dx(),
+				Height: imgInfo.Content.Dy(),
+			}
+		}
+		// Packed sprites are placed unscaled unless the manifest sized them,
+		// so the trim offset maps sheet pixels straight back onto the source
+		if layout.Rects != nil && g.config.Trim && imgInfo.TileSize == (image.Point{}) {
+			sprite.Trimmed = true
+			sprite.SourceX = imgInfo.Source.Min.X
+			sprite.SourceY = imgInfo.Source.Min.Y
+			sprite.SourceW = imgInfo.SourceWidth
+			sprite.SourceH = imgInfo.SourceHeight
+		}
+		// Grid sprites keep the whole tile, so the trimmed area is recorded
+		// next to where Content placed it. The source area includes
+		// --trim-margin, which Content leaves out.
+		if g.config.TrimKeepTile {
+			trim := imgInfo.Source.Inset(g.config.TrimMargin)
+			sprite.Trim = &metadata.Rect{X: trim.Min.X, Y: trim.Min.Y, Width: trim.Dx(), Height: trim.Dy()}
+			sprite.SourceW = imgInfo.SourceWidth
+			sprite.SourceH = imgInfo.SourceHeight
+		}
+		if g.config.MeasureContent {
+			visible := sheetContent(imgInfo.Image, destRect.Min, rotated)
+			sprite.ContentX, sprite.ContentY = visible.Min.X, visible.Min.Y
+			sprite.ContentW, sprite.ContentH = visible.Dx(), visible.Dy()
 		}
 		meta.Sprites = append(meta.Sprites, sprite)
 
-		if g.config.Verbose {
-			fmt.Printf("Placed sprite %d: %s at (%d, %d)\n", i, sprite.Name, x, y)
+		if shared {
+			meta.Sprites[first].Aliases = append(meta.Sprites[first].Aliases, name)
+			g.log.Debug("Placed sprite %d: %s at (%d, %d), shared with %s", i, sprite.Name, x, y, meta.Sprites[first].Name)
+		} else {
+			g.log.Debug("Placed sprite %d: %s at (%d, %d)", i, sprite.Name, x, y)
+		}
+	}
+
+	if horizontal, vertical := config.FlipMode(g.config.FlipSheet).Axes(); horizontal || vertical {
+		for i, page := range pages {
+			pages[i] = utils.FlipImage(page, horizontal, vertical)
 		}
+		g.flipSprites(meta, layout)
 	}
 
-	return spritesheet, meta, nil
+	// Drawn last so the labels are not mirrored with the sheet
+	if g.config.DebugGrid {
+		drawDebugGrid(pages, meta.Sprites)
+	}
+
+	sheets := make([]image.Image, len(pages))
+	for i, page := range pages {
+		sheets[i] = page
+	}
+
+	return sheets, meta, nil
+}
+
+// sheetContent returns the bounds of the visible pixels of img drawn at
+// origin on the sheet, turned clockwise first when rotated. It is empty when
+// img has no visible pixels.
+func sheetContent(img image.Image, origin image.Point, rotated bool) image.Rectangle {
+	visible := utils.GetImageBounds(img)
+	if visible.Empty() {
+		return image.Rectangle{}
+	}
+	visible = visible.Sub(img.Bounds().Min)
+
+	// Turning clockwise moves pixel (x, y) of a w x h image to (h-1-y, x)
+	if rotated {
+		height := img.Bounds().Dy()
+		visible = image.Rect(height-visible.Max.Y, visible.Min.X, height-visible.Min.Y, visible.Max.X)
+	}
+	return visible.Add(origin)
+}
+
+// debugGridColor outlines and numbers sprite regions with --debug-grid
+var debugGridColor = color.NRGBA{R: 255, B: 255, A: 255}
+
+// drawDebugGrid outlines every sprite region and writes the index of the
+// first sprite placed there in its top-left corner
+func drawDebugGrid(pages []*image.RGBA, sprites []metadata.SpriteInfo) {
+	type region struct {
+		page int
+		rect image.Rectangle
+	}
+	labeled := make(map[region]bool)
+
+	for _, sprite := range sprites {
+		r := region{sprite.Page, image.Rect(sprite.X, sprite.Y, sprite.X+sprite.Width, sprite.Y+sprite.Height)}
+		if labeled[r] {
+			continue
+		}
+		labeled[r] = true
+
+		utils.DrawOutline(pages[r.page], r.rect, debugGridColor)
+		utils.DrawLabel(pages[r.page], r.rect, strconv.Itoa(sprite.Index), debugGridColor)
+	}
 }
 
-// getSpriteName extracts the sprite name from filename (already processed in loadImages)
-func (g *Generator) getSpriteName(filename string) string {
-	return filename
+// newMetadata returns the sheet-level metadata of a layout, without sprites.
+// Page image names are filled in when the pages are saved.
+func (g *Generator) newMetadata(layout *Layout) *metadata.SpritesheetMetadata {
+	meta := &metadata.SpritesheetMetadata{
+		Width:         layout.Width,
+		Height:        layout.Height,
+		ContentWidth:  layout.ContentWidth,
+		ContentHeight: layout.ContentHeight,
+		TileWidth:     layout.TileWidth,
+		TileHeight:    layout.TileHeight,
+		Cols:          layout.Cols,
+		Rows:          layout.Rows,
+		Padding:       layout.Padding,
+		Margin:        layout.Margin,
+		RowCols:       layout.RowCols,
+		Packed:        layout.Rects != nil,
+		Version:       metadata.Version,
+		GeneratedAt:   generatedAt().Format(time.RFC3339),
+		Sprites:       []metadata.SpriteInfo{},
+	}
+
+	if layout.PageCount() > 1 {
+		meta.Pages = make([]metadata.PageInfo, layout.PageCount())
+		for i := range meta.Pages {
+			size := layout.PageSize(i)
+			meta.Pages[i] = metadata.PageInfo{Width: size.X, Height: size.Y}
+		}
+	}
+
+	return meta
 }
 
-// saveSpritesheet saves the spritesheet to a file
+// getSpriteName builds the name of sprite index from its base name, the file
+// name already processed in loadImages and normalized by the runner, by
+// applying --name-template and then --name-prefix and --name-suffix. source
+// is the file the sprite came from; its directory is normalized like the
+// file name for {{.Dir}}.
+func (g *Generator) getSpriteName(filename, source string, index int) (string, error) {
+	name := filename
+	if g.nameTemplate != nil {
+		var b strings.Builder
+		dir := utils.NormalizeName(g.spriteDir(source), config.NameCase(g.config.NameCase), g.config.NameSanitize)
+		data := config.SpriteNameData{Name: filename, Index: index, Dir: dir}
+		if err := g.nameTemplate.Execute(&b, data); err != nil {
+			return "", fmt.Errorf("failed to name sprite %s: %w", filename, err)
+		}
+		name = b.String()
+	}
+	return g.config.NamePrefix + name + g.config.NameSuffix, nil
+}
+
+// SpriteName returns the metadata name of the sprite made from mapping when
+// it is placed at index
+func (g *Generator) SpriteName(mapping utils.FileMapping, index int) (string, error) {
+	return g.getSpriteName(mapping.SpriteName(), mapping.OriginalPath, index)
+}
+
+// SourcePath returns the path recorded as the source of a sprite made from
+// source: relative to the input directory holding it, with forward slashes,
+// or "" with --no-source-paths
+func (g *Generator) SourcePath(source string) string {
+	if g.config.NoSourcePaths || source == "" {
+		return ""
+	}
+	// A single input file holds every frame of the sheet
+	if rel, err := filepath.Rel(utils.InputRoot(source, g.config.InputDirs()), source); err == nil && rel != "." {
+		source = rel
+	} else if err == nil {
+		source = filepath.Base(source)
+	}
+	return filepath.ToSlash(source)
+}
+
+// spriteDir returns the directory of source relative to the input directory
+// holding it, with forward slashes, or "" for files directly in it or outside
+// it
+func (g *Generator) spriteDir(source string) string {
+	rel, err := filepath.Rel(utils.InputRoot(source, g.config.InputDirs()), filepath.Dir(source))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// saveSpritesheet saves the spritesheet in the format implied by the output extension
 func (g *Generator) saveSpritesheet(img image.Image, outputPath string) error {
 	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
-	}
-	defer file.Close()
+	return utils.SaveImage(img, outputPath, g.encodeOptions())
+}
 
-	if err := png.Encode(file, img); err != nil {
-		return fmt.Errorf("failed to encode PNG: %w", err)
-	}
+// writeSpritesheet writes the spritesheet image to w as a PNG
+func (g *Generator) writeSpritesheet(img image.Image, w io.Writer) error {
+	return utils.WriteImage(w, img, utils.FormatPNG, g.encodeOptions())
+}
 
-	return nil
+// encodeOptions returns how sheets are encoded. Only the sheet is written
+// with --palette; the sprites it is built from keep their own colors.
+func (g *Generator) encodeOptions() utils.EncodeOptions {
+	opts := utils.NewEncodeOptions(g.config, g.log)
+	opts.Palette = g.config.Palette
+	return opts
 }
@@ -0,0 +1,649 @@
+package spritesheet
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+	"github.com/thanhfphan/svg2sheet/internal/metadata"
+	"github.com/thanhfphan/svg2sheet/internal/utils"
+)
+
+// solidImage returns a w x h image filled with c
+func solidImage(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+// writeTestPNG saves img as <name>.png in dir and returns its file mapping
+func writeTestPNG(t *testing.T, dir, name string, img image.Image) utils.FileMapping {
+	t.Helper()
+
+	path := filepath.Join(dir, name+".png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return utils.FileMapping{PNGPath: path, OriginalPath: path}
+}
+
+// generateSheet runs the generator over mappings and returns the decoded
+// sheet with its metadata
+func generateSheet(t *testing.T, cfg config.Config, mappings []utils.FileMapping) (*image.NRGBA, *metadata.SpritesheetMetadata) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	meta, err := newTestGenerator(t, cfg).GenerateTo(context.Background(), mappings, &buf)
+	if err != nil {
+		t.Fatalf("GenerateTo: %v", err)
+	}
+
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decoding sheet: %v", err)
+	}
+
+	sheet := image.NewNRGBA(img.Bounds())
+	draw.Draw(sheet, sheet.Bounds(), img, img.Bounds().Min, draw.Src)
+	return sheet, meta
+}
+
+// spriteRect returns the sheet area of a sprite
+func spriteRect(sprite metadata.SpriteInfo) image.Rectangle {
+	return image.Rect(sprite.X, sprite.Y, sprite.X+sprite.Width, sprite.Y+sprite.Height)
+}
+
+// drawnRegions returns the number of distinct sheet areas the sprites use
+func drawnRegions(sprites []metadata.SpriteInfo) int {
+	regions := make(map[image.Rectangle]bool)
+	for _, sprite := range sprites {
+		regions[spriteRect(sprite)] = true
+	}
+	return len(regions)
+}
+
+func TestDedupe(t *testing.T) {
+	dir := t.TempDir()
+	red := color.RGBA{R: 255, A: 255}
+	mappings := []utils.FileMapping{
+		writeTestPNG(t, dir, "first", solidImage(16, 16, red)),
+		writeTestPNG(t, dir, "other", solidImage(16, 16, color.RGBA{B: 255, A: 255})),
+		writeTestPNG(t, dir, "second", solidImage(16, 16, red)),
+	}
+
+	tests := []struct {
+		name string
+		pack bool
+	}{
+		{name: "grid"},
+		{name: "packed", pack: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{Dedupe: true, Pack: tt.pack, TileWidth: 16, TileHeight: 16}
+			if !tt.pack {
+				// Three tiles would take a second row
+				cfg.Cols = 2
+			}
+			sheet, meta := generateSheet(t, cfg, mappings)
+
+			if len(meta.Sprites) != 3 {
+				t.Fatalf("got %d sprites, want 3", len(meta.Sprites))
+			}
+			first, other, second := meta.Sprites[0], meta.Sprites[1], meta.Sprites[2]
+			if spriteRect(first) != spriteRect(second) {
+				t.Errorf("duplicates are at %v and %v, want the same region", spriteRect(first), spriteRect(second))
+			}
+			if spriteRect(first) == spriteRect(other) {
+				t.Errorf("distinct sprite shares region %v with a duplicate", spriteRect(other))
+			}
+
+			// Only two tiles are laid out, so the sheet holds two sprites' worth
+			if got := drawnRegions(meta.Sprites); got != 2 {
+				t.Errorf("sprites use %d regions, want 2", got)
+			}
+			if area := sheet.Bounds().Dx() * sheet.Bounds().Dy(); area != 2*16*16 {
+				t.Errorf("sheet is %v, want room for two 16x16 tiles", sheet.Bounds())
+			}
+		})
+	}
+}
+
+func TestDedupeDisabled(t *testing.T) {
+	dir := t.TempDir()
+	red := color.RGBA{R: 255, A: 255}
+	mappings := []utils.FileMapping{
+		writeTestPNG(t, dir, "first", solidImage(16, 16, red)),
+		writeTestPNG(t, dir, "second", solidImage(16, 16, red)),
+	}
+
+	_, meta := generateSheet(t, config.Config{Cols: 8, TileWidth: 16, TileHeight: 16}, mappings)
+
+	if got := drawnRegions(meta.Sprites); got != 2 {
+		t.Errorf("sprites use %d regions without --dedupe, want 2", got)
+	}
+}
+
+func TestHashImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(40 * x), G: uint8(90 * y), B: 7, A: 255})
+		}
+	}
+
+	// The digest covers the size followed by the RGBA rows
+	h := sha256.New()
+	h.Write([]byte("3x2:"))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			c := img.RGBAAt(x, y)
+			h.Write([]byte{c.R, c.G, c.B, c.A})
+		}
+	}
+	var want [sha256.Size]byte
+	copy(want[:], h.Sum(nil))
+
+	if got := hashImage(img); got != want {
+		t.Fatalf("hashImage = %x, want %x", got, want)
+	}
+
+	// The same pixels inside a wider image, so rows have a larger stride and
+	// the bounds do not start at the origin
+	wide := image.NewRGBA(image.Rect(-4, -4, 10, 10))
+	draw.Draw(wide, image.Rect(2, 5, 5, 7), img, image.Point{}, draw.Src)
+	sub := wide.SubImage(image.Rect(2, 5, 5, 7))
+
+	// The same pixels with a matching stride but offset bounds
+	offset := image.NewRGBA(image.Rect(7, 9, 10, 11))
+	draw.Draw(offset, offset.Bounds(), img, image.Point{}, draw.Src)
+
+	// The same pixels in another image type
+	nrgba := image.NewNRGBA(img.Bounds())
+	draw.Draw(nrgba, nrgba.Bounds(), img, image.Point{}, draw.Src)
+
+	for name, other := range map[string]image.Image{"sub-image": sub, "offset bounds": offset, "NRGBA": nrgba} {
+		if got := hashImage(other); got != want {
+			t.Errorf("%s hashes to %x, want %x", name, got, want)
+		}
+	}
+
+	// Equal pixel bytes in another shape must not collide
+	reshaped := &image.RGBA{Pix: img.Pix, Stride: 2 * 4, Rect: image.Rect(0, 0, 2, 3)}
+	if hashImage(reshaped) == want {
+		t.Error("a 2x3 image with the same bytes hashes like the 3x2 one")
+	}
+}
+
+func TestPivot(t *testing.T) {
+	dir := t.TempDir()
+	mappings := []utils.FileMapping{writeTestPNG(t, dir, "hero", solidImage(16, 16, color.RGBA{G: 255, A: 255}))}
+
+	tests := []struct {
+		pivot string
+		want  string
+	}{
+		{pivot: "", want: ""},
+		{pivot: "top-left", want: `"pivot":{"x":0,"y":0}`},
+		{pivot: "bottom", want: `"pivot":{"x":0.5,"y":1}`},
+		{pivot: "0.25,0.75", want: `"pivot":{"x":0.25,"y":0.75}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pivot, func(t *testing.T) {
+			_, meta := generateSheet(t, config.Config{Cols: 1, TileWidth: 16, TileHeight: 16, Pivot: tt.pivot}, mappings)
+
+			data, err := json.Marshal(meta.Sprites[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if strings.Contains(string(data), `"pivot"`) {
+					t.Errorf("sprite without --pivot has one: %s", data)
+				}
+				return
+			}
+			if !strings.Contains(string(data), tt.want) {
+				t.Errorf("sprite %s does not contain %s", data, tt.want)
+			}
+		})
+	}
+}
+
+// gradientImage returns a w x h opaque image whose every pixel differs
+func gradientImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(5 * x), G: uint8(5 * y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func TestAllowRotation(t *testing.T) {
+	dir := t.TempDir()
+	tall := gradientImage(10, 40)
+	wide := gradientImage(40, 10)
+	mappings := []utils.FileMapping{
+		writeTestPNG(t, dir, "tall", tall),
+		writeTestPNG(t, dir, "wide", wide),
+	}
+
+	sheet, meta := generateSheet(t, config.Config{Pack: true, AllowRotation: true}, mappings)
+
+	// Turning one of the two lets them stack into a 40x20 or 20x40 sheet
+	if size := sheet.Bounds().Size(); size.X*size.Y != 800 {
+		t.Fatalf("sheet is %v, want the two sprites side by side", size)
+	}
+
+	sources := []*image.RGBA{tall, wide}
+	rotations := 0
+	for i, sprite := range meta.Sprites {
+		src := sources[i]
+		w, h := src.Bounds().Dx(), src.Bounds().Dy()
+
+		if !sprite.Rotated {
+			if sprite.Width != w || sprite.Height != h {
+				t.Errorf("%s is %dx%d on the sheet, want %dx%d", sprite.Name, sprite.Width, sprite.Height, w, h)
+			}
+			continue
+		}
+
+		rotations++
+		if sprite.Width != h || sprite.Height != w {
+			t.Errorf("rotated %s is %dx%d on the sheet, want %dx%d", sprite.Name, sprite.Width, sprite.Height, h, w)
+		}
+
+		// Clockwise, source pixel (x, y) lands at (h-1-y, x) of the turned area
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				want := color.NRGBAModel.Convert(src.RGBAAt(x, y))
+				if got := sheet.NRGBAAt(sprite.X+h-1-y, sprite.Y+x); got != want {
+					t.Fatalf("%s pixel (%d,%d) is %v on the sheet, want %v", sprite.Name, x, y, got, want)
+				}
+			}
+		}
+	}
+
+	if rotations != 1 {
+		t.Errorf("%d sprites are marked rotated, want 1", rotations)
+	}
+}
+
+// haloImage returns a 20x20 red sprite whose outer five rings fade in with
+// alpha 10, 20, 30, 40 and 50 around a 10x10 opaque center
+func haloImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			ring := min(x, y, 19-x, 19-y)
+			alpha := uint8(255)
+			if ring < 5 {
+				alpha = uint8(10 * (ring + 1))
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: 255, A: alpha})
+		}
+	}
+	return img
+}
+
+func TestTrimThreshold(t *testing.T) {
+	dir := t.TempDir()
+	mappings := []utils.FileMapping{writeTestPNG(t, dir, "halo", haloImage())}
+
+	tests := []struct {
+		threshold int
+		wantSize  int
+		wantEdge  uint8 // alpha of the sheet's top-left pixel
+	}{
+		{threshold: 0, wantSize: 20, wantEdge: 10},
+		{threshold: 9, wantSize: 20, wantEdge: 10},
+		{threshold: 10, wantSize: 18, wantEdge: 20},
+		{threshold: 25, wantSize: 16, wantEdge: 30},
+		{threshold: 50, wantSize: 10, wantEdge: 255},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.threshold), func(t *testing.T) {
+			sheet, meta := generateSheet(t, config.Config{Pack: true, Trim: true, TrimThreshold: tt.threshold}, mappings)
+
+			if size := sheet.Bounds().Size(); size != image.Pt(tt.wantSize, tt.wantSize) {
+				t.Fatalf("sheet is %v, want %dx%d", size, tt.wantSize, tt.wantSize)
+			}
+
+			// Pixels at or below the threshold only count for trimming, so
+			// the fainter rings that survive keep their alpha
+			if got := sheet.NRGBAAt(0, 0); got != (color.NRGBA{R: 255, A: tt.wantEdge}) {
+				t.Errorf("top-left pixel = %v, want red with alpha %d", got, tt.wantEdge)
+			}
+			if got := sheet.NRGBAAt(tt.wantSize/2, tt.wantSize/2); got != (color.NRGBA{R: 255, A: 255}) {
+				t.Errorf("center pixel = %v, want opaque red", got)
+			}
+
+			offset := (20 - tt.wantSize) / 2
+			sprite := meta.Sprites[0]
+			if sprite.SourceX != offset || sprite.SourceY != offset || sprite.SourceW != 20 || sprite.SourceH != 20 {
+				t.Errorf("trim offset is %d,%d in %dx%d, want %d,%d in 20x20",
+					sprite.SourceX, sprite.SourceY, sprite.SourceW, sprite.SourceH, offset, offset)
+			}
+		})
+	}
+}
+
+func TestColorKey(t *testing.T) {
+	// An opaque sprite on a magenta key background with a little noise
+	src := image.NewRGBA(image.Rect(0, 0, 12, 12))
+	for y := 0; y < 12; y++ {
+		for x := 0; x < 12; x++ {
+			src.SetRGBA(x, y, color.RGBA{R: uint8(250 + (x+y)%6), G: uint8((x * y) % 4), B: 252, A: 255})
+		}
+	}
+	blue := color.RGBA{B: 200, A: 255}
+	draw.Draw(src, image.Rect(4, 3, 8, 9), image.NewUniform(blue), image.Point{}, draw.Src)
+
+	dir := t.TempDir()
+	mappings := []utils.FileMapping{writeTestPNG(t, dir, "legacy", src)}
+
+	t.Run("grid", func(t *testing.T) {
+		sheet, _ := generateSheet(t, config.Config{Cols: 1, TileWidth: 12, TileHeight: 12, ColorKey: "#ff00ff", KeyTolerance: 8}, mappings)
+
+		for y := 0; y < 12; y++ {
+			for x := 0; x < 12; x++ {
+				want := color.NRGBA{}
+				if image.Pt(x, y).In(image.Rect(4, 3, 8, 9)) {
+					want = color.NRGBA{B: 200, A: 255}
+				}
+				if got := sheet.NRGBAAt(x, y); got != want {
+					t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, got, want)
+				}
+			}
+		}
+	})
+
+	t.Run("trimmed", func(t *testing.T) {
+		// The keyed background is trimmed away like any transparent border
+		sheet, meta := generateSheet(t, config.Config{Pack: true, Trim: true, ColorKey: "#ff00ff", KeyTolerance: 8}, mappings)
+
+		if size := sheet.Bounds().Size(); size != image.Pt(4, 6) {
+			t.Fatalf("sheet is %v, want the 4x6 sprite", size)
+		}
+		if got := sheet.NRGBAAt(0, 0); got != (color.NRGBA{B: 200, A: 255}) {
+			t.Errorf("top-left pixel = %v, want the sprite's blue", got)
+		}
+		if sprite := meta.Sprites[0]; sprite.SourceX != 4 || sprite.SourceY != 3 {
+			t.Errorf("trim offset = %d,%d, want 4,3", sprite.SourceX, sprite.SourceY)
+		}
+	})
+
+	t.Run("tolerance too low", func(t *testing.T) {
+		sheet, _ := generateSheet(t, config.Config{Cols: 1, TileWidth: 12, TileHeight: 12, ColorKey: "#ff00ff"}, mappings)
+
+		// Only exact matches are keyed, and the noise leaves none
+		if got := sheet.NRGBAAt(11, 0); got.A != 255 {
+			t.Errorf("off-key pixel = %v, want it kept opaque", got)
+		}
+	})
+}
+
+func TestOpacityAndTint(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	src.SetNRGBA(0, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	src.SetNRGBA(1, 0, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+
+	dir := t.TempDir()
+	mappings := []utils.FileMapping{writeTestPNG(t, dir, "icon", src)}
+
+	tests := []struct {
+		name    string
+		opacity float64
+		tint    string
+		want    []color.NRGBA // adjusted sprite pixels before they are drawn
+	}{
+		{name: "opacity", opacity: 0.5, want: []color.NRGBA{{R: 255, G: 255, B: 255, A: 128}, {R: 200, G: 100, B: 50, A: 128}}},
+		{name: "tint", tint: "#00ff80", want: []color.NRGBA{{G: 255, B: 128, A: 255}, {G: 100, B: 25, A: 255}}},
+		{name: "both", opacity: 0.2, tint: "#ff0000", want: []color.NRGBA{{R: 255, A: 51}, {R: 200, A: 51}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sheet, _ := generateSheet(t, config.Config{Pack: true, Opacity: tt.opacity, Tint: tt.tint}, mappings)
+
+			for x, want := range tt.want {
+				// The sheet stores premultiplied alpha, which can round the
+				// color of translucent pixels
+				want = color.NRGBAModel.Convert(color.RGBAModel.Convert(want)).(color.NRGBA)
+				if got := sheet.NRGBAAt(x, 0); got != want {
+					t.Errorf("pixel %d = %v, want %v", x, got, want)
+				}
+			}
+		})
+	}
+}
+
+// marginImage returns a w x h transparent image with an opaque gradient over
+// content
+func marginImage(w, h int, content image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, content, gradientImage(content.Dx(), content.Dy()), image.Point{}, draw.Src)
+	return img
+}
+
+func TestSheetContent(t *testing.T) {
+	// A 10x20 image drawn at (100, 50) with pixels only in (2,3)-(6,15)
+	img := marginImage(10, 20, image.Rect(2, 3, 6, 15))
+	origin := image.Pt(100, 50)
+
+	tests := []struct {
+		name    string
+		img     image.Image
+		rotated bool
+		want    image.Rectangle
+	}{
+		{name: "unrotated", img: img, want: image.Rect(102, 53, 106, 65)},
+		// Turned clockwise into 20x10, rows 3-15 become columns 20-15 to 20-3
+		{name: "rotated", img: img, rotated: true, want: image.Rect(105, 52, 117, 56)},
+		{name: "offset bounds", img: img.SubImage(image.Rect(1, 1, 10, 20)), want: image.Rect(101, 52, 105, 64)},
+		{name: "offset bounds rotated", img: img.SubImage(image.Rect(1, 1, 10, 20)), rotated: true, want: image.Rect(105, 51, 117, 55)},
+		{name: "transparent", img: image.NewRGBA(image.Rect(0, 0, 10, 20)), want: image.Rectangle{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sheetContent(tt.img, origin, tt.rotated); got != tt.want {
+				t.Errorf("sheetContent = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMeasureContent(t *testing.T) {
+	dir := t.TempDir()
+	mappings := []utils.FileMapping{
+		writeTestPNG(t, dir, "tall", marginImage(10, 40, image.Rect(1, 4, 7, 33))),
+		writeTestPNG(t, dir, "wide", marginImage(40, 10, image.Rect(5, 2, 30, 9))),
+	}
+
+	for _, rotation := range []bool{false, true} {
+		t.Run("rotation "+strconv.FormatBool(rotation), func(t *testing.T) {
+			sheet, meta := generateSheet(t, config.Config{
+				Pack:           true,
+				AllowRotation:  rotation,
+				MeasureContent: true,
+			}, mappings)
+
+			rotations := 0
+			for _, sprite := range meta.Sprites {
+				if sprite.Rotated {
+					rotations++
+				}
+
+				// The recorded bounds are exactly the visible pixels of the sprite area
+				var want image.Rectangle
+				area := spriteRect(sprite)
+				for y := area.Min.Y; y < area.Max.Y; y++ {
+					for x := area.Min.X; x < area.Max.X; x++ {
+						if sheet.NRGBAAt(x, y).A > 0 {
+							want = want.Union(image.Rect(x, y, x+1, y+1))
+						}
+					}
+				}
+				got := image.Rect(sprite.ContentX, sprite.ContentY, sprite.ContentX+sprite.ContentW, sprite.ContentY+sprite.ContentH)
+				if got != want {
+					t.Errorf("%s content is %v, want %v", sprite.Name, got, want)
+				}
+			}
+
+			if wantRotations := map[bool]int{false: 0, true: 1}[rotation]; rotations != wantRotations {
+				t.Errorf("%d sprites are rotated, want %d", rotations, wantRotations)
+			}
+		})
+	}
+}
+
+func TestFixedGridLayout(t *testing.T) {
+	tests := []struct {
+		name       string
+		sprites    int
+		wantWidth  int
+		wantHeight int
+		wantErr    bool
+	}{
+		{name: "exact fit", sprites: 6, wantWidth: 3*16 + 2*2, wantHeight: 2*16 + 2},
+		{name: "fewer sprites than cells", sprites: 4, wantWidth: 3*16 + 2*2, wantHeight: 2*16 + 2},
+		{name: "one sprite", sprites: 1, wantWidth: 3*16 + 2*2, wantHeight: 2*16 + 2},
+		{name: "more sprites than cells", sprites: 7, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGenerator(t, config.Config{Cols: 3, Rows: 2, Padding: 2})
+
+			sizes := make([]image.Point, tt.sprites)
+			for i := range sizes {
+				sizes[i] = image.Pt(16, 16)
+			}
+
+			layout, err := g.calculateLayout(sizes)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("calculateLayout = %+v, want an error for %d sprites in 6 cells", layout, tt.sprites)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("calculateLayout: %v", err)
+			}
+
+			// The grid keeps its configured shape whatever the sprite count
+			if layout.Cols != 3 || layout.Rows != 2 {
+				t.Errorf("grid is %dx%d, want 3x2", layout.Cols, layout.Rows)
+			}
+			if layout.Width != tt.wantWidth || layout.Height != tt.wantHeight {
+				t.Errorf("sheet is %dx%d, want %dx%d", layout.Width, layout.Height, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestFixedGridFillsRowByRow(t *testing.T) {
+	dir := t.TempDir()
+	var mappings []utils.FileMapping
+	for _, name := range []string{"a", "b", "c", "d"} {
+		mappings = append(mappings, writeTestPNG(t, dir, name, solidImage(8, 8, color.White)))
+	}
+
+	sheet, meta := generateSheet(t, config.Config{Cols: 3, Rows: 2}, mappings)
+
+	tile := meta.Sprites[0].Width
+	if size := sheet.Bounds().Size(); size != image.Pt(3*tile, 2*tile) {
+		t.Fatalf("sheet is %v, want 3x2 tiles of %d", size, tile)
+	}
+
+	want := []image.Point{{0, 0}, {1, 0}, {2, 0}, {0, 1}}
+	for i, sprite := range meta.Sprites {
+		if got := image.Pt(sprite.X, sprite.Y); got != want[i].Mul(tile) {
+			t.Errorf("%s is at %v, want %v", sprite.Name, got, want[i].Mul(tile))
+		}
+	}
+
+	// The cells after the last sprite stay empty
+	for y := tile; y < 2*tile; y++ {
+		for x := tile; x < 3*tile; x++ {
+			if a := sheet.NRGBAAt(x, y).A; a != 0 {
+				t.Fatalf("empty cell pixel (%d,%d) has alpha %d", x, y, a)
+			}
+		}
+	}
+}
+
+func TestSourcePath(t *testing.T) {
+	icons := filepath.Join("assets", "icons")
+	brand := filepath.Join("assets", "brand")
+
+	tests := []struct {
+		name   string
+		cfg    config.Config
+		source string
+		want   string
+	}{
+		{
+			name:   "directly in the input",
+			cfg:    config.Config{Input: icons},
+			source: filepath.Join(icons, "play.svg"),
+			want:   "play.svg",
+		},
+		{
+			name:   "in a subdirectory",
+			cfg:    config.Config{Input: icons},
+			source: filepath.Join(icons, "ui", "play.svg"),
+			want:   "ui/play.svg",
+		},
+		{
+			name:   "in another input directory",
+			cfg:    config.Config{Input: icons, ExtraInputs: []string{brand}},
+			source: filepath.Join(brand, "logo.svg"),
+			want:   "logo.svg",
+		},
+		{
+			name:   "single input file",
+			cfg:    config.Config{Input: filepath.Join(icons, "spinner.svg")},
+			source: filepath.Join(icons, "spinner.svg"),
+			want:   "spinner.svg",
+		},
+		{
+			name:   "no source paths",
+			cfg:    config.Config{Input: icons, NoSourcePaths: true},
+			source: filepath.Join(icons, "ui", "play.svg"),
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGenerator(t, tt.cfg)
+			if got := g.SourcePath(tt.source); got != tt.want {
+				t.Errorf("SourcePath(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package spritesheet
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+)
+
+// packWidthSteps is the number of bin widths tried when packing
+const packWidthSteps = 16
+
+// maxRectsBin is a fixed-size bin filled with the MaxRects algorithm. It keeps
+// the list of maximal free rectangles and places each new rectangle in the
+// free area that keeps it closest to the top-left corner.
+type maxRectsBin struct {
+	free []image.Rectangle
+}
+
+// newMaxRectsBin creates an empty bin of the given size
+func newMaxRectsBin(width, height int) *maxRectsBin {
+	return &maxRectsBin{
+		free: []image.Rectangle{image.Rect(0, 0, width, height)},
+	}
+}
+
+// insert places a width x height rectangle and returns its position, or false
+// if no free area is large enough. With allowRotation the rectangle may be
+// placed turned 90 degrees instead when that scores better, which the second
+// result reports.
+func (b *maxRectsBin) insert(width, height int, allowRotation bool) (image.Point, bool, bool) {
+	best := image.Rectangle{}
+	bestY, bestX := math.MaxInt, math.MaxInt
+	found, rotated := false, false
+
+	// Bottom-left heuristic: lowest resulting bottom edge, then leftmost.
+	// Ties keep the unrotated placement.
+	try := func(width, height int, rotate bool) {
+		for _, free := range b.free {
+			if free.Dx() < width || free.Dy() < height {
+				continue
+			}
+			y, x := free.Min.Y+height, free.Min.X
+			if y < bestY || (y == bestY && x < bestX) {
+				best = image.Rect(free.Min.X, free.Min.Y, free.Min.X+width, free.Min.Y+height)
+				bestY, bestX = y, x
+				found, rotated = true, rotate
+			}
+		}
+	}
+
+	try(width, height, false)
+	if allowRotation && width != height {
+		try(height, width, true)
+	}
+
+	if !found {
+		return image.Point{}, false, false
+	}
+
+	b.place(best)
+	return best.Min, rotated, true
+}
+
+// place removes a used rectangle from the free areas, splitting every free
+// rectangle it overlaps into the maximal rectangles around it
+func (b *maxRectsBin) place(used image.Rectangle) {
+	var free []image.Rectangle
+
+	for _, r := range b.free {
+		if !r.Overlaps(used) {
+			free = append(free, r)
+			continue
+		}
+
+		if used.Min.X > r.Min.X {
+			free = append(free, image.Rect(r.Min.X, r.Min.Y, used.Min.X, r.Max.Y))
+		}
+		if used.Max.X < r.Max.X {
+			free = append(free, image.Rect(used.Max.X, r.Min.Y, r.Max.X, r.Max.Y))
+		}
+		if used.Min.Y > r.Min.Y {
+			free = append(free, image.Rect(r.Min.X, r.Min.Y, r.Max.X, used.Min.Y))
+		}
+		if used.Max.Y < r.Max.Y {
+			free = append(free, image.Rect(r.Min.X, used.Max.Y, r.Max.X, r.Max.Y))
+		}
+	}
+
+	b.free = pruneContained(free)
+}
+
+// pruneContained drops free rectangles that lie entirely inside another one
+func pruneContained(rects []image.Rectangle) []image.Rectangle {
+	pruned := make([]image.Rectangle, 0, len(rects))
+
+	for i, r := range rects {
+		contained := false
+		for j, other := range rects {
+			if i == j || !r.In(other) {
+				continue
+			}
+			// Keep the first of two identical rectangles
+			if r == other && i < j {
+				continue
+			}
+			contained = true
+			break
+		}
+		if !contained {
+			pruned = append(pruned, r)
+		}
+	}
+
+	return pruned
+}
+
+// packRects packs rectangles of the given sizes with padding pixels between
+// them and returns their positions in input order along with the atlas size.
+// Several bin widths are tried and the one giving the smallest area wins.
+// With allowRotation rectangles may be turned 90 degrees; rotated reports
+// which were, and their positions have width and height swapped.
+func packRects(sizes []image.Point, padding int, allowRotation bool) ([]image.Rectangle, []bool, int, int) {
+	if len(sizes) == 0 {
+		return nil, nil, 0, 0
+	}
+
+	order := packOrder(sizes)
+
+	// Turning sprites does not always pay off, so packings without rotation
+	// compete too and win ties
+	rotations := []bool{false}
+	if allowRotation {
+		rotations = append(rotations, true)
+	}
+
+	var best []image.Rectangle
+	var bestRotated []bool
+	bestWidth, bestHeight := 0, 0
+
+	for _, rotate := range rotations {
+		minWidth, totalHeight := packBounds(sizes, padding, rotate)
+		step := minWidth / packWidthSteps
+		if step < 1 {
+			step = 1
+		}
+
+		for i := 0; i < packWidthSteps; i++ {
+			binWidth := minWidth + i*step
+
+			rects, rotated, width, height, ok := packInto(sizes, order, padding, binWidth, totalHeight, rotate)
+			if !ok {
+				continue
+			}
+
+			if best == nil || width*height < bestWidth*bestHeight ||
+				(width*height == bestWidth*bestHeight && abs(width-height) < abs(bestWidth-bestHeight)) {
+				best, bestRotated, bestWidth, bestHeight = rects, rotated, width, height
+			}
+		}
+	}
+
+	return best, bestRotated, bestWidth, bestHeight
+}
+
+// packBounds returns the narrowest bin width worth trying and a bin height
+// that always fits every rectangle. Every rectangle reserves padding on its
+// right and bottom edge; the padding after the last column and row is cut
+// off at the end.
+func packBounds(sizes []image.Point, padding int, rotate bool) (int, int) {
+	maxWidth, totalHeight, area := 0, 0, 0
+	for _, size := range sizes {
+		w, h := size.X+padding, size.Y+padding
+		if rotate && h < w {
+			// The narrower side can always be turned to face the bin width
+			w, h = h, w
+		}
+		if w > maxWidth {
+			maxWidth = w
+		}
+		totalHeight += h
+		area += w * h
+	}
+
+	minWidth := int(math.Ceil(math.Sqrt(float64(area))))
+	if minWidth < maxWidth {
+		minWidth = maxWidth
+	}
+	return minWidth, totalHeight
+}
+
+// packPages packs rectangles like packRects, spreading them over as many
+// pages of at most maxSize x maxSize as needed. It returns each rectangle's
+// position within its page, which were rotated, the page of each rectangle
+// and the size of each page. A single page is returned with nil page indexes
+// when everything fits.
+func packPages(sizes []image.Point, padding, maxSize int, allowRotation bool) ([]image.Rectangle, []bool, []int, []image.Point, error) {
+	for i, size := range sizes {
+		if size.X > maxSize || size.Y > maxSize {
+			return nil, nil, nil, nil, fmt.Errorf("sprite %d is %dx%d, larger than the maximum sheet size %d", i, size.X, size.Y, maxSize)
+		}
+	}
+
+	rects, rotated, width, height := packRects(sizes, padding, allowRotation)
+	if width <= maxSize && height <= maxSize {
+		return rects, rotated, nil, []image.Point{image.Pt(width, height)}, nil
+	}
+
+	// Bins reserve room for the trailing padding, which is cut off again
+	var bins []*maxRectsBin
+	var pageSizes []image.Point
+	rects = make([]image.Rectangle, len(sizes))
+	rotated = make([]bool, len(sizes))
+	pages := make([]int, len(sizes))
+
+	for _, index := range packOrder(sizes) {
+		size := sizes[index]
+
+		// Fill earlier pages first so later pages stay as small as possible
+		page := -1
+		var pos image.Point
+		var turned bool
+		for p, bin := range bins {
+			if at, rotate, ok := bin.insert(size.X+padding, size.Y+padding, allowRotation); ok {
+				page, pos, turned = p, at, rotate
+				break
+			}
+		}
+		if page < 0 {
+			bin := newMaxRectsBin(maxSize+padding, maxSize+padding)
+			// Always fits: the size was checked against maxSize above
+			pos, turned, _ = bin.insert(size.X+padding, size.Y+padding, allowRotation)
+			bins = append(bins, bin)
+			pageSizes = append(pageSizes, image.Point{})
+			page = len(bins) - 1
+		}
+
+		if turned {
+			size = image.Pt(size.Y, size.X)
+		}
+		rects[index] = image.Rectangle{Min: pos, Max: pos.Add(size)}
+		rotated[index] = turned
+		pages[index] = page
+		pageSizes[page].X = max(pageSizes[page].X, rects[index].Max.X)
+		pageSizes[page].Y = max(pageSizes[page].Y, rects[index].Max.Y)
+	}
+
+	return rects, rotated, pages, pageSizes, nil
+}
+
+// packOrder returns the rectangle indexes in placement order: large
+// rectangles first, with ties keeping input order for stable output
+func packOrder(sizes []image.Point) []int {
+	order := make([]int, len(sizes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := sizes[order[i]], sizes[order[j]]
+		if a.Y != b.Y {
+			return a.Y > b.Y
+		}
+		return a.X > b.X
+	})
+	return order
+}
+
+// packInto packs the rectangles into a single bin of the given size and
+// returns their positions and rotations along with the extent actually used
+func packInto(sizes []image.Point, order []int, padding, binWidth, binHeight int, allowRotation bool) ([]image.Rectangle, []bool, int, int, bool) {
+	bin := newMaxRectsBin(binWidth, binHeight)
+	rects := make([]image.Rectangle, len(sizes))
+	rotated := make([]bool, len(sizes))
+	width, height := 0, 0
+
+	for _, index := range order {
+		size := sizes[index]
+		pos, turned, ok := bin.insert(size.X+padding, size.Y+padding, allowRotation)
+		if !ok {
+			return nil, nil, 0, 0, false
+		}
+
+		if turned {
+			size = image.Pt(size.Y, size.X)
+		}
+		rects[index] = image.Rectangle{Min: pos, Max: pos.Add(size)}
+		rotated[index] = turned
+		if rects[index].Max.X > width {
+			width = rects[index].Max.X
+		}
+		if rects[index].Max.Y > height {
+			height = rects[index].Max.Y
+		}
+	}
+
+	return rects, rotated, width, height, true
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,118 @@
+package spritesheet
+
+import (
+	"image"
+	"testing"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+	"github.com/thanhfphan/svg2sheet/internal/logging"
+)
+
+// newTestGenerator returns a generator for cfg with the defaults filled in
+func newTestGenerator(t *testing.T, cfg config.Config) *Generator {
+	t.Helper()
+	cfg.SetDefaults()
+	return NewGenerator(&cfg, logging.Discard())
+}
+
+// tooClose reports whether a and b overlap or leave less than padding
+// pixels between them
+func tooClose(a, b image.Rectangle, padding int) bool {
+	pad := image.Pt(padding, padding)
+	return image.Rectangle{Min: a.Min, Max: a.Max.Add(pad)}.Overlaps(b) ||
+		image.Rectangle{Min: b.Min, Max: b.Max.Add(pad)}.Overlaps(a)
+}
+
+func TestPackSizes(t *testing.T) {
+	varied := []image.Point{
+		{64, 64}, {32, 16}, {16, 48}, {100, 20}, {7, 7},
+		{50, 50}, {1, 1}, {33, 65}, {12, 90}, {64, 64},
+	}
+
+	tests := []struct {
+		name     string
+		sizes    []image.Point
+		padding  int
+		margin   int
+		rotation bool
+	}{
+		{name: "single", sizes: []image.Point{{40, 30}}},
+		{name: "equal sizes", sizes: []image.Point{{32, 32}, {32, 32}, {32, 32}, {32, 32}, {32, 32}}},
+		{name: "varied sizes", sizes: varied},
+		{name: "varied sizes with padding", sizes: varied, padding: 3},
+		{name: "varied sizes with padding and margin", sizes: varied, padding: 2, margin: 5},
+		{name: "varied sizes with rotation", sizes: varied, padding: 1, rotation: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGenerator(t, config.Config{
+				Pack:          true,
+				Padding:       tt.padding,
+				Margin:        tt.margin,
+				AllowRotation: tt.rotation,
+			})
+
+			layout, err := g.packSizes(tt.sizes)
+			if err != nil {
+				t.Fatalf("packSizes: %v", err)
+			}
+			if len(layout.Rects) != len(tt.sizes) {
+				t.Fatalf("got %d rects, want %d", len(layout.Rects), len(tt.sizes))
+			}
+
+			inner := image.Rect(tt.margin, tt.margin, layout.Width-tt.margin, layout.Height-tt.margin)
+			for i, r := range layout.Rects {
+				want := tt.sizes[i]
+				if layout.Rotated != nil && layout.Rotated[i] {
+					want = image.Pt(want.Y, want.X)
+				}
+				if r.Size() != want {
+					t.Errorf("rect %d is %v, want size %v", i, r, want)
+				}
+				if !r.In(inner) {
+					t.Errorf("rect %d %v is outside %v", i, r, inner)
+				}
+
+				for j := i + 1; j < len(layout.Rects); j++ {
+					if tooClose(r, layout.Rects[j], tt.padding) {
+						t.Errorf("rect %d %v and rect %d %v are closer than padding %d", i, r, j, layout.Rects[j], tt.padding)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPackSizesPages(t *testing.T) {
+	sizes := []image.Point{{60, 60}, {60, 60}, {60, 60}, {30, 30}, {30, 30}}
+
+	g := newTestGenerator(t, config.Config{Pack: true, Padding: 2, MaxSheetSize: 100})
+
+	layout, err := g.packSizes(sizes)
+	if err != nil {
+		t.Fatalf("packSizes: %v", err)
+	}
+	if layout.PageCount() < 2 {
+		t.Fatalf("got %d pages, want the sprites split over several", layout.PageCount())
+	}
+
+	for i, r := range layout.Rects {
+		page := layout.PageSizes[layout.PageOf[i]]
+		if page.X > 100 || page.Y > 100 {
+			t.Errorf("page %d is %v, larger than max-sheet-size", layout.PageOf[i], page)
+		}
+		if !r.In(image.Rectangle{Max: page}) {
+			t.Errorf("rect %d %v is outside page %v", i, r, page)
+		}
+		for j := i + 1; j < len(layout.Rects); j++ {
+			if layout.PageOf[j] == layout.PageOf[i] && tooClose(r, layout.Rects[j], 2) {
+				t.Errorf("rect %d %v and rect %d %v on page %d are closer than padding", i, r, j, layout.Rects[j], layout.PageOf[i])
+			}
+		}
+	}
+
+	if _, err := g.packSizes([]image.Point{{120, 10}}); err == nil {
+		t.Error("packSizes accepted a sprite wider than max-sheet-size")
+	}
+}
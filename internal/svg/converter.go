@@ -1,51 +1,258 @@
 package svg
 
 import (
+	"context"
 	"fmt"
 	"image"
+	_ "image/jpeg" // decoders for decodeRendered
+	_ "image/png"
+	"os"
+	"time"
 
 	"github.com/thanhfphan/svg2sheet/internal/config"
+	"github.com/thanhfphan/svg2sheet/internal/logging"
+	"github.com/thanhfphan/svg2sheet/internal/utils"
+	_ "golang.org/x/image/webp"
 )
 
+// AutoConverterOrder lists the backends tried by --converter auto, best
+// rendering quality first
+var AutoConverterOrder = []config.ConverterType{
+	config.ConverterRSVG,
+	config.ConverterInkscape,
+	config.ConverterRod,
+	config.ConverterOkSVG,
+}
+
 // Converter handles SVG to PNG conversion using pluggable backends
 type Converter struct {
-	config   *config.Config
-	backend  SVGConverter
-	registry *ConverterRegistry
+	config        *config.Config
+	converterType config.ConverterType // resolved backend, never auto
+	backend       SVGConverter
+	registry      *ConverterRegistry
+	viewBox       string // --viewbox in attribute form, empty when unset
 }
 
-// NewConverter creates a new SVG converter with the specified backend
-func NewConverter(cfg *config.Config) (*Converter, error) {
+// NewConverter creates a new SVG converter with the specified backend that
+// reports through log
+func NewConverter(cfg *config.Config, log logging.Logger) (*Converter, error) {
+	viewBox, err := cfg.ViewBoxAttr()
+	if err != nil {
+		return nil, err
+	}
+
 	registry := NewConverterRegistry()
-	options := NewConversionOptions(cfg)
+	options := NewConversionOptions(cfg, log)
 
-	// Create the specified converter backend
 	converterType := config.ConverterType(cfg.Converter)
+	if converterType == config.ConverterAuto {
+		resolved, err := resolveAutoConverter(registry, options)
+		if err != nil {
+			return nil, err
+		}
+		converterType = resolved
+
+		log.Debug("Auto-selected %s converter", converterType)
+	}
+
+	// Create the specified converter backend
 	backend, err := registry.Create(converterType, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create %s converter: %w", cfg.Converter, err)
+		return nil, fmt.Errorf("failed to create %s converter: %w", converterType, err)
 	}
 
 	return &Converter{
-		config:   cfg,
-		backend:  backend,
-		registry: registry,
+		config:        cfg,
+		converterType: converterType,
+		backend:       backend,
+		registry:      registry,
+		viewBox:       viewBox,
 	}, nil
 }
 
+// resolveAutoConverter returns the first backend in AutoConverterOrder that
+// is available on the system
+func resolveAutoConverter(registry *ConverterRegistry, opts *ConversionOptions) (config.ConverterType, error) {
+	for _, converterType := range AutoConverterOrder {
+		info, err := registry.GetConverterInfo(converterType, opts)
+		if err == nil && info.Available {
+			return converterType, nil
+		}
+	}
+
+	return "", fmt.Errorf("no converter backend is available")
+}
+
 // ConvertFile converts a single SVG file to PNG using the configured backend
-func (c *Converter) ConvertFile(inputPath, outputPath string) error {
-	return c.backend.ConvertFile(inputPath, outputPath)
+func (c *Converter) ConvertFile(ctx context.Context, inputPath, outputPath string) error {
+	if c.preprocesses() {
+		prepared, err := c.preprocessFile(inputPath)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(prepared)
+		inputPath = prepared
+	}
+	return c.backend.ConvertFile(ctx, inputPath, outputPath)
+}
+
+// ConvertFiles converts the SVG at each mapping's OriginalPath to its PNGPath,
+// in one batch when the backend supports it and file by file otherwise. The
+// Converter field of each mapping is set to the backend that rendered it.
+// With --skip-errors files are always converted one by one, and a file that
+// fails has its Err field set instead of aborting the batch. progress, when
+// not nil, is called with each file's path before it is converted.
+func (c *Converter) ConvertFiles(ctx context.Context, mappings []utils.FileMapping, progress func(path string)) error {
+	if batch, ok := c.backend.(BatchConverter); ok && !c.config.SkipErrors {
+		return c.convertBatch(ctx, batch, mappings, progress)
+	}
+
+	for i := range mappings {
+		if progress != nil {
+			progress(mappings[i].OriginalPath)
+		}
+		if err := c.ConvertFile(ctx, mappings[i].OriginalPath, mappings[i].PNGPath); err != nil {
+			if ctx.Err() != nil || !c.config.SkipErrors {
+				return fmt.Errorf("failed to convert %s: %w", mappings[i].OriginalPath, err)
+			}
+			mappings[i].Err = err
+		}
+	}
+
+	return nil
+}
+
+// convertBatch converts the mappings with a batch-capable backend. With
+// --sanitize or --viewbox the backend is given pre-processed copies of the
+// SVGs, and progress still reports the original paths.
+func (c *Converter) convertBatch(ctx context.Context, batch BatchConverter, mappings []utils.FileMapping, progress func(path string)) error {
+	if !c.preprocesses() {
+		return batch.ConvertFiles(ctx, mappings, progress)
+	}
+
+	prepared := make([]utils.FileMapping, len(mappings))
+	originals := make(map[string]string, len(mappings))
+	for i, mapping := range mappings {
+		path, err := c.preprocessFile(mapping.OriginalPath)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(path)
+
+		mapping.OriginalPath = path
+		prepared[i] = mapping
+		originals[path] = mappings[i].OriginalPath
+	}
+
+	return batch.ConvertFiles(ctx, prepared, func(path string) {
+		if progress != nil {
+			progress(originals[path])
+		}
+	})
+}
+
+// ConvertFrames renders one frame of the animated SVG at inputPath to each
+// output path, interval apart in animation time. Only backends that play
+// animations support it.
+func (c *Converter) ConvertFrames(ctx context.Context, inputPath string, interval time.Duration, outputPaths []string) error {
+	frames, ok := c.backend.(FrameConverter)
+	if !ok {
+		return fmt.Errorf("the %s converter cannot render animation frames", c.converterType)
+	}
+	if c.preprocesses() {
+		prepared, err := c.preprocessFile(inputPath)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(prepared)
+		inputPath = prepared
+	}
+	return frames.ConvertFrames(ctx, inputPath, interval, outputPaths)
 }
 
 // ConvertToImage converts SVG data to an image.Image using the configured backend
-func (c *Converter) ConvertToImage(svgData []byte) (image.Image, error) {
-	return c.backend.ConvertToImage(svgData)
+func (c *Converter) ConvertToImage(ctx context.Context, svgData []byte) (image.Image, error) {
+	if c.preprocesses() {
+		prepared, err := c.preprocess(svgData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare SVG: %w", err)
+		}
+		svgData = prepared
+	}
+	return c.backend.ConvertToImage(ctx, svgData)
 }
 
 // GetImageDimensions returns the dimensions of an SVG file using the configured backend
-func (c *Converter) GetImageDimensions(svgPath string) (int, int, error) {
-	return c.backend.GetImageDimensions(svgPath)
+func (c *Converter) GetImageDimensions(ctx context.Context, svgPath string) (int, int, error) {
+	// Only a given viewBox can change the size; sanitizing never does
+	if c.viewBox != "" {
+		prepared, err := c.preprocessFile(svgPath)
+		if err != nil {
+			return 0, 0, err
+		}
+		defer os.Remove(prepared)
+		svgPath = prepared
+	}
+	return c.backend.GetImageDimensions(ctx, svgPath)
+}
+
+// preprocesses reports whether SVGs are changed before they reach the
+// backend, by --sanitize or --viewbox
+func (c *Converter) preprocesses() bool {
+	return c.config.Sanitize || c.viewBox != ""
+}
+
+// preprocess applies --sanitize and then --viewbox to SVG data, the shared
+// step every backend renders through
+func (c *Converter) preprocess(data []byte) ([]byte, error) {
+	var err error
+	if c.config.Sanitize {
+		if data, err = SanitizeSVG(data); err != nil {
+			return nil, err
+		}
+	}
+	if c.viewBox != "" {
+		if data, err = SetViewBox(data, c.viewBox); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// preprocessFile writes a pre-processed copy of the SVG file at path to a
+// temporary file and returns its path. The caller removes the copy.
+func (c *Converter) preprocessFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read SVG file: %w", err)
+	}
+
+	prepared, err := c.preprocess(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare %s: %w", path, err)
+	}
+
+	tempPath, err := utils.CreateTempFile(".svg")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(tempPath, prepared, 0644); err != nil {
+		os.Remove(tempPath)
+		return "", fmt.Errorf("failed to write prepared SVG: %w", err)
+	}
+
+	return tempPath, nil
+}
+
+// Close releases resources held by the configured backend
+func (c *Converter) Close() error {
+	return c.backend.Close()
+}
+
+// Type returns the configured backend, with auto resolved to the backend it
+// selected
+func (c *Converter) Type() config.ConverterType {
+	return c.converterType
 }
 
 // GetRegistry returns the converter registry for advanced operations
@@ -57,3 +264,38 @@ func (c *Converter) GetRegistry() *ConverterRegistry {
 func (c *Converter) GetBackend() SVGConverter {
 	return c.backend
 }
+
+// decodeRendered reads back the image an external tool rendered to path.
+// It is decoded by its content rather than its extension, so a tool may
+// write PNG, JPEG or WebP intermediates regardless of the final output
+// format.
+func decodeRendered(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rendered image: %w", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode rendered image: %w", err)
+	}
+
+	return img, nil
+}
+
+// convertViaImage renders an SVG file through the backend's ConvertToImage and
+// encodes the result in-process, for backends that can only write PNG directly
+func convertViaImage(ctx context.Context, backend SVGConverter, inputPath, outputPath string, opts utils.EncodeOptions) error {
+	svgData, err := os.ReadFile(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read SVG file: %w", err)
+	}
+
+	img, err := backend.ConvertToImage(ctx, svgData)
+	if err != nil {
+		return fmt.Errorf("failed to convert SVG to image: %w", err)
+	}
+
+	return utils.SaveImage(img, outputPath, opts)
+}
@@ -0,0 +1,65 @@
+package svg
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"github.com/thanhfphan/svg2sheet/internal/logging"
+)
+
+func TestBackendsAgreeOnSize(t *testing.T) {
+	svgs := []struct {
+		name string
+		svg  string
+		want image.Point
+	}{
+		{
+			name: "viewBox only",
+			svg:  `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 48 24"><rect width="48" height="24" fill="red"/></svg>`,
+			want: image.Pt(48, 24),
+		},
+		{
+			name: "no size at all",
+			svg:  `<svg xmlns="http://www.w3.org/2000/svg"><circle cx="20" cy="20" r="10" fill="blue"/></svg>`,
+			want: image.Pt(DefaultSVGSize, DefaultSVGSize),
+		},
+		{
+			name: "percentages of the viewBox",
+			svg:  `<svg xmlns="http://www.w3.org/2000/svg" width="50%" height="50%" viewBox="0 0 64 32"><rect width="64" height="32"/></svg>`,
+			want: image.Pt(32, 16),
+		},
+	}
+
+	backends := []struct {
+		name string
+		new  func(*ConversionOptions) SVGConverter
+	}{
+		{name: "oksvg", new: NewOkSVGConverter},
+		{name: "rsvg", new: NewRSVGConverter},
+		{name: "inkscape", new: NewInkscapeConverter},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			c := backend.new(&ConversionOptions{Scale: 2, DPI: DefaultDPI, Normalize: true, Logger: logging.Discard()})
+			defer c.Close()
+			if err := c.IsAvailable(); err != nil {
+				t.Skipf("%s is not available: %v", backend.name, err)
+			}
+
+			for _, tt := range svgs {
+				img, err := c.ConvertToImage(context.Background(), []byte(tt.svg))
+				if err != nil {
+					t.Errorf("%s: ConvertToImage: %v", tt.name, err)
+					continue
+				}
+
+				// --scale 2 doubles the shared parser's size on every backend
+				if got, want := img.Bounds().Size(), tt.want.Mul(2); got != want {
+					t.Errorf("%s: image is %v, want %v", tt.name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package svg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultSVGSize is the width and height assumed for an SVG that declares
+// neither usable width/height attributes nor a viewBox
+const DefaultSVGSize = 100.0
+
+// DefaultDPI is the CSS resolution at which SVG sizes are defined: physical
+// units become pixels at this resolution, and output is rendered at it
+// unless --dpi says otherwise
+const DefaultDPI = 96.0
+
+// svgUnits lists the SVG length units with their size in inches for
+// physical units, or in pixels for the others. em and rem assume the
+// default 16px font size. rem is listed before em so the longer suffix
+// matches first.
+var svgUnits = []struct {
+	suffix   string
+	size     float64
+	physical bool
+}{
+	{"rem", 16, false},
+	{"em", 16, false},
+	{"px", 1, false},
+	{"pt", 1.0 / 72, true},
+	{"pc", 1.0 / 6, true},
+	{"in", 1, true},
+	{"cm", 1 / 2.54, true},
+	{"mm", 1 / 25.4, true},
+}
+
+// ParseSVGDimensions returns the intrinsic width and height in CSS pixels of
+// an SVG document, converting physical units (mm, cm, in, pt, pc) at
+// DefaultDPI. Only the root <svg> element is read, so width and height
+// attributes of child elements never leak in. Percentages are taken relative
+// to the viewBox; a missing width or height is derived from the viewBox,
+// keeping its aspect ratio when the other dimension is known. Documents
+// without either get DefaultSVGSize.
+func ParseSVGDimensions(svgData []byte) (float64, float64, error) {
+	root, err := svgRootElement(svgData)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var widthAttr, heightAttr, viewBoxAttr string
+	for _, attr := range root.Attr {
+		switch attr.Name.Local {
+		case "width":
+			widthAttr = attr.Value
+		case "height":
+			heightAttr = attr.Value
+		case "viewBox":
+			viewBoxAttr = attr.Value
+		}
+	}
+
+	vbWidth, vbHeight, hasViewBox := parseViewBox(viewBoxAttr)
+	width, hasWidth := parseSVGLength(widthAttr, vbWidth)
+	height, hasHeight := parseSVGLength(heightAttr, vbHeight)
+
+	switch {
+	case hasWidth && hasHeight:
+		return width, height, nil
+	case hasViewBox && hasWidth:
+		return width, width * vbHeight / vbWidth, nil
+	case hasViewBox && hasHeight:
+		return height * vbWidth / vbHeight, height, nil
+	case hasViewBox:
+		return vbWidth, vbHeight, nil
+	case hasWidth:
+		return width, DefaultSVGSize, nil
+	case hasHeight:
+		return DefaultSVGSize, height, nil
+	default:
+		return DefaultSVGSize, DefaultSVGSize, nil
+	}
+}
+
+// svgRootElement returns the first element of an SVG document, which must be
+// an <svg> element
+func svgRootElement(svgData []byte) (xml.StartElement, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(svgData))
+	// Exported SVGs often use entities declared in a DTD or a non-UTF-8
+	// encoding; neither matters for the root attributes
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return xml.StartElement{}, fmt.Errorf("no <svg> element found")
+		}
+		if err != nil {
+			return xml.StartElement{}, fmt.Errorf("invalid SVG XML: %w", err)
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			if start.Name.Local != "svg" {
+				return xml.StartElement{}, fmt.Errorf("root element is <%s>, not <svg>", start.Name.Local)
+			}
+			return start, nil
+		}
+	}
+}
+
+// parseSVGLength converts an SVG length such as "64", "12.5px", "10mm" or
+// "50%" to CSS pixels. Percentages are relative to reference, the matching
+// viewBox size. Percentages without a reference, unknown units and
+// non-positive values are reported as missing.
+func parseSVGLength(s string, reference float64) (float64, bool) {
+	number, factor := strings.TrimSpace(s), 1.0
+	lower := strings.ToLower(number)
+	for _, unit := range svgUnits {
+		if strings.HasSuffix(lower, unit.suffix) {
+			number, factor = number[:len(number)-len(unit.suffix)], unit.size
+			if unit.physical {
+				factor *= DefaultDPI
+			}
+			break
+		}
+	}
+	if strings.HasSuffix(number, "%") {
+		number, factor = strings.TrimSuffix(number, "%"), reference/100
+	}
+
+	// Unknown units fail to parse as a number
+	value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || !isPositiveFinite(value) || factor <= 0 {
+		return 0, false
+	}
+
+	return value * factor, true
+}
+
+// parseViewBox returns the width and height of a viewBox attribute, whose
+// four numbers may be separated by whitespace and/or commas
+func parseViewBox(s string) (float64, float64, bool) {
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	if len(parts) != 4 {
+		return 0, 0, false
+	}
+
+	width, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil || !isPositiveFinite(width) {
+		return 0, 0, false
+	}
+
+	height, err := strconv.ParseFloat(parts[3], 64)
+	if err != nil || !isPositiveFinite(height) {
+		return 0, 0, false
+	}
+
+	return width, height, true
+}
+
+// isPositiveFinite reports whether v is a usable length, rejecting the NaN
+// and Inf values strconv accepts
+func isPositiveFinite(v float64) bool {
+	return v > 0 && !math.IsInf(v, 0)
+}
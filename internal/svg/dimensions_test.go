@@ -0,0 +1,183 @@
+package svg
+
+import (
+	"math"
+	"testing"
+)
+
+// closeTo reports whether got is within rounding error of want
+func closeTo(got, want float64) bool {
+	return math.Abs(got-want) < 1e-9
+}
+
+func TestParseSVGDimensions(t *testing.T) {
+	tests := []struct {
+		name       string
+		svg        string
+		wantWidth  float64
+		wantHeight float64
+	}{
+		{
+			name:      "width and height",
+			svg:       `<svg xmlns="http://www.w3.org/2000/svg" width="48" height="24"/>`,
+			wantWidth: 48, wantHeight: 24,
+		},
+		{
+			name:      "width and height override the viewBox",
+			svg:       `<svg xmlns="http://www.w3.org/2000/svg" width="48" height="24" viewBox="0 0 10 10"/>`,
+			wantWidth: 48, wantHeight: 24,
+		},
+		{
+			name:      "viewBox only",
+			svg:       `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 32"/>`,
+			wantWidth: 64, wantHeight: 32,
+		},
+		{
+			name:      "viewBox with commas and offset",
+			svg:       `<svg xmlns="http://www.w3.org/2000/svg" viewBox="-5,10, 40,20"/>`,
+			wantWidth: 40, wantHeight: 20,
+		},
+		{
+			name:      "width only keeps the viewBox aspect ratio",
+			svg:       `<svg xmlns="http://www.w3.org/2000/svg" width="100" viewBox="0 0 50 25"/>`,
+			wantWidth: 100, wantHeight: 50,
+		},
+		{
+			name:      "height only keeps the viewBox aspect ratio",
+			svg:       `<svg xmlns="http://www.w3.org/2000/svg" height="100" viewBox="0 0 50 25"/>`,
+			wantWidth: 200, wantHeight: 100,
+		},
+		{
+			name:      "width only without a viewBox",
+			svg:       `<svg xmlns="http://www.w3.org/2000/svg" width="30"/>`,
+			wantWidth: 30, wantHeight: DefaultSVGSize,
+		},
+		{
+			name:      "height only without a viewBox",
+			svg:       `<svg xmlns="http://www.w3.org/2000/svg" height="30"/>`,
+			wantWidth: DefaultSVGSize, wantHeight: 30,
+		},
+		{
+			name:      "percentages of the viewBox",
+			svg:       `<svg xmlns="http://www.w3.org/2000/svg" width="50%" height="200%" viewBox="0 0 80 40"/>`,
+			wantWidth: 40, wantHeight: 80,
+		},
+		{
+			name:      "percentages without a viewBox",
+			svg:       `<svg xmlns="http://www.w3.org/2000/svg" width="100%" height="100%"/>`,
+			wantWidth: DefaultSVGSize, wantHeight: DefaultSVGSize,
+		},
+		{
+			name:      "missing both",
+			svg:       `<svg xmlns="http://www.w3.org/2000/svg"><rect width="10" height="10"/></svg>`,
+			wantWidth: DefaultSVGSize, wantHeight: DefaultSVGSize,
+		},
+		{
+			name:      "child sizes are ignored",
+			svg:       `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 16 16"><svg width="500" height="500"/></svg>`,
+			wantWidth: 16, wantHeight: 16,
+		},
+		{
+			name:      "invalid sizes fall back to the viewBox",
+			svg:       `<svg xmlns="http://www.w3.org/2000/svg" width="-4" height="auto" viewBox="0 0 20 10"/>`,
+			wantWidth: 20, wantHeight: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height, err := ParseSVGDimensions([]byte(tt.svg))
+			if err != nil {
+				t.Fatalf("ParseSVGDimensions: %v", err)
+			}
+			if !closeTo(width, tt.wantWidth) || !closeTo(height, tt.wantHeight) {
+				t.Errorf("got %vx%v, want %vx%v", width, height, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestParseSVGDimensionsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		svg  string
+	}{
+		{name: "empty", svg: ""},
+		{name: "not svg", svg: `<html width="10" height="10"/>`},
+		{name: "malformed", svg: `<svg width="10" height="10"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ParseSVGDimensions([]byte(tt.svg)); err == nil {
+				t.Error("ParseSVGDimensions succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestParseSVGLengthUnits(t *testing.T) {
+	tests := []struct {
+		length string
+		want   float64
+	}{
+		{"64", 64},
+		{"64px", 64},
+		{"12.5PX", 12.5},
+		{"72pt", 96},
+		{"6pc", 96},
+		{"25.4mm", 96},
+		{"2.54cm", 96},
+		{"1in", 96},
+		{"2em", 32},
+		{"1.5rem", 24},
+		{" 10 px ", 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.length, func(t *testing.T) {
+			got, ok := parseSVGLength(tt.length, 0)
+			if !ok {
+				t.Fatalf("parseSVGLength(%q) reported no length", tt.length)
+			}
+			if !closeTo(got, tt.want) {
+				t.Errorf("parseSVGLength(%q) = %v, want %v", tt.length, got, tt.want)
+			}
+		})
+	}
+
+	for _, length := range []string{"", "auto", "10vw", "0", "-3mm", "Inf", "NaN"} {
+		if got, ok := parseSVGLength(length, 100); ok {
+			t.Errorf("parseSVGLength(%q) = %v, want no length", length, got)
+		}
+	}
+}
+
+func TestPhysicalUnitsAtDPI(t *testing.T) {
+	// 1in x 0.5in is 96x48 CSS pixels, rendered at the --dpi density
+	data := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="1in" height="12.7mm"/>`)
+
+	width, height, err := ParseSVGDimensions(data)
+	if err != nil {
+		t.Fatalf("ParseSVGDimensions: %v", err)
+	}
+
+	tests := []struct {
+		dpi        float64
+		wantWidth  int
+		wantHeight int
+	}{
+		{dpi: 0, wantWidth: 96, wantHeight: 48},
+		{dpi: DefaultDPI, wantWidth: 96, wantHeight: 48},
+		{dpi: 72, wantWidth: 72, wantHeight: 36},
+		{dpi: 300, wantWidth: 300, wantHeight: 150},
+	}
+
+	for _, tt := range tests {
+		opts := ConversionOptions{Scale: 1, DPI: tt.dpi}
+		gotWidth, gotHeight := opts.CalculateDimensions(width, height)
+		if gotWidth != tt.wantWidth || gotHeight != tt.wantHeight {
+			t.Errorf("at %v dpi got %dx%d, want %dx%d", tt.dpi, gotWidth, gotHeight, tt.wantWidth, tt.wantHeight)
+		}
+	}
+}
@@ -1,13 +1,17 @@
 package svg
 
 import (
+	"context"
 	"fmt"
 	"image"
+	"image/color"
 	"image/png"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+
+	"github.com/thanhfphan/svg2sheet/internal/utils"
 )
 
 // InkscapeConverter implements SVGConverter using the Inkscape command-line tool
@@ -41,14 +45,28 @@ func (c *InkscapeConverter) IsAvailable() error {
 	return nil
 }
 
+// Close is a no-op; the inkscape runs as a separate process per conversion
+func (c *InkscapeConverter) Close() error {
+	return nil
+}
+
 // ConvertFile converts a single SVG file to PNG
-func (c *InkscapeConverter) ConvertFile(inputPath, outputPath string) error {
-	if c.options.Verbose {
-		fmt.Printf("Converting SVG with Inkscape: %s -> %s\n", inputPath, outputPath)
+func (c *InkscapeConverter) ConvertFile(ctx context.Context, inputPath, outputPath string) error {
+	c.options.Logger.Debug("Converting SVG with Inkscape: %s -> %s", inputPath, outputPath)
+
+	// The external tool only writes PNG at its own compression, so other
+	// formats and compression levels are re-encoded in-process
+	if format, err := utils.ImageFormatFromPath(outputPath); err == nil && (format != utils.FormatPNG || c.options.Compression != png.DefaultCompression) {
+		return convertViaImage(ctx, c, inputPath, outputPath, c.options.EncodeOptions())
 	}
 
+	return c.render(ctx, inputPath, outputPath)
+}
+
+// render runs inkscape to write the SVG at inputPath as the PNG outputPath
+func (c *InkscapeConverter) render(ctx context.Context, inputPath, outputPath string) error {
 	// Get SVG dimensions to calculate target size
-	origWidth, origHeight, err := c.getSVGDimensions(inputPath)
+	origWidth, origHeight, err := c.getSVGDimensions(ctx, inputPath)
 	if err != nil {
 		return fmt.Errorf("failed to get SVG dimensions: %w", err)
 	}
@@ -56,22 +74,43 @@ func (c *InkscapeConverter) ConvertFile(inputPath, outputPath string) error {
 	// Calculate target dimensions
 	width, height := c.options.CalculateDimensions(origWidth, origHeight)
 
-	// Build inkscape command
-	args := []string{
-		"--export-type=png",
-		"--export-width=" + strconv.Itoa(width),
-		"--export-height=" + strconv.Itoa(height),
-		"--export-filename=" + outputPath,
-		inputPath,
-	}
-
-	cmd := exec.Command("inkscape", args...)
-
-	if c.options.Verbose {
-		fmt.Printf("Executing: inkscape %s\n", strings.Join(args, " "))
-	}
+	// Build inkscape command. Without an explicit size Inkscape renders at
+	// the requested density itself; --scale multiplies it. Normalized sizes
+	// are always given explicitly.
+	args := []string{"--export-type=png"}
+	if c.options.Width == 0 && c.options.Height == 0 && !c.options.Normalize {
+		scale := c.options.Scale
+		if scale <= 0 {
+			scale = 1
+		}
+		args = append(args, "--export-dpi="+strconv.FormatFloat(DefaultDPI*c.options.Density()*scale, 'f', -1, 64))
+	} else {
+		args = append(args,
+			"--export-width="+strconv.Itoa(width),
+			"--export-height="+strconv.Itoa(height),
+		)
+	}
+	args = append(args, "--export-filename="+outputPath)
+	if c.options.Background != nil {
+		bg := color.NRGBAModel.Convert(c.options.Background).(color.NRGBA)
+		args = append(args,
+			fmt.Sprintf("--export-background=#%02x%02x%02x", bg.R, bg.G, bg.B),
+			fmt.Sprintf("--export-background-opacity=%.3f", float64(bg.A)/255),
+		)
+	}
+	args = append(args, inputPath)
+
+	renderCtx, cancel := c.options.renderContext(ctx)
+	defer cancel()
+
+	cmd := exec.CommandContext(renderCtx, "inkscape", args...)
+
+	c.options.Logger.Debug("Executing: inkscape %s", strings.Join(args, " "))
 
 	output, err := cmd.CombinedOutput()
+	if renderCtx.Err() != nil {
+		return c.options.renderError(ctx, renderCtx, err)
+	}
 	if err != nil {
 		return fmt.Errorf("inkscape failed: %w\nOutput: %s", err, string(output))
 	}
@@ -80,7 +119,7 @@ func (c *InkscapeConverter) ConvertFile(inputPath, outputPath string) error {
 }
 
 // ConvertToImage converts SVG data to an image.Image
-func (c *InkscapeConverter) ConvertToImage(svgData []byte) (image.Image, error) {
+func (c *InkscapeConverter) ConvertToImage(ctx context.Context, svgData []byte) (image.Image, error) {
 	tmpSVG, err := os.CreateTemp("", "svg2sheet_*.svg")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temporary SVG file: %w", err)
@@ -93,36 +132,25 @@ func (c *InkscapeConverter) ConvertToImage(svgData []byte) (image.Image, error)
 	}
 	tmpSVG.Close()
 
-	tmpPNG, err := os.CreateTemp("", "svg2sheet_*.png")
+	tmpImage, err := os.CreateTemp("", "svg2sheet_*.png")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temporary PNG file: %w", err)
+		return nil, fmt.Errorf("failed to create temporary image file: %w", err)
 	}
-	defer os.Remove(tmpPNG.Name())
-	tmpPNG.Close()
+	defer os.Remove(tmpImage.Name())
+	tmpImage.Close()
 
-	// Convert using ConvertFile
-	if err := c.ConvertFile(tmpSVG.Name(), tmpPNG.Name()); err != nil {
+	// Render with the tool directly: ConvertFile may re-encode through
+	// ConvertToImage, which would come back here
+	if err := c.render(ctx, tmpSVG.Name(), tmpImage.Name()); err != nil {
 		return nil, fmt.Errorf("failed to convert SVG: %w", err)
 	}
 
-	// Read the PNG file back as image.Image
-	pngFile, err := os.Open(tmpPNG.Name())
-	if err != nil {
-		return nil, fmt.Errorf("failed to open converted PNG: %w", err)
-	}
-	defer pngFile.Close()
-
-	img, err := png.Decode(pngFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode PNG: %w", err)
-	}
-
-	return img, nil
+	return decodeRendered(tmpImage.Name())
 }
 
 // GetImageDimensions returns the dimensions that would be used for conversion
-func (c *InkscapeConverter) GetImageDimensions(svgPath string) (int, int, error) {
-	origWidth, origHeight, err := c.getSVGDimensions(svgPath)
+func (c *InkscapeConverter) GetImageDimensions(ctx context.Context, svgPath string) (int, int, error) {
+	origWidth, origHeight, err := c.getSVGDimensions(ctx, svgPath)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -131,10 +159,24 @@ func (c *InkscapeConverter) GetImageDimensions(svgPath string) (int, int, error)
 	return width, height, nil
 }
 
-// getSVGDimensions gets the original dimensions of an SVG file using Inkscape
-func (c *InkscapeConverter) getSVGDimensions(svgPath string) (float64, float64, error) {
+// getSVGDimensions gets the original dimensions of an SVG file from its root
+// element, like the other backends, and asks Inkscape only when the file
+// cannot be parsed and sizes are not normalized
+func (c *InkscapeConverter) getSVGDimensions(ctx context.Context, svgPath string) (float64, float64, error) {
+	data, err := os.ReadFile(svgPath)
+	if err == nil {
+		width, height, parseErr := ParseSVGDimensions(data)
+		if parseErr == nil {
+			return width, height, nil
+		}
+		err = parseErr
+	}
+	if c.options.Normalize {
+		return 0, 0, err
+	}
+
 	// Use inkscape to query SVG dimensions
-	cmd := exec.Command("inkscape", "--query-width", "--query-height", svgPath)
+	cmd := exec.CommandContext(ctx, "inkscape", "--query-width", "--query-height", svgPath)
 	output, err := cmd.Output()
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to query SVG dimensions: %w", err)
@@ -1,21 +1,27 @@
 package svg
 
 import (
+	"context"
 	"image"
+	"image/color"
+	"image/png"
+	"time"
 
 	"github.com/thanhfphan/svg2sheet/internal/config"
+	"github.com/thanhfphan/svg2sheet/internal/logging"
+	"github.com/thanhfphan/svg2sheet/internal/utils"
 )
 
 // SVGConverter defines the interface that all SVG conversion backends must implement
 type SVGConverter interface {
 	// ConvertFile converts a single SVG file to PNG
-	ConvertFile(inputPath, outputPath string) error
+	ConvertFile(ctx context.Context, inputPath, outputPath string) error
 
 	// ConvertToImage converts SVG data to an image.Image
-	ConvertToImage(svgData []byte) (image.Image, error)
+	ConvertToImage(ctx context.Context, svgData []byte) (image.Image, error)
 
 	// GetImageDimensions returns the dimensions that would be used for conversion
-	GetImageDimensions(svgPath string) (int, int, error)
+	GetImageDimensions(ctx context.Context, svgPath string) (int, int, error)
 
 	// IsAvailable checks if this converter is available on the system
 	IsAvailable() error
@@ -25,58 +31,148 @@ type SVGConverter interface {
 
 	// Description returns a description of this converter and its capabilities
 	Description() string
+
+	// Close releases any resources held by the converter, such as browser processes
+	Close() error
+}
+
+// BatchConverter is implemented by converters that can convert many files
+// faster than one ConvertFile call per file
+type BatchConverter interface {
+	// ConvertFiles converts the SVG at each mapping's OriginalPath to its
+	// PNGPath, calling progress (when not nil) before each file
+	ConvertFiles(ctx context.Context, mappings []utils.FileMapping, progress func(path string)) error
+}
+
+// FrameConverter is implemented by converters that can play SVG animations
+type FrameConverter interface {
+	// ConvertFrames renders the animated SVG at inputPath once for each
+	// output path, advancing the animation by interval between frames and
+	// starting at time zero
+	ConvertFrames(ctx context.Context, inputPath string, interval time.Duration, outputPaths []string) error
 }
 
 // ConversionOptions holds options for SVG conversion
 type ConversionOptions struct {
-	Scale   float64
-	Width   int
-	Height  int
-	Verbose bool
+	Scale       float64
+	Width       int
+	Height      int
+	DPI         float64 // raster density, DefaultDPI when 0
+	Normalize   bool    // take sizes only from ParseSVGDimensions and pass them to the backend explicitly
+	Quality     int
+	Compression png.CompressionLevel // PNG compression level, png.DefaultCompression when 0
+	MaxBytes    int64
+	Background  color.Color    // fill behind the rendered SVG, nil keeps transparency
+	Timeout     time.Duration  // time limit per render, none when 0
+	Jobs        int            // conversions a converter must be able to run at once; 1 when 0
+	Logger      logging.Logger // receives progress messages of the converters
 }
 
-// NewConversionOptions creates ConversionOptions from config
-func NewConversionOptions(cfg *config.Config) *ConversionOptions {
+// NewConversionOptions creates ConversionOptions from config for converters
+// that report through log
+func NewConversionOptions(cfg *config.Config, log logging.Logger) *ConversionOptions {
+	// Validated by Config.Validate
+	background, _ := cfg.BackgroundColor()
+	timeout, _ := cfg.RenderTimeout()
+
 	return &ConversionOptions{
-		Scale:   cfg.Scale,
-		Width:   cfg.Width,
-		Height:  cfg.Height,
-		Verbose: cfg.Verbose,
+		Scale:       cfg.Scale,
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+		DPI:         cfg.DPI,
+		Normalize:   cfg.NormalizeSize,
+		Quality:     cfg.Quality,
+		Compression: config.PNGCompression(cfg.PNGCompression).Level(),
+		MaxBytes:    cfg.MaxFileBytes,
+		Background:  background,
+		Timeout:     timeout,
+		Jobs:        cfg.Jobs,
+		Logger:      log,
+	}
+}
+
+// EncodeOptions returns the options used to write converted images
+func (opts *ConversionOptions) EncodeOptions() utils.EncodeOptions {
+	return utils.EncodeOptions{
+		Quality:     opts.Quality,
+		Compression: opts.Compression,
+		Background:  opts.Background,
+		MaxBytes:    opts.MaxBytes,
+		Logger:      opts.Logger,
 	}
 }
 
 // CalculateDimensions determines the target width and height for conversion
 // This is a common utility function that can be used by all converters
+// SVG sizes are taken to be at DefaultDPI, so the original size is
+// multiplied by DPI/96. An explicit width and height replace that size, with
+// a missing one following the aspect ratio, and are used as given. Scale
+// then multiplies whichever size applies, so --width 64 --scale 2 renders
+// 128 pixels wide. An original size that is zero or negative has no aspect
+// ratio, so a missing dimension then matches the given one, and is never
+// rendered at a negative size.
 func (opts *ConversionOptions) CalculateDimensions(origWidth, origHeight float64) (int, int) {
-	// If no dimensions specified, use original
-	if opts.Scale == 0 && opts.Width == 0 && opts.Height == 0 {
-		return int(origWidth), int(origHeight)
-	}
-
-	// If scale is specified, use it
-	if opts.Scale > 0 {
-		return int(origWidth * opts.Scale), int(origHeight * opts.Scale)
+	scale := opts.Scale
+	if scale <= 0 {
+		scale = 1
 	}
 
 	// If both width and height are specified, use them
 	if opts.Width > 0 && opts.Height > 0 {
-		return opts.Width, opts.Height
+		return int(float64(opts.Width) * scale), int(float64(opts.Height) * scale)
 	}
 
+	knownSize := origWidth > 0 && origHeight > 0
+
 	// If only width is specified, calculate height maintaining aspect ratio
 	if opts.Width > 0 {
-		aspectRatio := origHeight / origWidth
-		return opts.Width, int(float64(opts.Width) * aspectRatio)
+		aspectRatio := 1.0
+		if knownSize {
+			aspectRatio = origHeight / origWidth
+		}
+		return int(float64(opts.Width) * scale), int(float64(opts.Width) * aspectRatio * scale)
 	}
 
 	// If only height is specified, calculate width maintaining aspect ratio
 	if opts.Height > 0 {
-		aspectRatio := origWidth / origHeight
-		return int(float64(opts.Height) * aspectRatio), opts.Height
+		aspectRatio := 1.0
+		if knownSize {
+			aspectRatio = origWidth / origHeight
+		}
+		return int(float64(opts.Height) * aspectRatio * scale), int(float64(opts.Height) * scale)
 	}
 
-	// Fallback to original dimensions
-	return int(origWidth), int(origHeight)
+	// Otherwise scale the original size at the configured density
+	density := opts.Density()
+	return int(max(origWidth, 0) * scale * density), int(max(origHeight, 0) * scale * density)
+}
+
+// Density returns the factor between the configured DPI and DefaultDPI
+func (opts *ConversionOptions) Density() float64 {
+	if opts.DPI <= 0 {
+		return 1
+	}
+	return opts.DPI / DefaultDPI
+}
+
+// renderContext bounds a single render by the configured timeout
+func (opts *ConversionOptions) renderContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if opts.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, opts.Timeout)
+}
+
+// renderError returns the error for a render that failed with err: the
+// cancellation of ctx, a RenderTimeoutError when renderCtx expired, or err
+func (opts *ConversionOptions) renderError(ctx, renderCtx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if renderCtx.Err() == context.DeadlineExceeded {
+		return &RenderTimeoutError{Timeout: opts.Timeout}
+	}
+	return err
 }
 
 // ConverterRegistry manages available SVG converters
@@ -146,20 +242,26 @@ func (r *ConverterRegistry) GetConverterInfo(converterType config.ConverterType,
 	}
 
 	converter := factory(opts)
-	return &ConverterInfo{
+	info := &ConverterInfo{
 		Type:        converterType,
 		Name:        converter.Name(),
 		Description: converter.Description(),
-		Available:   converter.IsAvailable() == nil,
-	}, nil
+		Available:   true,
+	}
+	if err := converter.IsAvailable(); err != nil {
+		info.Available = false
+		info.Reason = err.Error()
+	}
+	return info, nil
 }
 
 // ConverterInfo holds information about a converter
 type ConverterInfo struct {
-	Type        config.ConverterType
-	Name        string
-	Description string
-	Available   bool
+	Type        config.ConverterType `json:"type"`
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	Available   bool                 `json:"available"`
+	Reason      string               `json:"reason,omitempty"` // why the converter is not available
 }
 
 // Error types for converter operations
@@ -179,3 +281,12 @@ type ConverterUnavailableError struct {
 func (e *ConverterUnavailableError) Error() string {
 	return "converter " + e.ConverterType + " is not available: " + e.Reason
 }
+
+// RenderTimeoutError reports a render that did not finish within --timeout
+type RenderTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *RenderTimeoutError) Error() string {
+	return "rendering timed out after " + e.Timeout.String()
+}
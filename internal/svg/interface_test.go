@@ -0,0 +1,76 @@
+package svg
+
+import (
+	"testing"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+	"github.com/thanhfphan/svg2sheet/internal/logging"
+)
+
+func TestCalculateDimensions(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       ConversionOptions
+		origWidth  float64
+		origHeight float64
+		wantWidth  int
+		wantHeight int
+	}{
+		{name: "original size", opts: ConversionOptions{}, origWidth: 40, origHeight: 20, wantWidth: 40, wantHeight: 20},
+		{name: "scale", opts: ConversionOptions{Scale: 2.5}, origWidth: 40, origHeight: 20, wantWidth: 100, wantHeight: 50},
+		{name: "negative scale is ignored", opts: ConversionOptions{Scale: -2}, origWidth: 40, origHeight: 20, wantWidth: 40, wantHeight: 20},
+		{name: "dpi", opts: ConversionOptions{Scale: 1, DPI: 192}, origWidth: 40, origHeight: 20, wantWidth: 80, wantHeight: 40},
+		{name: "scale and dpi", opts: ConversionOptions{Scale: 2, DPI: 48}, origWidth: 40, origHeight: 20, wantWidth: 40, wantHeight: 20},
+		{name: "width only", opts: ConversionOptions{Width: 80}, origWidth: 40, origHeight: 20, wantWidth: 80, wantHeight: 40},
+		{name: "width only with scale", opts: ConversionOptions{Width: 64, Scale: 2}, origWidth: 40, origHeight: 20, wantWidth: 128, wantHeight: 64},
+		{name: "height only", opts: ConversionOptions{Height: 60}, origWidth: 40, origHeight: 20, wantWidth: 120, wantHeight: 60},
+		{name: "height only with scale", opts: ConversionOptions{Height: 10, Scale: 3}, origWidth: 40, origHeight: 20, wantWidth: 60, wantHeight: 30},
+		{name: "width and height", opts: ConversionOptions{Width: 30, Height: 70}, origWidth: 40, origHeight: 20, wantWidth: 30, wantHeight: 70},
+		{name: "width and height with scale", opts: ConversionOptions{Width: 30, Height: 70, Scale: 2}, origWidth: 40, origHeight: 20, wantWidth: 60, wantHeight: 140},
+		{name: "dpi does not affect an explicit size", opts: ConversionOptions{Width: 30, DPI: 300}, origWidth: 30, origHeight: 30, wantWidth: 30, wantHeight: 30},
+		{name: "zero origin", opts: ConversionOptions{Scale: 2}, origWidth: 0, origHeight: 0, wantWidth: 0, wantHeight: 0},
+		{name: "negative origin", opts: ConversionOptions{Scale: 2}, origWidth: -40, origHeight: 20, wantWidth: 0, wantHeight: 40},
+		{name: "width only with zero origin", opts: ConversionOptions{Width: 50}, origWidth: 0, origHeight: 0, wantWidth: 50, wantHeight: 50},
+		{name: "height only with negative origin", opts: ConversionOptions{Height: 50}, origWidth: 20, origHeight: -10, wantWidth: 50, wantHeight: 50},
+		{name: "width and height with zero origin", opts: ConversionOptions{Width: 30, Height: 70}, origWidth: 0, origHeight: 0, wantWidth: 30, wantHeight: 70},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height := tt.opts.CalculateDimensions(tt.origWidth, tt.origHeight)
+			if width != tt.wantWidth || height != tt.wantHeight {
+				t.Errorf("got %dx%d, want %dx%d", width, height, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestNewConversionOptionsJobs(t *testing.T) {
+	tests := []struct {
+		jobs int
+		want int
+	}{
+		{jobs: 0, want: 1},
+		{jobs: 1, want: 1},
+		{jobs: 4, want: 4},
+	}
+
+	for _, tt := range tests {
+		cfg := config.Config{Input: "in", Output: "out.png", Jobs: tt.jobs}
+		cfg.SetDefaults()
+
+		opts := NewConversionOptions(&cfg, logging.Discard())
+		if opts.Jobs != tt.want {
+			t.Errorf("--jobs %d gives Jobs %d, want %d", tt.jobs, opts.Jobs, tt.want)
+		}
+		if pool := NewRodConverter(opts).(*RodConverter).pool; cap(pool.slots) != tt.want {
+			t.Errorf("--jobs %d gives a pool of %d pages, want %d", tt.jobs, cap(pool.slots), tt.want)
+		}
+	}
+
+	cfg := config.Config{Input: "in", Output: "out.png", Jobs: -1}
+	cfg.SetDefaults()
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate accepted --jobs -1")
+	}
+}
@@ -2,13 +2,14 @@ package svg
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"image"
-	"image/png"
 	"os"
 
 	"github.com/srwiley/oksvg"
 	"github.com/srwiley/rasterx"
+	"github.com/thanhfphan/svg2sheet/internal/utils"
 )
 
 // OkSVGConverter implements SVGConverter using the oksvg+rasterx libraries
@@ -39,11 +40,14 @@ func (c *OkSVGConverter) IsAvailable() error {
 	return nil
 }
 
+// Close is a no-op; the pure Go converter holds no resources
+func (c *OkSVGConverter) Close() error {
+	return nil
+}
+
 // ConvertFile converts a single SVG file to PNG
-func (c *OkSVGConverter) ConvertFile(inputPath, outputPath string) error {
-	if c.options.Verbose {
-		fmt.Printf("Converting SVG with OkSVG: %s -> %s\n", inputPath, outputPath)
-	}
+func (c *OkSVGConverter) ConvertFile(ctx context.Context, inputPath, outputPath string) error {
+	c.options.Logger.Debug("Converting SVG with OkSVG: %s -> %s", inputPath, outputPath)
 
 	// Read SVG file
 	svgData, err := os.ReadFile(inputPath)
@@ -52,31 +56,38 @@ func (c *OkSVGConverter) ConvertFile(inputPath, outputPath string) error {
 	}
 
 	// Convert to image
-	img, err := c.ConvertToImage(svgData)
+	img, err := c.ConvertToImage(ctx, svgData)
 	if err != nil {
 		return fmt.Errorf("failed to convert SVG to image: %w", err)
 	}
 
 	// Save as PNG
-	return c.savePNG(img, outputPath)
+	return c.saveImage(img, outputPath)
 }
 
 // ConvertToImage converts SVG data to an image.Image
-func (c *OkSVGConverter) ConvertToImage(svgData []byte) (image.Image, error) {
+func (c *OkSVGConverter) ConvertToImage(ctx context.Context, svgData []byte) (image.Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	icon, err := oksvg.ReadIconStream(bytes.NewReader(svgData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse SVG with OkSVG: %w", err)
 	}
 
 	// Calculate target dimensions
-	width, height := c.calculateDimensions(icon)
+	width, height, err := c.calculateDimensions(svgData, icon)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create and return raster image
 	return c.rasterizeSVG(icon, width, height), nil
 }
 
 // GetImageDimensions returns the dimensions of an SVG file
-func (c *OkSVGConverter) GetImageDimensions(svgPath string) (int, int, error) {
+func (c *OkSVGConverter) GetImageDimensions(ctx context.Context, svgPath string) (int, int, error) {
 	svgData, err := os.ReadFile(svgPath)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to read SVG file: %w", err)
@@ -87,16 +98,23 @@ func (c *OkSVGConverter) GetImageDimensions(svgPath string) (int, int, error) {
 		return 0, 0, fmt.Errorf("failed to parse SVG with OkSVG: %w", err)
 	}
 
-	width, height := c.calculateDimensions(icon)
-	return width, height, nil
+	return c.calculateDimensions(svgData, icon)
 }
 
-// calculateDimensions determines the target width and height for the conversion
-func (c *OkSVGConverter) calculateDimensions(icon *oksvg.SvgIcon) (int, int) {
-	origWidth := icon.ViewBox.W
-	origHeight := icon.ViewBox.H
+// calculateDimensions determines the target width and height for the
+// conversion from the SVG's root attributes, falling back to the viewBox
+// OkSVG parsed unless sizes are normalized
+func (c *OkSVGConverter) calculateDimensions(svgData []byte, icon *oksvg.SvgIcon) (int, int, error) {
+	origWidth, origHeight, err := ParseSVGDimensions(svgData)
+	if err != nil {
+		if c.options.Normalize {
+			return 0, 0, fmt.Errorf("failed to parse SVG dimensions: %w", err)
+		}
+		origWidth, origHeight = icon.ViewBox.W, icon.ViewBox.H
+	}
 
-	return c.options.CalculateDimensions(origWidth, origHeight)
+	width, height := c.options.CalculateDimensions(origWidth, origHeight)
+	return width, height, nil
 }
 
 // rasterizeSVG converts the SVG icon to a raster image
@@ -113,17 +131,7 @@ func (c *OkSVGConverter) rasterizeSVG(icon *oksvg.SvgIcon, width, height int) im
 	return img
 }
 
-// savePNG saves the image as a PNG file
-func (c *OkSVGConverter) savePNG(img image.Image, outputPath string) error {
-	outFile, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
-	}
-	defer outFile.Close()
-
-	if err := png.Encode(outFile, img); err != nil {
-		return fmt.Errorf("failed to encode PNG: %w", err)
-	}
-
-	return nil
+// saveImage saves the image in the format implied by the output extension
+func (c *OkSVGConverter) saveImage(img image.Image, outputPath string) error {
+	return utils.SaveImage(img, outputPath, c.options.EncodeOptions())
 }
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package svg
+
+// processRunning cannot check for a process on this platform, so ok is false
+func processRunning(pid int) (running, ok bool) {
+	return false, false
+}
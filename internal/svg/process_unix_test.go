@@ -0,0 +1,17 @@
+//go:build unix
+
+package svg
+
+import (
+	"os"
+	"syscall"
+)
+
+// processRunning reports whether the process with the given ID still exists
+func processRunning(pid int) (running, ok bool) {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false, true
+	}
+	return process.Signal(syscall.Signal(0)) == nil, true
+}
@@ -1,27 +1,34 @@
 package svg
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/png"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/launcher"
 	"github.com/go-rod/rod/lib/proto"
+	"github.com/thanhfphan/svg2sheet/internal/utils"
 )
 
-// RodConverter implements SVGConverter using Rod browser automation
+// RodConverter implements SVGConverter using Rod browser automation. It is
+// safe for concurrent use: every conversion renders on its own page of a
+// shared browser.
 type RodConverter struct {
 	options *ConversionOptions
-	browser *rod.Browser
+	pool    *browserPool
 }
 
 // NewRodConverter creates a new Rod-based converter
 func NewRodConverter(options *ConversionOptions) SVGConverter {
 	return &RodConverter{
 		options: options,
+		pool:    newBrowserPool(options.Jobs),
 	}
 }
 
@@ -46,31 +53,135 @@ func (c *RodConverter) IsAvailable() error {
 }
 
 // ConvertFile converts a single SVG file to PNG
-func (c *RodConverter) ConvertFile(inputPath, outputPath string) error {
-	if c.options.Verbose {
-		fmt.Printf("Converting SVG with Rod Browser: %s -> %s\n", inputPath, outputPath)
-	}
+func (c *RodConverter) ConvertFile(ctx context.Context, inputPath, outputPath string) error {
+	c.options.Logger.Debug("Converting SVG with Rod Browser: %s -> %s", inputPath, outputPath)
 
 	svgData, err := os.ReadFile(inputPath)
 	if err != nil {
 		return fmt.Errorf("failed to read SVG file: %w", err)
 	}
 
-	img, err := c.ConvertToImage(svgData)
+	img, err := c.ConvertToImage(ctx, svgData)
 	if err != nil {
 		return fmt.Errorf("failed to convert SVG to image: %w", err)
 	}
 
-	return c.savePNG(img, outputPath)
+	return c.saveImage(img, outputPath)
 }
 
 // ConvertToImage converts SVG data to an image.Image
-func (c *RodConverter) ConvertToImage(svgData []byte) (image.Image, error) {
-	if err := c.initBrowser(); err != nil {
-		return nil, fmt.Errorf("failed to initialize browser: %w", err)
+func (c *RodConverter) ConvertToImage(ctx context.Context, svgData []byte) (image.Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	page, err := c.pool.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := c.render(ctx, page.page, svgData)
+	c.pool.release(page, err)
+	return img, err
+}
+
+// ConvertFiles converts the SVG at each mapping's OriginalPath to its PNGPath.
+// The files are shared among up to Jobs pages, each used for the files it
+// takes in turn; only its content and viewport change between files. The
+// first failure stops the other pages and is returned.
+func (c *RodConverter) ConvertFiles(ctx context.Context, mappings []utils.FileMapping, progress func(path string)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	report := func(path string) {
+		if progress != nil {
+			mu.Lock()
+			progress(path)
+			mu.Unlock()
+		}
+	}
+
+	queue := make(chan utils.FileMapping)
+	workers := min(max(c.options.Jobs, 1), len(mappings))
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.convertQueue(ctx, queue, report); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				cancel()
+			}
+		}()
+	}
+
+feed:
+	for _, mapping := range mappings {
+		select {
+		case queue <- mapping:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
 	}
+	return ctx.Err()
+}
 
-	origWidth, origHeight, err := c.parseSVGDimensions(svgData)
+// convertQueue converts the mappings received from queue on one pooled page
+// until queue is closed
+func (c *RodConverter) convertQueue(ctx context.Context, queue <-chan utils.FileMapping, progress func(path string)) (err error) {
+	pooled, err := c.pool.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { c.pool.release(pooled, err) }()
+
+	for mapping := range queue {
+		progress(mapping.OriginalPath)
+		c.options.Logger.Debug("Converting SVG with Rod Browser: %s -> %s", mapping.OriginalPath, mapping.PNGPath)
+
+		svgData, err := os.ReadFile(mapping.OriginalPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", mapping.OriginalPath, err)
+		}
+
+		img, err := c.render(ctx, pooled.page, svgData)
+		if err != nil {
+			return fmt.Errorf("failed to convert %s: %w", mapping.OriginalPath, err)
+		}
+
+		if err := c.saveImage(img, mapping.PNGPath); err != nil {
+			return fmt.Errorf("failed to save %s: %w", mapping.PNGPath, err)
+		}
+	}
+
+	return nil
+}
+
+// render draws SVG data on an open page sized to the target dimensions
+func (c *RodConverter) render(ctx context.Context, page *rod.Page, svgData []byte) (image.Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	origWidth, origHeight, err := ParseSVGDimensions(svgData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse SVG dimensions: %w", err)
 	}
@@ -80,19 +191,12 @@ func (c *RodConverter) ConvertToImage(svgData []byte) (image.Image, error) {
 
 	html := c.createHTMLWithSVG(string(svgData), width, height)
 
-	page := c.browser.MustPage()
-	defer page.MustClose()
-
-	page.MustSetViewport(width, height, 1, false)
-	page.MustNavigate("data:text/html;charset=utf-8," + html)
-	page.MustWaitLoad()
+	renderCtx, cancel := c.options.renderContext(ctx)
+	defer cancel()
 
-	screenshot, err := page.Screenshot(true, &proto.PageCaptureScreenshot{
-		Format:  proto.PageCaptureScreenshotFormatPng,
-		Quality: nil, // PNG doesn't use quality
-	})
+	screenshot, err := c.capture(page.Context(renderCtx), html, width, height)
 	if err != nil {
-		return nil, fmt.Errorf("failed to take screenshot: %w", err)
+		return nil, c.options.renderError(ctx, renderCtx, err)
 	}
 
 	img, err := png.Decode(strings.NewReader(string(screenshot)))
@@ -103,109 +207,139 @@ func (c *RodConverter) ConvertToImage(svgData []byte) (image.Image, error) {
 	return img, nil
 }
 
-// GetImageDimensions returns the dimensions of an SVG file
-func (c *RodConverter) GetImageDimensions(svgPath string) (int, int, error) {
-	svgData, err := os.ReadFile(svgPath)
-	if err != nil {
-		return 0, 0, fmt.Errorf("failed to read SVG file: %w", err)
+// ConvertFrames renders the animated SVG at inputPath once for each output
+// path. The page is loaded once; for every frame its SMIL and CSS animations
+// are paused and moved to the frame's time before the screenshot, so frames
+// do not depend on how fast the browser renders.
+func (c *RodConverter) ConvertFrames(ctx context.Context, inputPath string, interval time.Duration, outputPaths []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	origWidth, origHeight, err := c.parseSVGDimensions(svgData)
+	c.options.Logger.Debug("Capturing %d frames with Rod Browser: %s", len(outputPaths), inputPath)
+
+	svgData, err := os.ReadFile(inputPath)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to parse SVG dimensions: %w", err)
+		return fmt.Errorf("failed to read SVG file: %w", err)
 	}
 
+	origWidth, origHeight, err := ParseSVGDimensions(svgData)
+	if err != nil {
+		return fmt.Errorf("failed to parse SVG dimensions: %w", err)
+	}
 	width, height := c.options.CalculateDimensions(origWidth, origHeight)
-	return width, height, nil
+
+	page, err := c.pool.acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The time limit covers the whole animation, like a single render
+	renderCtx, cancel := c.options.renderContext(ctx)
+	defer cancel()
+	p := page.page.Context(renderCtx)
+
+	err = c.load(p, c.createHTMLWithSVG(string(svgData), width, height), width, height)
+	for i := 0; err == nil && i < len(outputPaths); i++ {
+		err = c.captureFrame(p, time.Duration(i)*interval, outputPaths[i])
+	}
+	if err != nil {
+		err = c.options.renderError(ctx, renderCtx, err)
+	}
+	c.pool.release(page, err)
+	return err
 }
 
-// initBrowser initializes the browser instance if not already done
-func (c *RodConverter) initBrowser() error {
-	if c.browser != nil {
-		return nil
+// seekAnimations pauses every SMIL and CSS animation on the page at the
+// given time in seconds
+const seekAnimations = `(seconds) => {
+	for (const svg of document.querySelectorAll('svg')) {
+		svg.pauseAnimations();
+		svg.setCurrentTime(seconds);
 	}
+	for (const animation of document.getAnimations()) {
+		animation.pause();
+		animation.currentTime = seconds * 1000;
+	}
+}`
 
-	launcher := launcher.New().
-		Headless(true).
-		NoSandbox(true).
-		Set("disable-gpu").
-		Set("disable-dev-shm-usage")
+// captureFrame moves the page's animations to at and saves a screenshot to
+// outputPath
+func (c *RodConverter) captureFrame(p *rod.Page, at time.Duration, outputPath string) error {
+	if _, err := p.Eval(seekAnimations, at.Seconds()); err != nil {
+		return fmt.Errorf("failed to seek animations: %w", err)
+	}
 
-	url, err := launcher.Launch()
+	screenshot, err := c.screenshot(p)
 	if err != nil {
-		return fmt.Errorf("failed to launch browser: %w", err)
+		return err
 	}
 
-	browser := rod.New().ControlURL(url)
-	if err := browser.Connect(); err != nil {
-		return fmt.Errorf("failed to connect to browser: %w", err)
+	img, err := png.Decode(strings.NewReader(string(screenshot)))
+	if err != nil {
+		return fmt.Errorf("failed to decode screenshot PNG: %w", err)
 	}
 
-	c.browser = browser
-	return nil
+	return c.saveImage(img, outputPath)
 }
 
-// parseSVGDimensions extracts width and height from SVG data
-func (c *RodConverter) parseSVGDimensions(svgData []byte) (float64, float64, error) {
-	// TODO: Improve SVG dimension parsing
-	svgStr := string(svgData)
-
-	// Default dimensions if not found
-	width, height := 100.0, 100.0
-
-	// Look for viewBox attribute first
-	if viewBoxStart := strings.Index(svgStr, "viewBox=\""); viewBoxStart != -1 {
-		viewBoxStart += 9 // length of "viewBox=\""
-		if viewBoxEnd := strings.Index(svgStr[viewBoxStart:], "\""); viewBoxEnd != -1 {
-			viewBox := svgStr[viewBoxStart : viewBoxStart+viewBoxEnd]
-			parts := strings.Fields(viewBox)
-			if len(parts) >= 4 {
-				// viewBox format: "x y width height"
-				if w, err := parseFloatRod(parts[2]); err == nil {
-					width = w
-				}
-				if h, err := parseFloatRod(parts[3]); err == nil {
-					height = h
-				}
-			}
-		}
+// capture resizes the page viewport, replaces its content with the HTML and
+// returns a PNG screenshot
+func (c *RodConverter) capture(p *rod.Page, html string, width, height int) ([]byte, error) {
+	if err := c.load(p, html, width, height); err != nil {
+		return nil, err
 	}
+	return c.screenshot(p)
+}
 
-	// Look for width and height attributes
-	if widthStart := strings.Index(svgStr, "width=\""); widthStart != -1 {
-		widthStart += 7 // length of "width=\""
-		if widthEnd := strings.Index(svgStr[widthStart:], "\""); widthEnd != -1 {
-			widthStr := svgStr[widthStart : widthStart+widthEnd]
-			if w, err := parseFloatRod(widthStr); err == nil {
-				width = w
-			}
-		}
+// load resizes the page viewport and replaces its content with the HTML
+func (c *RodConverter) load(p *rod.Page, html string, width, height int) error {
+	if err := p.SetViewport(&proto.EmulationSetDeviceMetricsOverride{
+		Width:             width,
+		Height:            height,
+		DeviceScaleFactor: 1,
+	}); err != nil {
+		return fmt.Errorf("failed to set viewport: %w", err)
 	}
 
-	if heightStart := strings.Index(svgStr, "height=\""); heightStart != -1 {
-		heightStart += 8 // length of "height=\""
-		if heightEnd := strings.Index(svgStr[heightStart:], "\""); heightEnd != -1 {
-			heightStr := svgStr[heightStart : heightStart+heightEnd]
-			if h, err := parseFloatRod(heightStr); err == nil {
-				height = h
-			}
-		}
+	if err := p.SetDocumentContent(html); err != nil {
+		return fmt.Errorf("failed to load SVG page: %w", err)
 	}
 
-	return width, height, nil
+	if err := p.WaitLoad(); err != nil {
+		return fmt.Errorf("failed to wait for page load: %w", err)
+	}
+
+	return nil
+}
+
+// screenshot returns a PNG screenshot of the page
+func (c *RodConverter) screenshot(p *rod.Page) ([]byte, error) {
+	screenshot, err := p.Screenshot(true, &proto.PageCaptureScreenshot{
+		Format:  proto.PageCaptureScreenshotFormatPng,
+		Quality: nil, // PNG doesn't use quality
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to take screenshot: %w", err)
+	}
+
+	return screenshot, nil
 }
 
-// parseFloatRod parses a float from a string, handling units
-func parseFloatRod(s string) (float64, error) {
-	// Remove common SVG units
-	s = strings.TrimSuffix(s, "px")
-	s = strings.TrimSuffix(s, "pt")
-	s = strings.TrimSuffix(s, "em")
-	s = strings.TrimSuffix(s, "rem")
+// GetImageDimensions returns the dimensions of an SVG file
+func (c *RodConverter) GetImageDimensions(ctx context.Context, svgPath string) (int, int, error) {
+	svgData, err := os.ReadFile(svgPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read SVG file: %w", err)
+	}
 
-	var result float64
-	_, err := fmt.Sscanf(s, "%f", &result)
-	return result, err
+	origWidth, origHeight, err := ParseSVGDimensions(svgData)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse SVG dimensions: %w", err)
+	}
+
+	width, height := c.options.CalculateDimensions(origWidth, origHeight)
+	return width, height, nil
 }
 
 // createHTMLWithSVG creates an HTML page containing the SVG
@@ -225,25 +359,140 @@ func (c *RodConverter) createHTMLWithSVG(svgContent string, width, height int) s
 </html>`, width, height, svgContent)
 }
 
-// savePNG saves the image as a PNG file
-func (c *RodConverter) savePNG(img image.Image, outputPath string) error {
-	outFile, err := os.Create(outputPath)
+// saveImage saves the image in the format implied by the output extension
+func (c *RodConverter) saveImage(img image.Image, outputPath string) error {
+	return utils.SaveImage(img, outputPath, c.options.EncodeOptions())
+}
+
+// Close closes every pooled page and the browser and waits for the browser
+// process to exit. A later conversion launches a new browser.
+func (c *RodConverter) Close() error {
+	return c.pool.close()
+}
+
+// pooledPage is a browser page handed out by a browserPool
+type pooledPage struct {
+	page       *rod.Page
+	generation int // launch of the browser the page belongs to
+}
+
+// browserPool hands out pages of one lazily launched browser to concurrent
+// conversions. Up to size pages are open at once and returned pages are
+// reused, so the browser is launched once rather than for every file;
+// conversions beyond size wait for a page to be returned.
+type browserPool struct {
+	slots chan struct{} // one token per page in use
+
+	mu         sync.Mutex
+	browser    *rod.Browser
+	launcher   *launcher.Launcher
+	generation int          // incremented on close, so pages of a closed browser are dropped
+	idle       []pooledPage // returned pages ready for reuse
+}
+
+// newBrowserPool creates a pool of up to size pages, one when size is not
+// positive. No browser is launched until the first page is acquired.
+func newBrowserPool(size int) *browserPool {
+	return &browserPool{slots: make(chan struct{}, max(size, 1))}
+}
+
+// acquire returns an idle page, or opens a new one, launching the browser
+// first if needed. It waits while size pages are in use and returns
+// ctx.Err() if ctx is done first.
+func (b *browserPool) acquire(ctx context.Context) (pooledPage, error) {
+	select {
+	case b.slots <- struct{}{}:
+	case <-ctx.Done():
+		return pooledPage{}, ctx.Err()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if n := len(b.idle); n > 0 {
+		page := b.idle[n-1]
+		b.idle = b.idle[:n-1]
+		return page, nil
+	}
+
+	if err := b.launch(); err != nil {
+		<-b.slots
+		return pooledPage{}, fmt.Errorf("failed to initialize browser: %w", err)
+	}
+
+	page, err := b.browser.Page(proto.TargetCreateTarget{})
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		<-b.slots
+		return pooledPage{}, fmt.Errorf("failed to open page: %w", err)
 	}
-	defer outFile.Close()
+	return pooledPage{page: page, generation: b.generation}, nil
+}
 
-	if err := png.Encode(outFile, img); err != nil {
-		return fmt.Errorf("failed to encode PNG: %w", err)
+// release returns a page to the pool. A page whose conversion failed may be
+// left mid-render, as after a timeout, so it is closed instead of reused;
+// so are pages of a browser closed in the meantime.
+func (b *browserPool) release(page pooledPage, err error) {
+	b.mu.Lock()
+	if err == nil && page.generation == b.generation && b.browser != nil {
+		b.idle = append(b.idle, page)
+	} else {
+		page.page.Close()
 	}
+	b.mu.Unlock()
 
-	return nil
+	<-b.slots
 }
 
-// Close closes the browser instance
-func (c *RodConverter) Close() error {
-	if c.browser != nil {
-		return c.browser.Close()
+// launch starts the browser unless it is running. b.mu must be held.
+func (b *browserPool) launch() error {
+	if b.browser != nil {
+		return nil
+	}
+
+	l := launcher.New().
+		Headless(true).
+		NoSandbox(true).
+		Set("disable-gpu").
+		Set("disable-dev-shm-usage")
+
+	url, err := l.Launch()
+	if err != nil {
+		return fmt.Errorf("failed to launch browser: %w", err)
 	}
+
+	browser := rod.New().ControlURL(url)
+	if err := browser.Connect(); err != nil {
+		l.Kill()
+		l.Cleanup()
+		return fmt.Errorf("failed to connect to browser: %w", err)
+	}
+
+	b.browser = browser
+	b.launcher = l
 	return nil
 }
+
+// close closes the idle pages and the browser and waits for the browser
+// process to exit. Pages still in use are closed when they are released.
+func (b *browserPool) close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, page := range b.idle {
+		page.page.Close()
+	}
+	b.idle = nil
+	b.generation++
+
+	var err error
+	if b.browser != nil {
+		err = b.browser.Close()
+		b.browser = nil
+	}
+	if b.launcher != nil {
+		b.launcher.Kill()
+		b.launcher.Cleanup()
+		b.launcher = nil
+	}
+	return err
+}
@@ -0,0 +1,156 @@
+package svg
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/thanhfphan/svg2sheet/internal/logging"
+	"github.com/thanhfphan/svg2sheet/internal/utils"
+)
+
+// redSquareSVG is a 16x16 SVG filled with opaque red
+const redSquareSVG = `<svg xmlns="http://www.w3.org/2000/svg" width="16" height="16"><rect width="16" height="16" fill="#ff0000"/></svg>`
+
+// newTestRodConverter returns a Rod converter rendering up to jobs SVGs at
+// once. The test is skipped without an installed Chrome or Chromium, which
+// rod would otherwise download.
+func newTestRodConverter(t *testing.T, jobs int) *RodConverter {
+	t.Helper()
+
+	if _, found := launcher.LookPath(); !found {
+		t.Skip("Chrome/Chromium not found")
+	}
+
+	return NewRodConverter(&ConversionOptions{
+		Scale:  1,
+		DPI:    DefaultDPI,
+		Jobs:   jobs,
+		Logger: logging.Discard(),
+	}).(*RodConverter)
+}
+
+func TestRodConverterCloseEndsBrowser(t *testing.T) {
+	c := newTestRodConverter(t, 1)
+
+	if _, err := c.ConvertToImage(context.Background(), []byte(redSquareSVG)); err != nil {
+		t.Fatalf("ConvertToImage: %v", err)
+	}
+
+	c.pool.mu.Lock()
+	pid := c.pool.launcher.PID()
+	c.pool.mu.Unlock()
+	if pid == 0 {
+		t.Fatal("browser was not launched")
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if running, ok := processRunning(pid); ok && running {
+		t.Errorf("browser process %d still running after Close", pid)
+	}
+
+	// Closing again is a no-op
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestRodConverterConcurrentConversions(t *testing.T) {
+	c := newTestRodConverter(t, 3)
+	defer c.Close()
+
+	// Every SVG has its own size and color, so output that leaked from
+	// another conversion shows up as a wrong size or pixel
+	type job struct {
+		size  int
+		color color.NRGBA
+	}
+	jobs := make([]job, 12)
+	for i := range jobs {
+		jobs[i] = job{size: 10 + 3*i, color: color.NRGBA{R: uint8(20 * i), G: 255 - uint8(20*i), B: 100, A: 255}}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(jobs))
+	for i, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d"><rect width="%d" height="%d" fill="#%02x%02x%02x"/></svg>`,
+				j.size, j.size, j.size, j.size, j.color.R, j.color.G, j.color.B)
+			img, err := c.ConvertToImage(context.Background(), []byte(svg))
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			if size := img.Bounds().Size(); size != image.Pt(j.size, j.size) {
+				errs[i] = fmt.Errorf("image is %v, want %dx%d", size, j.size, j.size)
+				return
+			}
+			center := img.Bounds().Min.Add(image.Pt(j.size/2, j.size/2))
+			if got := color.NRGBAModel.Convert(img.At(center.X, center.Y)); got != j.color {
+				errs[i] = fmt.Errorf("center pixel is %v, want %v", got, j.color)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("conversion %d: %v", i, err)
+		}
+	}
+}
+
+func TestRodConverterConvertFilesJobs(t *testing.T) {
+	c := newTestRodConverter(t, 3)
+	defer c.Close()
+
+	dir := t.TempDir()
+	mappings := make([]utils.FileMapping, 10)
+	for i := range mappings {
+		size := 10 + 2*i
+		svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d"><rect width="%d" height="%d" fill="#ff0000"/></svg>`, size, size, size, size)
+		path := filepath.Join(dir, fmt.Sprintf("icon%d.svg", i))
+		if err := os.WriteFile(path, []byte(svg), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mappings[i] = utils.FileMapping{OriginalPath: path, PNGPath: filepath.Join(dir, fmt.Sprintf("icon%d.png", i))}
+	}
+
+	// Progress calls are serialized, so the count needs no lock
+	reported := 0
+	if err := c.ConvertFiles(context.Background(), mappings, func(string) { reported++ }); err != nil {
+		t.Fatalf("ConvertFiles: %v", err)
+	}
+	if reported != len(mappings) {
+		t.Errorf("progress reported %d files, want %d", reported, len(mappings))
+	}
+
+	for i, mapping := range mappings {
+		img, err := utils.DecodeImageFile(mapping.PNGPath)
+		if err != nil {
+			t.Fatalf("loading %s: %v", mapping.PNGPath, err)
+		}
+		if size := img.Bounds().Size(); size != image.Pt(10+2*i, 10+2*i) {
+			t.Errorf("%s is %v, want %dx%d", mapping.PNGPath, size, 10+2*i, 10+2*i)
+		}
+	}
+
+	c.pool.mu.Lock()
+	pages := len(c.pool.idle)
+	c.pool.mu.Unlock()
+	if pages < 1 || pages > 3 {
+		t.Errorf("pool holds %d pages, want 1 to 3", pages)
+	}
+}
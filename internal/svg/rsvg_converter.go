@@ -1,13 +1,17 @@
 package svg
 
 import (
+	"context"
 	"fmt"
 	"image"
+	"image/color"
 	"image/png"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+
+	"github.com/thanhfphan/svg2sheet/internal/utils"
 )
 
 // RSVGConverter implements SVGConverter using the rsvg-convert system command
@@ -49,12 +53,26 @@ func (c *RSVGConverter) IsAvailable() error {
 	return nil
 }
 
+// Close is a no-op; the rsvg-convert runs as a separate process per conversion
+func (c *RSVGConverter) Close() error {
+	return nil
+}
+
 // ConvertFile converts a single SVG file to PNG
-func (c *RSVGConverter) ConvertFile(inputPath, outputPath string) error {
-	if c.options.Verbose {
-		fmt.Printf("Converting SVG with RSVG: %s -> %s\n", inputPath, outputPath)
+func (c *RSVGConverter) ConvertFile(ctx context.Context, inputPath, outputPath string) error {
+	c.options.Logger.Debug("Converting SVG with RSVG: %s -> %s", inputPath, outputPath)
+
+	// The external tool only writes PNG at its own compression, so other
+	// formats and compression levels are re-encoded in-process
+	if format, err := utils.ImageFormatFromPath(outputPath); err == nil && (format != utils.FormatPNG || c.options.Compression != png.DefaultCompression) {
+		return convertViaImage(ctx, c, inputPath, outputPath, c.options.EncodeOptions())
 	}
 
+	return c.render(ctx, inputPath, outputPath)
+}
+
+// render runs rsvg-convert to write the SVG at inputPath as the PNG outputPath
+func (c *RSVGConverter) render(ctx context.Context, inputPath, outputPath string) error {
 	// Get SVG dimensions to calculate target size
 	origWidth, origHeight, err := c.getSVGDimensions(inputPath)
 	if err != nil {
@@ -64,22 +82,39 @@ func (c *RSVGConverter) ConvertFile(inputPath, outputPath string) error {
 	// Calculate target dimensions
 	width, height := c.options.CalculateDimensions(origWidth, origHeight)
 
+	// Physical units inside the drawing follow the requested density too
+	dpi := c.options.DPI
+	if dpi <= 0 {
+		dpi = DefaultDPI
+	}
+	dpiArg := strconv.FormatFloat(dpi, 'f', -1, 64)
+
 	// Build rsvg-convert command
 	args := []string{
 		"--format", "png",
 		"--width", strconv.Itoa(width),
 		"--height", strconv.Itoa(height),
+		"--dpi-x", dpiArg,
+		"--dpi-y", dpiArg,
 		"--output", outputPath,
-		inputPath,
 	}
+	if c.options.Background != nil {
+		bg := color.NRGBAModel.Convert(c.options.Background).(color.NRGBA)
+		args = append(args, "--background-color", fmt.Sprintf("#%02x%02x%02x%02x", bg.R, bg.G, bg.B, bg.A))
+	}
+	args = append(args, inputPath)
 
-	cmd := exec.Command("rsvg-convert", args...)
+	renderCtx, cancel := c.options.renderContext(ctx)
+	defer cancel()
 
-	if c.options.Verbose {
-		fmt.Printf("Executing: rsvg-convert %s\n", strings.Join(args, " "))
-	}
+	cmd := exec.CommandContext(renderCtx, "rsvg-convert", args...)
+
+	c.options.Logger.Debug("Executing: rsvg-convert %s", strings.Join(args, " "))
 
 	output, err := cmd.CombinedOutput()
+	if renderCtx.Err() != nil {
+		return c.options.renderError(ctx, renderCtx, err)
+	}
 	if err != nil {
 		return fmt.Errorf("rsvg-convert failed: %w\nOutput: %s", err, string(output))
 	}
@@ -88,7 +123,7 @@ func (c *RSVGConverter) ConvertFile(inputPath, outputPath string) error {
 }
 
 // ConvertToImage converts SVG data to an image.Image
-func (c *RSVGConverter) ConvertToImage(svgData []byte) (image.Image, error) {
+func (c *RSVGConverter) ConvertToImage(ctx context.Context, svgData []byte) (image.Image, error) {
 	tmpSVG, err := os.CreateTemp("", "svg2sheet_*.svg")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temporary SVG file: %w", err)
@@ -101,35 +136,24 @@ func (c *RSVGConverter) ConvertToImage(svgData []byte) (image.Image, error) {
 	}
 	tmpSVG.Close()
 
-	tmpPNG, err := os.CreateTemp("", "svg2sheet_*.png")
+	tmpImage, err := os.CreateTemp("", "svg2sheet_*.png")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temporary PNG file: %w", err)
+		return nil, fmt.Errorf("failed to create temporary image file: %w", err)
 	}
-	defer os.Remove(tmpPNG.Name())
-	tmpPNG.Close()
+	defer os.Remove(tmpImage.Name())
+	tmpImage.Close()
 
-	// Convert using ConvertFile
-	if err := c.ConvertFile(tmpSVG.Name(), tmpPNG.Name()); err != nil {
+	// Render with the tool directly: ConvertFile may re-encode through
+	// ConvertToImage, which would come back here
+	if err := c.render(ctx, tmpSVG.Name(), tmpImage.Name()); err != nil {
 		return nil, fmt.Errorf("failed to convert SVG: %w", err)
 	}
 
-	// Read the PNG file back as image.Image
-	pngFile, err := os.Open(tmpPNG.Name())
-	if err != nil {
-		return nil, fmt.Errorf("failed to open converted PNG: %w", err)
-	}
-	defer pngFile.Close()
-
-	img, err := png.Decode(pngFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode PNG: %w", err)
-	}
-
-	return img, nil
+	return decodeRendered(tmpImage.Name())
 }
 
 // GetImageDimensions returns the dimensions of an SVG file
-func (c *RSVGConverter) GetImageDimensions(svgPath string) (int, int, error) {
+func (c *RSVGConverter) GetImageDimensions(ctx context.Context, svgPath string) (int, int, error) {
 	origWidth, origHeight, err := c.getSVGDimensions(svgPath)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to get SVG dimensions: %w", err)
@@ -139,136 +163,13 @@ func (c *RSVGConverter) GetImageDimensions(svgPath string) (int, int, error) {
 	return width, height, nil
 }
 
-// getSVGDimensions gets the original dimensions of an SVG file using rsvg-convert
+// getSVGDimensions reads the original dimensions from the SVG file;
+// rsvg-convert has no option to query them
 func (c *RSVGConverter) getSVGDimensions(svgPath string) (float64, float64, error) {
-	// Use rsvg-convert to get SVG info
-	cmd := exec.Command("rsvg-convert", "--width", "--height", svgPath)
-	output, err := cmd.Output()
-	if err != nil {
-		// If the above fails, try a different approach
-		return c.getSVGDimensionsAlternative(svgPath)
-	}
-
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(lines) < 2 {
-		return c.getSVGDimensionsAlternative(svgPath)
-	}
-
-	width, err := strconv.ParseFloat(strings.TrimSpace(lines[0]), 64)
-	if err != nil {
-		return c.getSVGDimensionsAlternative(svgPath)
-	}
-
-	height, err := strconv.ParseFloat(strings.TrimSpace(lines[1]), 64)
-	if err != nil {
-		return c.getSVGDimensionsAlternative(svgPath)
-	}
-
-	return width, height, nil
-}
-
-// getSVGDimensionsAlternative gets SVG dimensions using a different rsvg-convert approach
-func (c *RSVGConverter) getSVGDimensionsAlternative(svgPath string) (float64, float64, error) {
-	// Try to get dimensions by converting to a 1x1 PNG and checking the natural size
-	// This is a fallback method
-	cmd := exec.Command("rsvg-convert", "--format", "png", "--width", "1", "--height", "1", svgPath)
-
-	// Capture stderr which might contain dimension info
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return 100.0, 100.0, nil // Default fallback
-	}
-
-	if err := cmd.Start(); err != nil {
-		return 100.0, 100.0, nil // Default fallback
-	}
-
-	// Read stderr output
-	stderrOutput := make([]byte, 1024)
-	n, _ := stderr.Read(stderrOutput)
-	stderr.Close()
-	cmd.Wait()
-
-	// Parse stderr for dimension information (this is implementation-specific)
-	stderrStr := string(stderrOutput[:n])
-	if strings.Contains(stderrStr, "x") {
-		// Try to extract dimensions from error messages
-		// This is a best-effort approach
-	}
-
-	// If all else fails, read the SVG file and try to parse dimensions manually
-	return c.parseSVGDimensionsFromFile(svgPath)
-}
-
-// parseSVGDimensionsFromFile manually parses SVG file for dimensions
-func (c *RSVGConverter) parseSVGDimensionsFromFile(svgPath string) (float64, float64, error) {
 	data, err := os.ReadFile(svgPath)
 	if err != nil {
-		return 100.0, 100.0, nil // Default fallback
+		return 0, 0, fmt.Errorf("failed to read SVG file: %w", err)
 	}
 
-	// Use the same parsing logic as the Rod converter
-	return c.parseSVGDimensions(data)
-}
-
-// parseSVGDimensions extracts width and height from SVG data
-func (c *RSVGConverter) parseSVGDimensions(svgData []byte) (float64, float64, error) {
-	svgStr := string(svgData)
-
-	// Default dimensions if not found
-	width, height := 100.0, 100.0
-
-	// Look for viewBox attribute first
-	if viewBoxStart := strings.Index(svgStr, "viewBox=\""); viewBoxStart != -1 {
-		viewBoxStart += 9 // length of "viewBox=\""
-		if viewBoxEnd := strings.Index(svgStr[viewBoxStart:], "\""); viewBoxEnd != -1 {
-			viewBox := svgStr[viewBoxStart : viewBoxStart+viewBoxEnd]
-			parts := strings.Fields(viewBox)
-			if len(parts) >= 4 {
-				// viewBox format: "x y width height"
-				if w, err := parseFloatRSVG(parts[2]); err == nil {
-					width = w
-				}
-				if h, err := parseFloatRSVG(parts[3]); err == nil {
-					height = h
-				}
-			}
-		}
-	}
-
-	// Look for width and height attributes
-	if widthStart := strings.Index(svgStr, "width=\""); widthStart != -1 {
-		widthStart += 7 // length of "width=\""
-		if widthEnd := strings.Index(svgStr[widthStart:], "\""); widthEnd != -1 {
-			widthStr := svgStr[widthStart : widthStart+widthEnd]
-			if w, err := parseFloatRSVG(widthStr); err == nil {
-				width = w
-			}
-		}
-	}
-
-	if heightStart := strings.Index(svgStr, "height=\""); heightStart != -1 {
-		heightStart += 8 // length of "height=\""
-		if heightEnd := strings.Index(svgStr[heightStart:], "\""); heightEnd != -1 {
-			heightStr := svgStr[heightStart : heightStart+heightEnd]
-			if h, err := parseFloatRSVG(heightStr); err == nil {
-				height = h
-			}
-		}
-	}
-
-	return width, height, nil
-}
-
-// parseFloatRSVG parses a float from a string, handling units
-func parseFloatRSVG(s string) (float64, error) {
-	// Remove common SVG units
-	s = strings.TrimSuffix(s, "px")
-	s = strings.TrimSuffix(s, "pt")
-	s = strings.TrimSuffix(s, "em")
-	s = strings.TrimSuffix(s, "rem")
-
-	var result float64
-	_, err := fmt.Sscanf(s, "%f", &result)
-	return result, err
+	return ParseSVGDimensions(data)
 }
@@ -0,0 +1,116 @@
+package svg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SanitizeSVG removes what a renderer could run or fetch from SVG data:
+// <script> elements in any namespace, on* event handler attributes, and
+// href and xlink:href attributes other than references into the document
+// (#id) and data: URIs. Everything else, including whitespace, comments
+// and entities, is kept byte for byte.
+func SanitizeSVG(data []byte) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+
+	var out bytes.Buffer
+	var copied int64 // data up to this offset is written or dropped
+	skipDepth := 0   // nesting within a removed element
+
+	for {
+		start := decoder.InputOffset()
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SVG: %w", err)
+		}
+		end := decoder.InputOffset()
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if skipDepth > 0 {
+				skipDepth++
+				continue
+			}
+			if strings.EqualFold(t.Name.Local, "script") {
+				out.Write(data[copied:start])
+				copied = end
+				skipDepth = 1
+				continue
+			}
+
+			attrs := safeAttrs(t.Attr)
+			if len(attrs) == len(t.Attr) {
+				continue
+			}
+			// Only tags that lose attributes are written anew
+			out.Write(data[copied:start])
+			writeStartTag(&out, t.Name, attrs, bytes.HasSuffix(data[start:end], []byte("/>")))
+			copied = end
+
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				if skipDepth == 0 {
+					copied = end
+				}
+			}
+		}
+	}
+
+	out.Write(data[copied:])
+	return out.Bytes(), nil
+}
+
+// safeAttrs returns the attributes that SanitizeSVG keeps
+func safeAttrs(attrs []xml.Attr) []xml.Attr {
+	kept := make([]xml.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		name := strings.ToLower(attr.Name.Local)
+		if strings.HasPrefix(name, "on") {
+			continue
+		}
+		if name == "href" && !isLocalReference(attr.Value) {
+			continue
+		}
+		kept = append(kept, attr)
+	}
+	return kept
+}
+
+// isLocalReference reports whether an href stays within the document: a
+// fragment such as #icon or a data: URI
+func isLocalReference(href string) bool {
+	href = strings.TrimSpace(href)
+	return strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "data:")
+}
+
+// writeStartTag writes a start tag with the given attributes, keeping
+// namespace prefixes as they were written
+func writeStartTag(out *bytes.Buffer, name xml.Name, attrs []xml.Attr, selfClosing bool) {
+	out.WriteString("<" + qualifiedName(name))
+	for _, attr := range attrs {
+		out.WriteString(" " + qualifiedName(attr.Name) + `="`)
+		xml.EscapeText(out, []byte(attr.Value))
+		out.WriteString(`"`)
+	}
+	if selfClosing {
+		out.WriteString("/>")
+	} else {
+		out.WriteString(">")
+	}
+}
+
+// qualifiedName returns a raw token name with its prefix, such as xlink:href
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
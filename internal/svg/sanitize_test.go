@@ -0,0 +1,99 @@
+package svg
+
+import (
+	"context"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/thanhfphan/svg2sheet/internal/logging"
+)
+
+func TestSanitizeSVG(t *testing.T) {
+	tests := []struct {
+		name string
+		svg  string
+		want string
+	}{
+		{
+			name: "script element",
+			svg:  `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script><rect width="4" height="4"/></svg>`,
+			want: `<svg xmlns="http://www.w3.org/2000/svg"><rect width="4" height="4"/></svg>`,
+		},
+		{
+			name: "script with CDATA and nested markup",
+			svg:  `<svg><script type="text/javascript"><![CDATA[ if (a < b) { x() } ]]><g><script/></g></script><circle r="2"/></svg>`,
+			want: `<svg><circle r="2"/></svg>`,
+		},
+		{
+			name: "self-closing and prefixed scripts",
+			svg:  `<svg xmlns:svg="http://www.w3.org/2000/svg"><script href="evil.js"/><svg:SCRIPT>x()</svg:SCRIPT><path d="M0 0h2"/></svg>`,
+			want: `<svg xmlns:svg="http://www.w3.org/2000/svg"><path d="M0 0h2"/></svg>`,
+		},
+		{
+			name: "event handlers",
+			svg:  `<svg onload="steal()"><rect onclick="x()" ONMOUSEOVER="y()" fill="red" width="4" height="4"/></svg>`,
+			want: `<svg><rect fill="red" width="4" height="4"/></svg>`,
+		},
+		{
+			name: "external references",
+			svg:  `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><image xlink:href="http://example.com/a.png"/><use href="other.svg#icon"/></svg>`,
+			want: `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><image/><use/></svg>`,
+		},
+		{
+			name: "local and data references are kept",
+			svg:  `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="#icon"/><image href=" data:image/png;base64,AAAA"/></svg>`,
+			want: `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="#icon"/><image href=" data:image/png;base64,AAAA"/></svg>`,
+		},
+		{
+			name: "visuals are kept byte for byte",
+			svg: "<?xml version=\"1.0\"?>\n<!-- icon -->\n<svg width='8' height='8'>\n  <defs><linearGradient id=\"g\"><stop offset=\"0\"/></linearGradient></defs>\n" +
+				"  <rect fill=\"url(#g)\" width=\"8\"   height=\"8\"/>\n  <text>a &amp; b</text>\n</svg>\n",
+			want: "<?xml version=\"1.0\"?>\n<!-- icon -->\n<svg width='8' height='8'>\n  <defs><linearGradient id=\"g\"><stop offset=\"0\"/></linearGradient></defs>\n" +
+				"  <rect fill=\"url(#g)\" width=\"8\"   height=\"8\"/>\n  <text>a &amp; b</text>\n</svg>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SanitizeSVG([]byte(tt.svg))
+			if err != nil {
+				t.Fatalf("SanitizeSVG: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeSVGKeepsRendering(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="16" height="16" onload="alert(1)">` +
+		`<script>document.querySelector("rect").setAttribute("fill", "blue")</script>` +
+		`<rect width="16" height="16" fill="#ff0000" onclick="x()"/></svg>`
+
+	sanitized, err := SanitizeSVG([]byte(svg))
+	if err != nil {
+		t.Fatalf("SanitizeSVG: %v", err)
+	}
+	for _, unsafe := range []string{"script", "onload", "onclick", "alert"} {
+		if strings.Contains(string(sanitized), unsafe) {
+			t.Errorf("sanitized SVG still contains %q: %s", unsafe, sanitized)
+		}
+	}
+
+	c := NewOkSVGConverter(&ConversionOptions{Scale: 1, Logger: logging.Discard()})
+	img, err := c.ConvertToImage(context.Background(), sanitized)
+	if err != nil {
+		t.Fatalf("ConvertToImage: %v", err)
+	}
+	if got := color.NRGBAModel.Convert(img.At(8, 8)); got != (color.NRGBA{R: 255, A: 255}) {
+		t.Errorf("center pixel = %v, want the red rect", got)
+	}
+}
+
+func TestSanitizeSVGErrors(t *testing.T) {
+	if _, err := SanitizeSVG([]byte(`<svg><rect width="4`)); err == nil {
+		t.Error("SanitizeSVG accepted truncated XML")
+	}
+}
@@ -0,0 +1,56 @@
+package svg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// SetViewBox gives the root <svg> element of SVG data the viewBox "x y w h"
+// when it has none or one without a positive width and height. A usable
+// viewBox is kept, so the option can be applied to a whole directory of
+// icons. Only the root start tag is rewritten; the rest of the data is kept
+// byte for byte.
+func SetViewBox(data []byte, viewBox string) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+
+	for {
+		start := decoder.InputOffset()
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no <svg> element found")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SVG: %w", err)
+		}
+
+		root, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if root.Name.Local != "svg" {
+			return nil, fmt.Errorf("root element is <%s>, not <svg>", root.Name.Local)
+		}
+
+		attrs := make([]xml.Attr, 0, len(root.Attr)+1)
+		for _, attr := range root.Attr {
+			if attr.Name.Space == "" && attr.Name.Local == "viewBox" {
+				if _, _, usable := parseViewBox(attr.Value); usable {
+					return data, nil
+				}
+				continue
+			}
+			attrs = append(attrs, attr)
+		}
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "viewBox"}, Value: viewBox})
+
+		end := decoder.InputOffset()
+		var out bytes.Buffer
+		out.Write(data[:start])
+		writeStartTag(&out, root.Name, attrs, bytes.HasSuffix(data[start:end], []byte("/>")))
+		out.Write(data[end:])
+		return out.Bytes(), nil
+	}
+}
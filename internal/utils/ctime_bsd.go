@@ -0,0 +1,19 @@
+//go:build darwin || freebsd || netbsd
+
+package utils
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// creationTime returns the file's birth time, falling back to ModTime when
+// the stat data is unavailable
+func creationTime(info os.FileInfo) time.Time {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.ModTime()
+	}
+	return time.Unix(stat.Birthtimespec.Unix())
+}
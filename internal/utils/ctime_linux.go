@@ -0,0 +1,20 @@
+//go:build linux
+
+package utils
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// creationTime returns the file's status change time. Linux stat does not
+// report a birth time, and ctime is only updated by metadata changes, not by
+// later reads. It falls back to ModTime when the stat data is unavailable.
+func creationTime(info os.FileInfo) time.Time {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.ModTime()
+	}
+	return time.Unix(stat.Ctim.Unix())
+}
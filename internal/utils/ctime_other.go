@@ -0,0 +1,13 @@
+//go:build !darwin && !freebsd && !netbsd && !linux && !windows
+
+package utils
+
+import (
+	"os"
+	"time"
+)
+
+// creationTime returns ModTime on platforms that do not expose a creation time
+func creationTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSortByCTime(t *testing.T) {
+	switch runtime.GOOS {
+	case "linux", "darwin", "freebsd", "netbsd", "windows":
+	default:
+		t.Skipf("%s does not expose a creation time", runtime.GOOS)
+	}
+
+	dir := t.TempDir()
+
+	// Files are created out of name order, and each gets a modification time
+	// that runs the other way, so only the creation time gives this order
+	names := []string{"c.png", "a.png", "b.png"}
+	mtime := time.Now().Add(time.Hour)
+	var files []string
+	for i, name := range names {
+		if i > 0 {
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		mtime = mtime.Add(-time.Minute)
+		files = append(files, path)
+	}
+
+	// Coarse timestamps on some filesystems can make the files tie
+	var last time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctime := creationTime(info)
+		if !ctime.After(last) {
+			t.Skip("filesystem creation times are too coarse to order the files")
+		}
+		last = ctime
+	}
+
+	// Hand the files over in name order so the sort has to move them
+	sorted, err := sortByCTime([]string{files[1], files[2], files[0]})
+	if err != nil {
+		t.Fatalf("sortByCTime: %v", err)
+	}
+
+	for i, want := range files {
+		if sorted[i] != want {
+			t.Errorf("position %d = %s, want %s", i, filepath.Base(sorted[i]), filepath.Base(want))
+		}
+	}
+}
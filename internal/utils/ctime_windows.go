@@ -0,0 +1,19 @@
+//go:build windows
+
+package utils
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// creationTime returns the file's creation time, falling back to ModTime when
+// the file attributes are unavailable
+func creationTime(info os.FileInfo) time.Time {
+	attrs, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return info.ModTime()
+	}
+	return time.Unix(0, attrs.CreationTime.Nanoseconds())
+}
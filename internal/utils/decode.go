@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RasterExtensions lists the raster image formats accepted as input next to
+// SVG. They are placed on sheets as they are, without a converter.
+var RasterExtensions = []string{".png", ".jpg", ".jpeg", ".gif"}
+
+// IsRasterInput reports whether path has a raster image extension
+func IsRasterInput(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, rasterExt := range RasterExtensions {
+		if ext == rasterExt {
+			return true
+		}
+	}
+	return false
+}
+
+// IsInputFile reports whether path is an SVG or raster image input
+func IsInputFile(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".svg" || IsRasterInput(path)
+}
+
+// DecodeImageFile decodes a PNG, JPEG or GIF file with the decoder matching
+// its extension. GIFs yield their first frame. JPEGs and paletted GIFs are
+// converted to RGBA so that they composite like PNGs: opaque formats become
+// fully opaque pixels and a GIF's transparent index becomes transparent.
+func DecodeImageFile(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var img image.Image
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".png":
+		return png.Decode(file)
+	case ".jpg", ".jpeg":
+		img, err = jpeg.Decode(file)
+	case ".gif":
+		img, err = gif.Decode(file)
+	default:
+		return nil, fmt.Errorf("unsupported image format: %s", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba, nil
+}
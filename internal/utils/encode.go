@@ -0,0 +1,235 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/thanhfphan/svg2sheet/internal/config"
+	"github.com/thanhfphan/svg2sheet/internal/logging"
+)
+
+// Output image formats
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatWebP = "webp"
+)
+
+// DefaultQuality is the encoder quality used when none is configured
+const DefaultQuality = 90
+
+// EncodeOptions controls how images are encoded to disk
+type EncodeOptions struct {
+	Quality     int                  // lossy encoder quality, 1-100
+	Compression png.CompressionLevel // PNG compression level, png.DefaultCompression when 0
+	Palette     int                  // write PNGs indexed when they have at most this many colors, 0 for never
+	Background  color.Color          // color used to flatten transparency for formats without alpha
+	MaxBytes    int64                // maximum encoded size in bytes, 0 for no limit
+	Logger      logging.Logger       // reports how images were shrunk to fit MaxBytes, nil to discard
+}
+
+// NewEncodeOptions creates EncodeOptions from config
+func NewEncodeOptions(cfg *config.Config, log logging.Logger) EncodeOptions {
+	return EncodeOptions{
+		Quality:     cfg.Quality,
+		Compression: config.PNGCompression(cfg.PNGCompression).Level(),
+		MaxBytes:    cfg.MaxFileBytes,
+		Logger:      log,
+	}
+}
+
+// logger returns the logger of the options, one that discards every message
+// when none is set
+func (opts EncodeOptions) logger() logging.Logger {
+	if opts.Logger == nil {
+		return logging.Discard()
+	}
+	return opts.Logger
+}
+
+// ImageFormatFromPath returns the output format implied by a file extension
+func ImageFormatFromPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		return FormatPNG, nil
+	case ".jpg", ".jpeg":
+		return FormatJPEG, nil
+	case ".webp":
+		return FormatWebP, nil
+	default:
+		return "", fmt.Errorf("unsupported output format: %s", filepath.Ext(path))
+	}
+}
+
+// SaveImage encodes an image to outputPath using the format implied by its
+// extension, reducing quality as needed to stay within opts.MaxBytes. The
+// image is composited over opts.Background first when one is set.
+func SaveImage(img image.Image, outputPath string, opts EncodeOptions) error {
+	format, err := ImageFormatFromPath(outputPath)
+	if err != nil {
+		return err
+	}
+
+	data, err := encodeOutput(img, format, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", outputPath, err)
+	}
+
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	return nil
+}
+
+// WriteImage is like SaveImage but writes the encoded image to w in the given
+// format, e.g. to stream it to standard output
+func WriteImage(w io.Writer, img image.Image, format string, opts EncodeOptions) error {
+	data, err := encodeOutput(img, format, opts)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+
+	return nil
+}
+
+// encodeOutput flattens and encodes an image the way SaveImage and
+// WriteImage store it
+func encodeOutput(img image.Image, format string, opts EncodeOptions) ([]byte, error) {
+	if opts.Background != nil {
+		img = FlattenImage(img, opts.Background)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeImage(&buf, img, format, opts); err != nil {
+		return nil, err
+	}
+
+	data := buf.Bytes()
+	if opts.MaxBytes > 0 && int64(len(data)) > opts.MaxBytes {
+		return encodeWithinBudget(img, format, opts)
+	}
+
+	return data, nil
+}
+
+// encodeWithinBudget re-encodes an image that exceeded opts.MaxBytes. Lossy
+// formats binary-search the highest quality that fits; lossless formats
+// (PNG, WebP) fall back to palette quantization.
+func encodeWithinBudget(img image.Image, format string, opts EncodeOptions) ([]byte, error) {
+	switch format {
+	case FormatJPEG:
+		hi := opts.Quality
+		if hi == 0 {
+			hi = DefaultQuality
+		}
+
+		var best []byte
+		bestQuality := 0
+		lo := 1
+		for lo <= hi {
+			mid := (lo + hi) / 2
+
+			var buf bytes.Buffer
+			trial := opts
+			trial.Quality = mid
+			if err := EncodeImage(&buf, img, format, trial); err != nil {
+				return nil, err
+			}
+
+			if int64(buf.Len()) <= opts.MaxBytes {
+				best, bestQuality = buf.Bytes(), mid
+				lo = mid + 1
+			} else {
+				hi = mid - 1
+			}
+		}
+
+		if best == nil {
+			return nil, fmt.Errorf("cannot fit within %d bytes even at quality 1", opts.MaxBytes)
+		}
+
+		opts.logger().Debug("Reduced quality to %d to fit within %d bytes (%d bytes)", bestQuality, opts.MaxBytes, len(best))
+		return best, nil
+
+	case FormatPNG, FormatWebP:
+		for _, colors := range []int{256, 128, 64, 32, 16} {
+			var buf bytes.Buffer
+			if err := EncodeImage(&buf, QuantizeImage(img, colors), format, opts); err != nil {
+				return nil, err
+			}
+
+			if int64(buf.Len()) <= opts.MaxBytes {
+				opts.logger().Debug("Quantized %s to %d colors to fit within %d bytes (%d bytes)", strings.ToUpper(format), colors, opts.MaxBytes, buf.Len())
+				return buf.Bytes(), nil
+			}
+		}
+
+		return nil, fmt.Errorf("cannot fit within %d bytes even with a 16-color palette", opts.MaxBytes)
+
+	default:
+		return nil, fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// EncodeImage writes an image to w in the given format
+func EncodeImage(w io.Writer, img image.Image, format string, opts EncodeOptions) error {
+	switch format {
+	case FormatPNG:
+		if opts.Palette > 0 {
+			img = paletteImage(img, opts.Palette, opts.logger())
+		}
+
+		encoder := png.Encoder{CompressionLevel: opts.Compression}
+		if err := encoder.Encode(w, img); err != nil {
+			return fmt.Errorf("failed to encode PNG: %w", err)
+		}
+	case FormatJPEG:
+		quality := opts.Quality
+		if quality == 0 {
+			quality = DefaultQuality
+		}
+
+		background := opts.Background
+		if background == nil {
+			background = color.White
+		}
+
+		if err := jpeg.Encode(w, FlattenImage(img, background), &jpeg.Options{Quality: quality}); err != nil {
+			return fmt.Errorf("failed to encode JPEG: %w", err)
+		}
+	case FormatWebP:
+		// WebP is always written lossless (VP8L) by the pure Go encoder, so
+		// Quality does not apply
+		if err := nativewebp.Encode(w, img, nil); err != nil {
+			return fmt.Errorf("failed to encode WebP: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+
+	return nil
+}
+
+// FlattenImage composites an image over a solid background color
+func FlattenImage(img image.Image, background color.Color) image.Image {
+	bounds := img.Bounds()
+	result := image.NewRGBA(bounds)
+	draw.Draw(result, bounds, image.NewUniform(background), image.Point{}, draw.Src)
+	draw.Draw(result, bounds, img, bounds.Min, draw.Over)
+	return result
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+)
+
+// sheetImage returns a size x size image of 64x64 tiles holding gradients,
+// which compress about as well as rendered sprites do
+func sheetImage(size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			tx, ty := x%64, y%64
+			if (tx-32)*(tx-32)+(ty-32)*(ty-32) > 28*28 {
+				continue
+			}
+			img.SetRGBA(x, y, color.RGBA{R: uint8(4 * tx), G: uint8(4 * ty), B: uint8(x / 8), A: 255})
+		}
+	}
+	return img
+}
+
+func TestPNGCompressionLevels(t *testing.T) {
+	img := sheetImage(256)
+
+	sizes := make(map[config.PNGCompression]int)
+	for _, level := range []config.PNGCompression{config.PNGNone, config.PNGSpeed, config.PNGDefault, config.PNGBest} {
+		var buf bytes.Buffer
+		if err := EncodeImage(&buf, img, FormatPNG, EncodeOptions{Compression: level.Level()}); err != nil {
+			t.Fatalf("%s: EncodeImage: %v", level, err)
+		}
+		sizes[level] = buf.Len()
+
+		// Every level is lossless
+		decoded, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatalf("%s: decoding: %v", level, err)
+		}
+		for _, p := range []image.Point{{0, 0}, {32, 32}, {100, 77}, {255, 255}} {
+			want := color.NRGBAModel.Convert(img.At(p.X, p.Y))
+			if got := color.NRGBAModel.Convert(decoded.At(p.X, p.Y)); got != want {
+				t.Errorf("%s: pixel %v = %v, want %v", level, p, got, want)
+			}
+		}
+	}
+
+	if !(sizes[config.PNGBest] <= sizes[config.PNGSpeed] && sizes[config.PNGSpeed] < sizes[config.PNGNone]) {
+		t.Errorf("encoded sizes best %d, speed %d, none %d; want them in that order",
+			sizes[config.PNGBest], sizes[config.PNGSpeed], sizes[config.PNGNone])
+	}
+}
+
+func BenchmarkPNGCompression(b *testing.B) {
+	img := sheetImage(2048)
+
+	for _, level := range []config.PNGCompression{config.PNGSpeed, config.PNGDefault, config.PNGBest} {
+		b.Run(string(level), func(b *testing.B) {
+			opts := EncodeOptions{Compression: level.Level()}
+			var buf bytes.Buffer
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				if err := EncodeImage(&buf, img, FormatPNG, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(buf.Len()), "bytes")
+		})
+	}
+}
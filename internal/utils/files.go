@@ -1,11 +1,15 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"image"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/thanhfphan/svg2sheet/internal/config"
@@ -20,9 +24,20 @@ type FileInfo struct {
 
 // FileMapping holds the mapping between original files and processed PNG files
 type FileMapping struct {
-	PNGPath      string
+	PNGPath      string // rendered PNG of an SVG, or the raster input itself
 	OriginalPath string
 	IsTemporary  bool
+	Err          error       // why rendering failed, when --skip-errors left the file out
+	TileSize     image.Point // tile size from --manifest; zero axes use the configured size
+	Name         string      // sprite name, the file name of OriginalPath without extension when empty
+}
+
+// SpriteName returns the name of the sprite made from the mapping
+func (m FileMapping) SpriteName() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return GetFileNameWithoutExt(m.OriginalPath)
 }
 
 // SortFiles sorts files according to the specified mode
@@ -34,11 +49,17 @@ func SortFiles(files []string, mode config.SortMode) ([]string, error) {
 	switch mode {
 	case config.SortByName:
 		return sortByName(files), nil
+	case config.SortNatural:
+		return sortNatural(files), nil
 	case config.SortByCTime:
 		return sortByCTime(files)
 	case config.SortManual:
 		// Manual sorting - return as-is (user should provide files in desired order)
 		return files, nil
+	case config.SortByFileSize, config.SortBySize, config.SortBySizeDesc:
+		// Sizes are only known after conversion (see SortMappingsByFileSize
+		// and SortMappingsBySize), so use name order as a stable starting point
+		return sortByName(files), nil
 	default:
 		return nil, fmt.Errorf("unsupported sort mode: %s", mode)
 	}
@@ -49,7 +70,8 @@ func sortByName(files []string) []string {
 	sorted := make([]string, len(files))
 	copy(sorted, files)
 
-	sort.Slice(sorted, func(i, j int) bool {
+	// Stable, so files of the same name keep the order they were found in
+	sort.SliceStable(sorted, func(i, j int) bool {
 		nameI := filepath.Base(sorted[i])
 		nameJ := filepath.Base(sorted[j])
 		return nameI < nameJ
@@ -58,7 +80,68 @@ func sortByName(files []string) []string {
 	return sorted
 }
 
-// sortByCTime sorts files by creation/modification time
+// sortNatural sorts files by filename, comparing runs of digits numerically
+func sortNatural(files []string) []string {
+	sorted := make([]string, len(files))
+	copy(sorted, files)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return NaturalLess(filepath.Base(sorted[i]), filepath.Base(sorted[j]))
+	})
+
+	return sorted
+}
+
+// NaturalLess reports whether a sorts before b when runs of digits are
+// compared by numeric value, e.g. "frame_2" before "frame_10". Numbers that
+// are equal in value but differ in leading zeros sort shorter first.
+func NaturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		aDigits, bDigits := isDigit(a[0]), isDigit(b[0])
+
+		if aDigits && bDigits {
+			aRun, bRun := digitRun(a), digitRun(b)
+			aNum, bNum := strings.TrimLeft(aRun, "0"), strings.TrimLeft(bRun, "0")
+
+			if len(aNum) != len(bNum) {
+				return len(aNum) < len(bNum)
+			}
+			if aNum != bNum {
+				return aNum < bNum
+			}
+			if len(aRun) != len(bRun) {
+				return len(aRun) < len(bRun)
+			}
+
+			a, b = a[len(aRun):], b[len(bRun):]
+			continue
+		}
+
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+
+	return len(a) < len(b)
+}
+
+// isDigit reports whether c is an ASCII digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// digitRun returns the leading run of digits in s
+func digitRun(s string) string {
+	end := 0
+	for end < len(s) && isDigit(s[end]) {
+		end++
+	}
+	return s[:end]
+}
+
+// sortByCTime sorts files by creation time, using ModTime on platforms
+// without one (see creationTime)
 func sortByCTime(files []string) ([]string, error) {
 	fileInfos := make([]FileInfo, 0, len(files))
 
@@ -71,12 +154,12 @@ func sortByCTime(files []string) ([]string, error) {
 		fileInfos = append(fileInfos, FileInfo{
 			Path:  file,
 			Name:  filepath.Base(file),
-			CTime: info.ModTime(), // Use ModTime as creation time approximation
+			CTime: creationTime(info),
 		})
 	}
 
 	// Sort by creation time
-	sort.Slice(fileInfos, func(i, j int) bool {
+	sort.SliceStable(fileInfos, func(i, j int) bool {
 		return fileInfos[i].CTime.Before(fileInfos[j].CTime)
 	})
 
@@ -89,6 +172,108 @@ func sortByCTime(files []string) ([]string, error) {
 	return sorted, nil
 }
 
+// SortMappingsByFileSize orders file mappings by the byte size of their PNG
+// files, smallest first; files of equal size keep their relative order
+func SortMappingsByFileSize(mappings []FileMapping) ([]FileMapping, error) {
+	sizes := make(map[string]int64, len(mappings))
+	for _, mapping := range mappings {
+		info, err := os.Stat(mapping.PNGPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat file %s: %w", mapping.PNGPath, err)
+		}
+		sizes[mapping.PNGPath] = info.Size()
+	}
+
+	sorted := make([]FileMapping, len(mappings))
+	copy(sorted, mappings)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sizes[sorted[i].PNGPath] < sizes[sorted[j].PNGPath]
+	})
+
+	return sorted, nil
+}
+
+// SortMappingsBySize orders file mappings by the pixel area of their PNG
+// files, smallest first or, with descending, largest first. With --trim the
+// area of the content left by trimming is used, after --color-key made its
+// key color transparent. Files of equal area keep their relative order.
+func SortMappingsBySize(mappings []FileMapping, descending bool, cfg *config.Config) ([]FileMapping, error) {
+	areas := make(map[string]int, len(mappings))
+	for _, mapping := range mappings {
+		size, err := spriteSize(mapping.PNGPath, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure file %s: %w", mapping.PNGPath, err)
+		}
+		areas[mapping.PNGPath] = size.X * size.Y
+	}
+
+	sorted := make([]FileMapping, len(mappings))
+	copy(sorted, mappings)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if descending {
+			return areas[sorted[i].PNGPath] > areas[sorted[j].PNGPath]
+		}
+		return areas[sorted[i].PNGPath] < areas[sorted[j].PNGPath]
+	})
+
+	return sorted, nil
+}
+
+// MaxSpriteSize returns the largest width and height among the sprites of
+// mappings, measured as SortMappingsBySize does and including the margin
+// --trim-margin adds. Axes a mapping's TileSize sets are left out, since the
+// sprite is fitted to its own tile there.
+func MaxSpriteSize(mappings []FileMapping, cfg *config.Config) (image.Point, error) {
+	var largest image.Point
+	for _, mapping := range mappings {
+		size, err := spriteSize(mapping.PNGPath, cfg)
+		if err != nil {
+			return image.Point{}, fmt.Errorf("failed to measure file %s: %w", mapping.PNGPath, err)
+		}
+		if cfg.Trim {
+			size = size.Add(image.Pt(2*cfg.TrimMargin, 2*cfg.TrimMargin))
+		}
+
+		if mapping.TileSize.X == 0 && size.X > largest.X {
+			largest.X = size.X
+		}
+		if mapping.TileSize.Y == 0 && size.Y > largest.Y {
+			largest.Y = size.Y
+		}
+	}
+	return largest, nil
+}
+
+// spriteSize returns the pixel size of an image file, or with --trim of its
+// content left by trimming. Untrimmed sizes are read from the header alone.
+func spriteSize(path string, cfg *config.Config) (image.Point, error) {
+	if cfg.Trim {
+		img, err := DecodeImageFile(path)
+		if err != nil {
+			return image.Point{}, err
+		}
+		// Validated by Config.Validate
+		if key, _ := cfg.KeyColor(); key != nil {
+			img = KeyColorToAlpha(img, key, uint8(cfg.KeyTolerance))
+		}
+		return ContentBounds(img, uint8(cfg.TrimThreshold)).Size(), nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return image.Point{}, err
+	}
+	defer file.Close()
+
+	size, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return image.Point{}, err
+	}
+	return image.Pt(size.Width, size.Height), nil
+}
+
 // CopyFile copies a file from src to dst
 func CopyFile(src, dst string) error {
 	srcFile, err := os.Open(src)
@@ -170,6 +355,35 @@ func GetFileNameWithoutExt(path string) string {
 	return base[:len(base)-len(ext)]
 }
 
+// PagePath returns the file path of one page of a spritesheet that was split
+// into pages, e.g. sheet.png becomes sheet_0.png, sheet_1.png, ...
+func PagePath(path string, page int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_%d%s", path[:len(path)-len(ext)], page, ext)
+}
+
+// IsOutputPath reports whether path is written by a run with the given
+// output: the output file itself, one of its pages, or a file inside an
+// output directory
+func IsOutputPath(path, output string) bool {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	output, err = filepath.Abs(output)
+	if err != nil {
+		return false
+	}
+
+	if path == output || strings.HasPrefix(path, output+string(filepath.Separator)) {
+		return true
+	}
+
+	ext := filepath.Ext(output)
+	matched, _ := filepath.Match(strings.TrimSuffix(output, ext)+"_[0-9]*"+ext, path)
+	return matched
+}
+
 // ListFiles returns all files in a directory with the given extensions
 func ListFiles(dir string, extensions []string) ([]string, error) {
 	var files []string
@@ -197,12 +411,28 @@ func ListFiles(dir string, extensions []string) ([]string, error) {
 	return files, err
 }
 
+// InputRoot returns the input directory among roots that holds path, or the
+// first of them when none does
+func InputRoot(path string, roots []string) string {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return root
+		}
+	}
+	return roots[0]
+}
+
 // ValidateInputPath validates that an input path exists and is accessible
 func ValidateInputPath(path string) error {
 	if path == "" {
 		return fmt.Errorf("input path cannot be empty")
 	}
 
+	if path == config.StdioPath {
+		return nil
+	}
+
 	info, err := os.Stat(path)
 	if os.IsNotExist(err) {
 		return fmt.Errorf("input path does not exist: %s", path)
@@ -212,45 +442,53 @@ func ValidateInputPath(path string) error {
 	}
 
 	if info.IsDir() {
-		entries, err := os.ReadDir(path)
-		if err != nil {
-			return fmt.Errorf("cannot read directory %s: %w", path, err)
-		}
-
+		// Inputs are collected from subdirectories too, so look through them
 		hasValidFiles := false
-		for _, entry := range entries {
-			if !entry.IsDir() {
-				ext := filepath.Ext(entry.Name())
-				if ext == ".svg" || ext == ".png" {
-					hasValidFiles = true
-					break
-				}
+		err := filepath.WalkDir(path, func(entryPath string, entry os.DirEntry, err error) error {
+			if err != nil {
+				return err
 			}
+			if !entry.IsDir() && IsInputFile(entry.Name()) {
+				hasValidFiles = true
+				return filepath.SkipAll
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("cannot read directory %s: %w", path, err)
 		}
 
 		if !hasValidFiles {
-			return fmt.Errorf("directory %s contains no valid SVG or PNG files", path)
+			return fmt.Errorf("directory %s contains no SVG, PNG, JPEG or GIF files", path)
 		}
 	} else {
-		ext := filepath.Ext(path)
-		if ext != ".svg" && ext != ".png" {
-			return fmt.Errorf("file %s must be an SVG or PNG file", path)
+		if !IsInputFile(path) {
+			return fmt.Errorf("file %s must be an SVG, PNG, JPEG or GIF file", path)
 		}
 	}
 
 	return nil
 }
 
-// ValidateOutputPath validates that an output path is writable
-func ValidateOutputPath(path string, force bool) error {
+// ValidateOutputPath validates that an output path is writable. A dry run
+// writes nothing, so it only checks that no existing file would be replaced.
+func ValidateOutputPath(path string, force, dryRun bool) error {
 	if path == "" {
 		return fmt.Errorf("output path cannot be empty")
 	}
 
+	if path == config.StdioPath {
+		return nil
+	}
+
 	if FileExists(path) && !force {
 		return fmt.Errorf("output file already exists: %s (use --force to overwrite)", path)
 	}
 
+	if dryRun {
+		return nil
+	}
+
 	parentDir := filepath.Dir(path)
 	if err := EnsureDir(parentDir); err != nil {
 		return fmt.Errorf("cannot create output directory: %w", err)
@@ -265,3 +503,19 @@ func ValidateOutputPath(path string, force bool) error {
 
 	return nil
 }
+
+// FileSHA256 returns the hex SHA-256 of a file's contents
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"time"
+)
+
+// SaveGIF writes frames as an animated GIF that loops forever, showing each
+// frame for delay. GIF delays are counted in hundredths of a second, so
+// delay is rounded to that, and at least one is used.
+func SaveGIF(frames []image.Image, delay time.Duration, outputPath string) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames to write")
+	}
+
+	centis := int((delay + 5*time.Millisecond) / (10 * time.Millisecond))
+	if centis < 1 {
+		centis = 1
+	}
+
+	anim := &gif.GIF{}
+	for _, frame := range frames {
+		anim.Image = append(anim.Image, GIFFrame(frame))
+		anim.Delay = append(anim.Delay, centis)
+		// Clear each frame before the next so transparent areas stay clear
+		anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", outputPath, err)
+	}
+
+	if err := gif.EncodeAll(file, anim); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to encode GIF: %w", err)
+	}
+
+	return file.Close()
+}
+
+// GIFFrame converts an image to a GIF frame. GIF has one fully transparent
+// palette entry and no partial transparency, so pixels at least half opaque
+// become opaque and the rest transparent. The opaque colors are reduced to
+// the other 255 entries with QuantizeImage.
+func GIFFrame(img image.Image) *image.Paletted {
+	bounds := img.Bounds()
+
+	// Collect the opaque pixels, without their alpha, to build the palette from
+	var opaque []color.NRGBA
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A >= 128 {
+				c.A = 255
+				opaque = append(opaque, c)
+			}
+		}
+	}
+
+	pal := color.Palette{color.Transparent}
+	var colors color.Palette
+	if len(opaque) > 0 {
+		strip := image.NewNRGBA(image.Rect(0, 0, len(opaque), 1))
+		for i, c := range opaque {
+			strip.SetNRGBA(i, 0, c)
+		}
+		colors = QuantizeImage(strip, 255).Palette
+		pal = append(pal, colors...)
+	}
+
+	result := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), pal)
+	indexes := make(map[color.NRGBA]uint8)
+	next := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A < 128 {
+				// Index 0 is the transparent entry, which NewPaletted starts with
+				continue
+			}
+
+			c = opaque[next]
+			next++
+			index, ok := indexes[c]
+			if !ok {
+				index = uint8(1 + colors.Index(c))
+				indexes[c] = index
+			}
+			result.SetColorIndex(x-bounds.Min.X, y-bounds.Min.Y, index)
+		}
+	}
+
+	return result
+}
@@ -4,63 +4,39 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
+	"math"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
 )
 
 // TrimTransparent removes transparent edges from an image
 func TrimTransparent(img image.Image) image.Image {
-	bounds := img.Bounds()
-
-	// Find the actual content bounds by scanning for non-transparent pixels
-	minX, minY := bounds.Max.X, bounds.Max.Y
-	maxX, maxY := bounds.Min.X, bounds.Min.Y
-
-	found := false
+	trimmed, _ := TrimTransparentRect(img, 0)
+	return trimmed
+}
 
-	// Scan the image to find non-transparent pixels
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			_, _, _, a := img.At(x, y).RGBA()
-			if a > 0 { // Non-transparent pixel found
-				if !found {
-					minX, minY = x, y
-					maxX, maxY = x, y
-					found = true
-				} else {
-					if x < minX {
-						minX = x
-					}
-					if x > maxX {
-						maxX = x
-					}
-					if y < minY {
-						minY = y
-					}
-					if y > maxY {
-						maxY = y
-					}
-				}
-			}
-		}
-	}
+// TrimTransparentRect removes transparent edges from an image and also returns
+// the area of the source image, relative to its top-left corner, that was kept.
+// Pixels with an alpha at or below threshold count as transparent.
+func TrimTransparentRect(img image.Image, threshold uint8) (image.Image, image.Rectangle) {
+	bounds := img.Bounds()
+	content := ContentBounds(img, threshold)
 
 	// If no non-transparent pixels found, return a 1x1 transparent image
-	if !found {
+	if content.Empty() {
 		result := image.NewRGBA(image.Rect(0, 0, 1, 1))
-		return result
+		return result, image.Rect(0, 0, 1, 1)
 	}
 
-	// Create new image with trimmed bounds
-	trimmedBounds := image.Rect(0, 0, maxX-minX+1, maxY-minY+1)
-	result := image.NewRGBA(trimmedBounds)
-
 	// Copy the non-transparent region
-	for y := minY; y <= maxY; y++ {
-		for x := minX; x <= maxX; x++ {
-			result.Set(x-minX, y-minY, img.At(x, y))
-		}
-	}
+	result := image.NewRGBA(image.Rect(0, 0, content.Dx(), content.Dy()))
+	draw.Draw(result, result.Bounds(), img, content.Min, draw.Src)
 
-	return result
+	return result, content.Sub(bounds.Min)
 }
 
 // ResizeImage resizes an image to the specified dimensions using nearest neighbor
@@ -101,8 +77,33 @@ func ResizeImage(img image.Image, width, height int) image.Image {
 	return result
 }
 
-// ResizeImageWithAspectRatio resizes an image while maintaining aspect ratio
-func ResizeImageWithAspectRatio(img image.Image, maxWidth, maxHeight int) image.Image {
+// ResizeImageFilter resizes an image to the specified dimensions with the
+// given filter. Nearest neighbor keeps hard pixel edges; bilinear and
+// Catmull-Rom smooth the result and avoid aliasing when downscaling.
+func ResizeImageFilter(img image.Image, width, height int, filter config.ResizeFilter) image.Image {
+	var scaler xdraw.Scaler
+	switch filter {
+	case config.ResizeBilinear:
+		scaler = xdraw.BiLinear
+	case config.ResizeCatmullRom:
+		scaler = xdraw.CatmullRom
+	default:
+		return ResizeImage(img, width, height)
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() == width && bounds.Dy() == height {
+		return img
+	}
+
+	result := image.NewRGBA(image.Rect(0, 0, width, height))
+	scaler.Scale(result, result.Bounds(), img, bounds, xdraw.Src, nil)
+	return result
+}
+
+// ResizeImageWithAspectRatio resizes an image with the given filter so it
+// fits within the maximum dimensions while maintaining aspect ratio
+func ResizeImageWithAspectRatio(img image.Image, maxWidth, maxHeight int, filter config.ResizeFilter) image.Image {
 	bounds := img.Bounds()
 	srcWidth := bounds.Dx()
 	srcHeight := bounds.Dy()
@@ -116,29 +117,35 @@ func ResizeImageWithAspectRatio(img image.Image, maxWidth, maxHeight int) image.
 	}
 
 	// Calculate new dimensions
-	newWidth := int(float64(srcWidth) * scale)
-	newHeight := int(float64(srcHeight) * scale)
+	newWidth := max(1, int(float64(srcWidth)*scale))
+	newHeight := max(1, int(float64(srcHeight)*scale))
 
-	return ResizeImage(img, newWidth, newHeight)
+	return ResizeImageFilter(img, newWidth, newHeight, filter)
 }
 
 // CenterImage centers an image within a canvas of the specified size
 func CenterImage(img image.Image, canvasWidth, canvasHeight int) image.Image {
+	canvas, _ := PlaceImage(img, canvasWidth, canvasHeight, 0.5, 0.5)
+	return canvas
+}
+
+// PlaceImage draws an image unscaled on a transparent canvas of the specified
+// size. alignX and alignY position it from 0 (left/top) to 1 (right/bottom).
+// It returns the canvas and the image's top-left corner within it.
+func PlaceImage(img image.Image, canvasWidth, canvasHeight int, alignX, alignY float64) (image.Image, image.Point) {
 	bounds := img.Bounds()
 	imgWidth := bounds.Dx()
 	imgHeight := bounds.Dy()
 
 	canvas := image.NewRGBA(image.Rect(0, 0, canvasWidth, canvasHeight))
 
-	// Calculate center position
-	x := (canvasWidth - imgWidth) / 2
-	y := (canvasHeight - imgHeight) / 2
+	x := int(float64(canvasWidth-imgWidth) * alignX)
+	y := int(float64(canvasHeight-imgHeight) * alignY)
 
-	// Draw image centered on canvas
 	destRect := image.Rect(x, y, x+imgWidth, y+imgHeight)
 	draw.Draw(canvas, destRect, img, bounds.Min, draw.Over)
 
-	return canvas
+	return canvas, image.Pt(x, y)
 }
 
 // PadImage adds padding around an image
@@ -159,6 +166,163 @@ func PadImage(img image.Image, padding int) image.Image {
 	return result
 }
 
+// ExtrudeEdges repeats the edge pixels of the area rect of img outward by n
+// pixels on every side, clipped to the image bounds. Corners take the color
+// of the nearest corner pixel.
+func ExtrudeEdges(img *image.RGBA, rect image.Rectangle, n int) {
+	if n <= 0 || rect.Empty() {
+		return
+	}
+
+	outer := rect.Inset(-n).Intersect(img.Bounds())
+	for y := outer.Min.Y; y < outer.Max.Y; y++ {
+		for x := outer.Min.X; x < outer.Max.X; x++ {
+			if image.Pt(x, y).In(rect) {
+				continue
+			}
+			srcX := min(max(x, rect.Min.X), rect.Max.X-1)
+			srcY := min(max(y, rect.Min.Y), rect.Max.Y-1)
+			img.SetRGBA(x, y, img.RGBAAt(srcX, srcY))
+		}
+	}
+}
+
+// DrawOutline draws a 1px border of color c just inside rect, clipped to the
+// image bounds
+func DrawOutline(img *image.RGBA, rect image.Rectangle, c color.Color) {
+	rect = rect.Intersect(img.Bounds())
+	if rect.Empty() {
+		return
+	}
+
+	fill := image.NewUniform(c)
+	for _, edge := range []image.Rectangle{
+		image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y+1),
+		image.Rect(rect.Min.X, rect.Max.Y-1, rect.Max.X, rect.Max.Y),
+		image.Rect(rect.Min.X, rect.Min.Y, rect.Min.X+1, rect.Max.Y),
+		image.Rect(rect.Max.X-1, rect.Min.Y, rect.Max.X, rect.Max.Y),
+	} {
+		draw.Draw(img, edge, fill, image.Point{}, draw.Src)
+	}
+}
+
+// DrawLabel writes text in color c with a 7x13 bitmap font, starting 2px in
+// from the top-left corner of rect and clipped to it
+func DrawLabel(img *image.RGBA, rect image.Rectangle, text string, c color.Color) {
+	clip, ok := img.SubImage(rect).(*image.RGBA)
+	if !ok || clip.Bounds().Empty() {
+		return
+	}
+
+	face := basicfont.Face7x13
+	drawer := &font.Drawer{
+		Dst:  clip,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(rect.Min.X+2, rect.Min.Y+2+face.Ascent),
+	}
+	drawer.DrawString(text)
+}
+
+// FlipImage returns a copy of img mirrored left-right when horizontal is set
+// and top-bottom when vertical is set
+func FlipImage(img image.Image, horizontal, vertical bool) *image.RGBA {
+	bounds := img.Bounds()
+	flipped := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+
+	for y := 0; y < bounds.Dy(); y++ {
+		dy := y
+		if vertical {
+			dy = bounds.Dy() - 1 - y
+		}
+		for x := 0; x < bounds.Dx(); x++ {
+			dx := x
+			if horizontal {
+				dx = bounds.Dx() - 1 - x
+			}
+			flipped.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+
+	return flipped
+}
+
+// RotateClockwise returns img turned 90 degrees clockwise: a w x h image
+// becomes h x w, with its left column as the top row
+func RotateClockwise(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	rotated := image.NewRGBA(image.Rect(0, 0, bounds.Dy(), bounds.Dx()))
+
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			rotated.Set(bounds.Dy()-1-y, x, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+
+	return rotated
+}
+
+// KeyColorToAlpha returns a copy of img in which every pixel whose red, green
+// and blue each differ from those of key by at most tolerance is fully
+// transparent, for opaque inputs that mark their background with a key color
+// such as magenta. Other pixels are kept as they are.
+func KeyColorToAlpha(img image.Image, key color.Color, tolerance uint8) *image.NRGBA {
+	k := color.NRGBAModel.Convert(key).(color.NRGBA)
+	bounds := img.Bounds()
+	result := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if channelDiff(c.R, k.R) <= tolerance && channelDiff(c.G, k.G) <= tolerance && channelDiff(c.B, k.B) <= tolerance {
+				c = color.NRGBA{}
+			}
+			result.SetNRGBA(x-bounds.Min.X, y-bounds.Min.Y, c)
+		}
+	}
+
+	return result
+}
+
+// AdjustColors returns a copy of img with its alpha multiplied by opacity
+// and, when tint is not nil, its red, green and blue multiplied by those of
+// tint, so white becomes the tint color and black stays black. An opacity of
+// 1 keeps the alpha as it is.
+func AdjustColors(img image.Image, opacity float64, tint color.Color) *image.NRGBA {
+	t := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	if tint != nil {
+		t = color.NRGBAModel.Convert(tint).(color.NRGBA)
+	}
+	bounds := img.Bounds()
+	result := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			c.R = multiplyChannel(c.R, t.R)
+			c.G = multiplyChannel(c.G, t.G)
+			c.B = multiplyChannel(c.B, t.B)
+			c.A = uint8(math.Round(float64(c.A) * opacity))
+			result.SetNRGBA(x-bounds.Min.X, y-bounds.Min.Y, c)
+		}
+	}
+
+	return result
+}
+
+// multiplyChannel multiplies two color channels as fractions of 255
+func multiplyChannel(a, b uint8) uint8 {
+	return uint8((int(a)*int(b) + 127) / 255)
+}
+
+// channelDiff returns the absolute difference of two color channels
+func channelDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
 // IsTransparent checks if a pixel is transparent
 func IsTransparent(c color.Color) bool {
 	_, _, _, a := c.RGBA()
@@ -167,43 +331,97 @@ func IsTransparent(c color.Color) bool {
 
 // GetImageBounds returns the actual content bounds of an image (excluding transparent areas)
 func GetImageBounds(img image.Image) image.Rectangle {
+	return ContentBounds(img, 0)
+}
+
+// ContentBounds returns the bounds of the pixels of img whose alpha is above
+// threshold, or an empty rectangle when there are none
+func ContentBounds(img image.Image, threshold uint8) image.Rectangle {
 	bounds := img.Bounds()
+	rowOpaque, colOpaque := opaqueScanners(img, threshold)
+
+	// Walk in from each edge and stop at the first row or column with a
+	// visible pixel, so empty borders are the only area scanned in full
+	minY := bounds.Min.Y
+	for minY < bounds.Max.Y && !rowOpaque(minY, bounds.Min.X, bounds.Max.X) {
+		minY++
+	}
+	if minY == bounds.Max.Y {
+		return image.Rect(0, 0, 0, 0)
+	}
 
-	minX, minY := bounds.Max.X, bounds.Max.Y
-	maxX, maxY := bounds.Min.X, bounds.Min.Y
-	found := false
+	maxY := bounds.Max.Y
+	for !rowOpaque(maxY-1, bounds.Min.X, bounds.Max.X) {
+		maxY--
+	}
 
-	// Scan for non-transparent pixels
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			if !IsTransparent(img.At(x, y)) {
-				if !found {
-					minX, minY = x, y
-					maxX, maxY = x, y
-					found = true
-				} else {
-					if x < minX {
-						minX = x
-					}
-					if x > maxX {
-						maxX = x
-					}
-					if y < minY {
-						minY = y
-					}
-					if y > maxY {
-						maxY = y
-					}
+	minX := bounds.Min.X
+	for !colOpaque(minX, minY, maxY) {
+		minX++
+	}
+
+	maxX := bounds.Max.X
+	for !colOpaque(maxX-1, minY, maxY) {
+		maxX--
+	}
+
+	return image.Rect(minX, minY, maxX, maxY)
+}
+
+// opaqueScanners returns functions reporting whether row y between x0 and x1,
+// or column x between y0 and y1, holds a pixel with an alpha above threshold.
+// RGBA and NRGBA images, which is what decoding and rendering produce, are
+// read straight from their pixel slices.
+func opaqueScanners(img image.Image, threshold uint8) (row func(y, x0, x1 int) bool, col func(x, y0, y1 int) bool) {
+	var pix []uint8
+	var stride int
+	var offset func(x, y int) int
+
+	switch src := img.(type) {
+	case *image.RGBA:
+		pix, stride, offset = src.Pix, src.Stride, src.PixOffset
+	case *image.NRGBA:
+		pix, stride, offset = src.Pix, src.Stride, src.PixOffset
+	default:
+		row = func(y, x0, x1 int) bool {
+			for x := x0; x < x1; x++ {
+				if _, _, _, a := img.At(x, y).RGBA(); a>>8 > uint32(threshold) {
+					return true
+				}
+			}
+			return false
+		}
+		col = func(x, y0, y1 int) bool {
+			for y := y0; y < y1; y++ {
+				if _, _, _, a := img.At(x, y).RGBA(); a>>8 > uint32(threshold) {
+					return true
 				}
 			}
+			return false
 		}
+		return row, col
 	}
 
-	if !found {
-		return image.Rect(0, 0, 0, 0)
+	// Alpha is the fourth byte of every pixel
+	row = func(y, x0, x1 int) bool {
+		start, end := offset(x0, y)+3, offset(x1, y)
+		for i := start; i < end; i += 4 {
+			if pix[i] > threshold {
+				return true
+			}
+		}
+		return false
 	}
-
-	return image.Rect(minX, minY, maxX+1, maxY+1)
+	col = func(x, y0, y1 int) bool {
+		start, end := offset(x, y0)+3, offset(x, y1)
+		for i := start; i < end; i += stride {
+			if pix[i] > threshold {
+				return true
+			}
+		}
+		return false
+	}
+	return row, col
 }
 
 // CreateTransparentImage creates a transparent image of the specified size
@@ -0,0 +1,338 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// distinctImage returns a w x h image where every pixel has its own color
+func distinctImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(10 + x*20), G: uint8(10 + y*20), B: 200, A: 255})
+		}
+	}
+	return img
+}
+
+func TestExtrudeEdges(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+	}{
+		{name: "one pixel", n: 1},
+		{name: "several pixels", n: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := distinctImage(4, 3)
+
+			// Place the sprite in a sheet with room for the gutter on every side
+			pad := tt.n + 2
+			sheet := image.NewRGBA(image.Rect(0, 0, 4+2*pad, 3+2*pad))
+			rect := image.Rect(pad, pad, pad+4, pad+3)
+			for y := 0; y < 3; y++ {
+				for x := 0; x < 4; x++ {
+					sheet.SetRGBA(pad+x, pad+y, src.RGBAAt(x, y))
+				}
+			}
+
+			ExtrudeEdges(sheet, rect, tt.n)
+
+			outer := rect.Inset(-tt.n)
+			for y := sheet.Bounds().Min.Y; y < sheet.Bounds().Max.Y; y++ {
+				for x := sheet.Bounds().Min.X; x < sheet.Bounds().Max.X; x++ {
+					var want color.RGBA
+					if image.Pt(x, y).In(outer) {
+						// The nearest sprite pixel, which for corners is the corner pixel
+						sx := min(max(x-pad, 0), 3)
+						sy := min(max(y-pad, 0), 2)
+						want = src.RGBAAt(sx, sy)
+					}
+					if got := sheet.RGBAAt(x, y); got != want {
+						t.Errorf("pixel (%d,%d) = %v, want %v", x, y, got, want)
+					}
+				}
+			}
+
+			// Spot-check the four corners of the gutter
+			corners := map[image.Point]image.Point{
+				{outer.Min.X, outer.Min.Y}:         {0, 0},
+				{outer.Max.X - 1, outer.Min.Y}:     {3, 0},
+				{outer.Min.X, outer.Max.Y - 1}:     {0, 2},
+				{outer.Max.X - 1, outer.Max.Y - 1}: {3, 2},
+			}
+			for at, from := range corners {
+				if got, want := sheet.RGBAAt(at.X, at.Y), src.RGBAAt(from.X, from.Y); got != want {
+					t.Errorf("corner %v = %v, want %v from sprite pixel %v", at, got, want, from)
+				}
+			}
+		})
+	}
+}
+
+func TestExtrudeEdgesClipsToImage(t *testing.T) {
+	sheet := distinctImage(4, 4)
+	want := distinctImage(4, 4)
+
+	// The sprite fills the image, so there is no room to extrude into
+	ExtrudeEdges(sheet, sheet.Bounds(), 2)
+
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if got := sheet.RGBAAt(x, y); got != want.RGBAAt(x, y) {
+				t.Errorf("pixel (%d,%d) = %v, want unchanged %v", x, y, got, want.RGBAAt(x, y))
+			}
+		}
+	}
+}
+
+func TestRotateClockwise(t *testing.T) {
+	// Bounds away from the origin must not shift the result
+	src := image.NewRGBA(image.Rect(5, 7, 8, 9))
+	for y := 7; y < 9; y++ {
+		for x := 5; x < 8; x++ {
+			src.SetRGBA(x, y, color.RGBA{R: uint8(x), G: uint8(y), A: 255})
+		}
+	}
+
+	got := RotateClockwise(src)
+
+	if got.Bounds() != image.Rect(0, 0, 2, 3) {
+		t.Fatalf("bounds = %v, want (0,0)-(2,3)", got.Bounds())
+	}
+
+	// The left column becomes the top row, read bottom to top
+	want := [][]color.RGBA{
+		{{R: 5, G: 8, A: 255}, {R: 5, G: 7, A: 255}},
+		{{R: 6, G: 8, A: 255}, {R: 6, G: 7, A: 255}},
+		{{R: 7, G: 8, A: 255}, {R: 7, G: 7, A: 255}},
+	}
+	for y, row := range want {
+		for x, c := range row {
+			if p := got.RGBAAt(x, y); p != c {
+				t.Errorf("pixel (%d,%d) = %v, want %v", x, y, p, c)
+			}
+		}
+	}
+}
+
+// opaqueImage hides the concrete type of an image, so only the generic
+// image.Image methods are available to the code under test
+type opaqueImage struct {
+	image.Image
+}
+
+// sparseImage returns a mostly empty w x h image with a small opaque blob
+func sparseImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := h/2 - 8; y < h/2+8; y++ {
+		for x := w/3 - 4; x < w/3+12; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: 200, A: 255})
+		}
+	}
+	return img
+}
+
+func TestContentBoundsFastPath(t *testing.T) {
+	img := sparseImage(300, 200)
+	want := image.Rect(96, 92, 112, 108)
+
+	nrgba := image.NewNRGBA(img.Bounds())
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			nrgba.Set(x, y, img.At(x, y))
+		}
+	}
+
+	for name, src := range map[string]image.Image{
+		"RGBA":      img,
+		"NRGBA":     nrgba,
+		"generic":   opaqueImage{img},
+		"sub-image": img.SubImage(image.Rect(50, 50, 250, 150)),
+	} {
+		if got := GetImageBounds(src); got != want {
+			t.Errorf("%s: GetImageBounds = %v, want %v", name, got, want)
+		}
+	}
+
+	if got := GetImageBounds(image.NewRGBA(image.Rect(0, 0, 10, 10))); !got.Empty() {
+		t.Errorf("GetImageBounds of an empty image = %v, want an empty rectangle", got)
+	}
+}
+
+func BenchmarkTrimTransparent(b *testing.B) {
+	img := sparseImage(2048, 2048)
+
+	b.Run("RGBA", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			TrimTransparent(img)
+		}
+	})
+
+	// The At fallback, which every image took before the Pix fast path
+	b.Run("generic", func(b *testing.B) {
+		generic := opaqueImage{img}
+		for i := 0; i < b.N; i++ {
+			TrimTransparent(generic)
+		}
+	})
+}
+
+func BenchmarkGetImageBounds(b *testing.B) {
+	img := sparseImage(2048, 2048)
+
+	b.Run("RGBA", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			GetImageBounds(img)
+		}
+	})
+
+	b.Run("generic", func(b *testing.B) {
+		generic := opaqueImage{img}
+		for i := 0; i < b.N; i++ {
+			GetImageBounds(generic)
+		}
+	})
+}
+
+func TestContentBoundsThreshold(t *testing.T) {
+	// Alpha rises by 40 per ring from the outside in, 255 in the middle
+	img := image.NewNRGBA(image.Rect(0, 0, 9, 9))
+	for y := 0; y < 9; y++ {
+		for x := 0; x < 9; x++ {
+			ring := min(x, y, 8-x, 8-y)
+			alpha := uint8(40 * (ring + 1))
+			if ring == 4 {
+				alpha = 255
+			}
+			img.SetNRGBA(x, y, color.NRGBA{G: 255, A: alpha})
+		}
+	}
+
+	tests := []struct {
+		threshold uint8
+		want      image.Rectangle
+	}{
+		{threshold: 0, want: image.Rect(0, 0, 9, 9)},
+		{threshold: 39, want: image.Rect(0, 0, 9, 9)},
+		{threshold: 40, want: image.Rect(1, 1, 8, 8)},
+		{threshold: 100, want: image.Rect(2, 2, 7, 7)},
+		{threshold: 160, want: image.Rect(4, 4, 5, 5)},
+		{threshold: 255, want: image.Rectangle{}},
+	}
+
+	for _, tt := range tests {
+		for name, src := range map[string]image.Image{"NRGBA": img, "generic": opaqueImage{img}} {
+			if got := ContentBounds(src, tt.threshold); got != tt.want {
+				t.Errorf("%s: ContentBounds at threshold %d = %v, want %v", name, tt.threshold, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestKeyColorToAlpha(t *testing.T) {
+	magenta := color.RGBA{R: 255, B: 255, A: 255}
+
+	// A solid key background with slightly off-key pixels and a sprite
+	src := image.NewRGBA(image.Rect(10, 10, 14, 12))
+	for y := 10; y < 12; y++ {
+		for x := 10; x < 14; x++ {
+			src.SetRGBA(x, y, magenta)
+		}
+	}
+	src.SetRGBA(11, 10, color.RGBA{R: 250, G: 5, B: 251, A: 255}) // within 5
+	src.SetRGBA(12, 10, color.RGBA{R: 249, G: 0, B: 255, A: 255}) // 6 off on red
+	src.SetRGBA(13, 11, color.RGBA{G: 200, B: 40, A: 255})        // sprite pixel
+
+	tests := []struct {
+		tolerance uint8
+		keyed     map[image.Point]bool // pixels of the result made transparent
+	}{
+		{tolerance: 0, keyed: map[image.Point]bool{{0, 0}: true, {3, 0}: true, {0, 1}: true, {1, 1}: true, {2, 1}: true}},
+		{tolerance: 5, keyed: map[image.Point]bool{{0, 0}: true, {1, 0}: true, {3, 0}: true, {0, 1}: true, {1, 1}: true, {2, 1}: true}},
+		{tolerance: 6, keyed: map[image.Point]bool{{0, 0}: true, {1, 0}: true, {2, 0}: true, {3, 0}: true, {0, 1}: true, {1, 1}: true, {2, 1}: true}},
+	}
+
+	for _, tt := range tests {
+		got := KeyColorToAlpha(src, magenta, tt.tolerance)
+		if got.Bounds() != image.Rect(0, 0, 4, 2) {
+			t.Fatalf("bounds = %v, want (0,0)-(4,2)", got.Bounds())
+		}
+
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 4; x++ {
+				want := color.NRGBAModel.Convert(src.At(10+x, 10+y)).(color.NRGBA)
+				if tt.keyed[image.Pt(x, y)] {
+					want = color.NRGBA{}
+				}
+				if p := got.NRGBAAt(x, y); p != want {
+					t.Errorf("tolerance %d: pixel (%d,%d) = %v, want %v", tt.tolerance, x, y, p, want)
+				}
+			}
+		}
+	}
+}
+
+func TestAdjustColors(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(3, 3, 7, 4))
+	src.SetNRGBA(3, 3, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	src.SetNRGBA(4, 3, color.NRGBA{R: 128, G: 128, B: 128, A: 100})
+	src.SetNRGBA(5, 3, color.NRGBA{R: 10, G: 200, B: 30, A: 255})
+	src.SetNRGBA(6, 3, color.NRGBA{})
+
+	orange := color.RGBA{R: 255, G: 128, A: 255}
+
+	tests := []struct {
+		name    string
+		opacity float64
+		tint    color.Color
+		want    []color.NRGBA
+	}{
+		{
+			name:    "unchanged",
+			opacity: 1,
+			want:    []color.NRGBA{{255, 255, 255, 255}, {128, 128, 128, 100}, {10, 200, 30, 255}, {}},
+		},
+		{
+			name:    "half opacity",
+			opacity: 0.5,
+			want:    []color.NRGBA{{255, 255, 255, 128}, {128, 128, 128, 50}, {10, 200, 30, 128}, {}},
+		},
+		{
+			name:    "tint",
+			opacity: 1,
+			tint:    orange,
+			// white becomes the tint, black stays black
+			want: []color.NRGBA{{255, 128, 0, 255}, {128, 64, 0, 100}, {10, 100, 0, 255}, {}},
+		},
+		{
+			name:    "tint and opacity",
+			opacity: 0.25,
+			tint:    orange,
+			want:    []color.NRGBA{{255, 128, 0, 64}, {128, 64, 0, 25}, {10, 100, 0, 64}, {}},
+		},
+		{
+			name:    "invisible",
+			opacity: 0,
+			want:    []color.NRGBA{{255, 255, 255, 0}, {128, 128, 128, 0}, {10, 200, 30, 0}, {}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AdjustColors(src, tt.opacity, tt.tint)
+			if got.Bounds() != image.Rect(0, 0, 4, 1) {
+				t.Fatalf("bounds = %v, want (0,0)-(4,1)", got.Bounds())
+			}
+			for x, want := range tt.want {
+				if p := got.NRGBAAt(x, 0); p != want {
+					t.Errorf("pixel %d = %v, want %v", x, p, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LoadInputList reads an --input-list: one path or glob per line, in the
+// order the files should take. Blank lines and lines starting with # are
+// skipped. Relative paths and globs are taken relative to base. A listed path
+// must be an existing SVG or raster image; a glob expands to the input files
+// it matches, in name order, and must match at least one. Files listed more
+// than once keep their first position.
+func LoadInputList(path, base string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input list: %w", err)
+	}
+	defer file.Close()
+
+	var files []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			files = append(files, name)
+		}
+	}
+
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(base, line)
+		}
+
+		// A file whose name contains [ or * is taken as it is
+		if _, err := os.Stat(line); err == nil || !isGlob(line) {
+			if err := checkListedFile(line); err != nil {
+				return nil, fmt.Errorf("input list %s, line %d: %w", path, lineNo, err)
+			}
+			add(line)
+			continue
+		}
+
+		matches, err := filepath.Glob(line)
+		if err != nil {
+			return nil, fmt.Errorf("input list %s, line %d: invalid glob %s: %w", path, lineNo, line, err)
+		}
+		matched := false
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.Mode().IsRegular() && IsInputFile(match) {
+				add(match)
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("input list %s, line %d: %s matches no SVG or raster images", path, lineNo, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read input list %s: %w", path, err)
+	}
+
+	return files, nil
+}
+
+// isGlob reports whether a listed path contains glob metacharacters
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// checkListedFile returns an error unless path is an existing file with an
+// input extension
+func checkListedFile(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s does not exist", path)
+	}
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a file", path)
+	}
+	if !IsInputFile(path) {
+		return fmt.Errorf("%s is not an SVG, PNG, JPEG or GIF file", path)
+	}
+	return nil
+}
+
+// MatchesFilters reports whether a scanned file passes --include and
+// --exclude, given its path relative to the input directory. Patterns
+// without a slash match the file name alone and others the whole relative
+// path. Exclude patterns win: a file matching one is left out even when an
+// include pattern matches it too.
+func MatchesFilters(rel string, include, exclude []string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range exclude {
+		if matchesFilter(pattern, rel) {
+			return false
+		}
+	}
+
+	if len(include) == 0 {
+		return true
+	}
+	for _, pattern := range include {
+		if matchesFilter(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFilter matches one --include or --exclude pattern against a
+// slash-separated relative path
+func matchesFilter(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		rel = path.Base(rel)
+	}
+	// Validated by Config.Validate
+	matched, _ := path.Match(pattern, rel)
+	return matched
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchesFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		rel     string
+		include []string
+		exclude []string
+		want    bool
+	}{
+		{name: "no filters", rel: "icons/play.svg", want: true},
+		{name: "include by name", rel: "icons/play.svg", include: []string{"play*"}, want: true},
+		{name: "include misses", rel: "icons/stop.svg", include: []string{"play*"}, want: false},
+		{name: "any include matches", rel: "icons/stop.svg", include: []string{"play*", "st*"}, want: true},
+		{name: "exclude by name in a subdirectory", rel: "icons/play_draft.svg", exclude: []string{"*_draft.svg"}, want: false},
+		{name: "exclude wins over an overlapping include", rel: "icons/play_draft.svg", include: []string{"play*"}, exclude: []string{"*_draft.svg"}, want: false},
+		{name: "exclude wins over the same pattern", rel: "play.svg", include: []string{"*.svg"}, exclude: []string{"*.svg"}, want: false},
+		{name: "overlap spares files only include matches", rel: "icons/play.svg", include: []string{"play*"}, exclude: []string{"*_draft.svg"}, want: true},
+		{name: "path pattern", rel: "ui/buttons/ok.svg", include: []string{"ui/*/*.svg"}, want: true},
+		{name: "path pattern matches the whole path", rel: "ui/buttons/ok.svg", include: []string{"buttons/*.svg"}, want: false},
+		{name: "path exclude inside a name include", rel: "ui/old/ok.svg", include: []string{"*.svg"}, exclude: []string{"ui/old/*"}, want: false},
+		{name: "path exclude leaves other directories", rel: "ui/new/ok.svg", include: []string{"*.svg"}, exclude: []string{"ui/old/*"}, want: true},
+		{name: "native separators", rel: filepath.Join("ui", "old", "ok.svg"), exclude: []string{"ui/old/*"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchesFilters(tt.rel, tt.include, tt.exclude); got != tt.want {
+				t.Errorf("MatchesFilters(%q, %q, %q) = %v, want %v", tt.rel, tt.include, tt.exclude, got, tt.want)
+			}
+		})
+	}
+}
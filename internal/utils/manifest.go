@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ManifestEntry is one file listed in a --manifest. A zero width or height
+// keeps the configured tile size on that axis.
+type ManifestEntry struct {
+	File   string `json:"file"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// LoadManifest reads a manifest listing input files in sheet order with
+// optional tile sizes. Files ending in .csv hold "file,width,height" rows,
+// with an optional header row and # comments; anything else is read as a
+// JSON array of {"file", "width", "height"} objects.
+func LoadManifest(path string) ([]ManifestEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer file.Close()
+
+	var entries []ManifestEntry
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		entries, err = parseManifestCSV(file)
+	} else {
+		err = json.NewDecoder(file).Decode(&entries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+
+	for i, entry := range entries {
+		if entry.File == "" {
+			return nil, fmt.Errorf("manifest %s: entry %d has no file", path, i+1)
+		}
+		if entry.Width < 0 || entry.Height < 0 {
+			return nil, fmt.Errorf("manifest %s: %s has a negative tile size", path, entry.File)
+		}
+	}
+
+	return entries, nil
+}
+
+// parseManifestCSV reads "file,width,height" rows; width and height may be
+// empty or left out
+func parseManifestCSV(r io.Reader) ([]ManifestEntry, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) > 0 && strings.EqualFold(strings.TrimSpace(records[0][0]), "file") {
+		records = records[1:]
+	}
+
+	entries := make([]ManifestEntry, 0, len(records))
+	for _, record := range records {
+		if len(record) > 3 {
+			return nil, fmt.Errorf("row %q has more than 3 fields", strings.Join(record, ","))
+		}
+
+		entry := ManifestEntry{File: strings.TrimSpace(record[0])}
+		for i, size := range []*int{&entry.Width, &entry.Height} {
+			if i+1 >= len(record) || strings.TrimSpace(record[i+1]) == "" {
+				continue
+			}
+			if *size, err = strconv.Atoi(strings.TrimSpace(record[i+1])); err != nil {
+				return nil, fmt.Errorf("invalid tile size for %s: %q", entry.File, record[i+1])
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// OrderByManifest puts files in manifest order and returns the tile size each
+// listed file asks for, keyed by path. Entries name a file by its path
+// relative to the one of roots holding it or, when that is unambiguous, by
+// its base name. Files the manifest does not list keep their order after the
+// listed ones.
+func OrderByManifest(files []string, roots []string, entries []ManifestEntry) ([]string, map[string]image.Point, error) {
+	byPath := make(map[string]string, len(files))
+	byName := make(map[string][]string, len(files))
+	for _, file := range files {
+		if rel, err := filepath.Rel(InputRoot(file, roots), file); err == nil {
+			byPath[filepath.ToSlash(rel)] = file
+		}
+		byName[filepath.Base(file)] = append(byName[filepath.Base(file)], file)
+	}
+
+	ordered := make([]string, 0, len(files))
+	sizes := make(map[string]image.Point)
+	listed := make(map[string]bool, len(entries))
+
+	for _, entry := range entries {
+		name := filepath.ToSlash(filepath.Clean(entry.File))
+		file, ok := byPath[name]
+		if !ok {
+			switch matches := byName[name]; len(matches) {
+			case 0:
+				return nil, nil, fmt.Errorf("manifest lists %s, which is not an input file", entry.File)
+			case 1:
+				file = matches[0]
+			default:
+				return nil, nil, fmt.Errorf("manifest entry %s matches %d input files; use its path relative to the input directory", entry.File, len(matches))
+			}
+		}
+
+		if listed[file] {
+			return nil, nil, fmt.Errorf("manifest lists %s more than once", file)
+		}
+		listed[file] = true
+		ordered = append(ordered, file)
+
+		if entry.Width > 0 || entry.Height > 0 {
+			sizes[file] = image.Pt(entry.Width, entry.Height)
+		}
+	}
+
+	for _, file := range files {
+		if !listed[file] {
+			ordered = append(ordered, file)
+		}
+	}
+
+	return ordered, sizes, nil
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+)
+
+// NormalizeName applies --name-case and --name-sanitize to a sprite name.
+// Each "/"-separated part is normalized on its own, so directory names keep
+// their separators. Normalizing a normalized name leaves it unchanged.
+func NormalizeName(name string, mode config.NameCase, sanitize bool) string {
+	if name == "" || (mode == "" || mode == config.NameCaseNone) && !sanitize {
+		return name
+	}
+
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = normalizePart(part, mode, sanitize)
+	}
+	return strings.Join(parts, "/")
+}
+
+// normalizePart normalizes a name without "/". Sanitizing comes first, so
+// that the case is applied to the words left after it.
+func normalizePart(part string, mode config.NameCase, sanitize bool) string {
+	if sanitize {
+		part = sanitizeName(part)
+	}
+
+	// Names without any letter or digit are left as they are
+	words := nameWords(part)
+	if len(words) == 0 {
+		return part
+	}
+
+	switch mode {
+	case config.NameCaseLower:
+		return strings.ToLower(part)
+	case config.NameCaseSnake:
+		return strings.ToLower(strings.Join(words, "_"))
+	case config.NameCaseKebab:
+		return strings.ToLower(strings.Join(words, "-"))
+	case config.NameCaseCamel:
+		for i, word := range words {
+			word = strings.ToLower(word)
+			if i > 0 {
+				r := []rune(word)
+				r[0] = unicode.ToUpper(r[0])
+				word = string(r)
+			}
+			words[i] = word
+		}
+		return strings.Join(words, "")
+	default:
+		return part
+	}
+}
+
+// sanitizeName replaces each run of characters other than ASCII letters,
+// digits, _ and - with one underscore, dropping them at the ends. Names with
+// none of those characters become "_".
+func sanitizeName(name string) string {
+	var b strings.Builder
+	pending := false
+	for _, r := range name {
+		if r < 0x80 && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-') {
+			if pending && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			pending = false
+			b.WriteRune(r)
+		} else {
+			pending = true
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// nameWords splits a name into words at characters other than letters and
+// digits and where the case changes, so "My Icon (v2)", "my_icon_v2" and
+// "MyIconV2" all give My or my, Icon or icon, and v2 or V2. A run of capitals
+// is one word up to the capital starting the next, as in "HTTPServer".
+func nameWords(name string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				words = append(words, string(word))
+				word = nil
+			}
+			continue
+		}
+
+		if len(word) > 0 && unicode.IsUpper(r) {
+			prev := word[len(word)-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+				words = append(words, string(word))
+				word = nil
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     config.NameCase
+		sanitize bool
+		want     string
+	}{
+		{name: "My Icon (v2)", mode: config.NameCaseNone, want: "My Icon (v2)"},
+		{name: "My Icon (v2)", mode: config.NameCaseLower, want: "my icon (v2)"},
+		{name: "My Icon (v2)", mode: config.NameCaseSnake, want: "my_icon_v2"},
+		{name: "My Icon (v2)", mode: config.NameCaseKebab, want: "my-icon-v2"},
+		{name: "My Icon (v2)", mode: config.NameCaseCamel, want: "myIconV2"},
+		{name: "My Icon (v2)", sanitize: true, want: "My_Icon_v2"},
+		{name: "My Icon (v2)", mode: config.NameCaseLower, sanitize: true, want: "my_icon_v2"},
+		{name: "HTTPServer", mode: config.NameCaseSnake, want: "http_server"},
+		{name: "playerIdle2", mode: config.NameCaseKebab, want: "player-idle2"},
+		{name: "  Big--Red  Button!! ", mode: config.NameCaseSnake, want: "big_red_button"},
+		{name: "café au lait", sanitize: true, want: "caf_au_lait"},
+		{name: "ÜBER cool", mode: config.NameCaseCamel, want: "überCool"},
+		{name: "(((", sanitize: true, want: "_"},
+		{name: "---", mode: config.NameCaseSnake, want: "---"},
+		{name: "UI Icons/My Icon (v2)", mode: config.NameCaseSnake, want: "ui_icons/my_icon_v2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode)+"/"+tt.name, func(t *testing.T) {
+			got := NormalizeName(tt.name, tt.mode, tt.sanitize)
+			if got != tt.want {
+				t.Errorf("NormalizeName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+
+			// Normalizing again must not change the name
+			if again := NormalizeName(got, tt.mode, tt.sanitize); again != got {
+				t.Errorf("normalizing %q again gives %q", got, again)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+
+	"github.com/thanhfphan/svg2sheet/internal/logging"
+)
+
+// colorCount is a distinct color and the number of pixels using it
+type colorCount struct {
+	c     color.NRGBA
+	count int
+}
+
+// colorBox is a group of colors that becomes a single palette entry
+type colorBox []colorCount
+
+// QuantizeImage reduces an image to at most maxColors colors (2-256) using
+// median cut over RGBA, preserving alpha in the palette
+func QuantizeImage(img image.Image, maxColors int) *image.Paletted {
+	if maxColors < 2 {
+		maxColors = 2
+	}
+	if maxColors > 256 {
+		maxColors = 256
+	}
+
+	bounds := img.Bounds()
+
+	// Build a histogram of distinct colors
+	histogram := make(map[color.NRGBA]int)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			histogram[c]++
+		}
+	}
+
+	colors := make(colorBox, 0, len(histogram))
+	for c, count := range histogram {
+		colors = append(colors, colorCount{c: c, count: count})
+	}
+
+	boxes := []colorBox{colors}
+	for len(boxes) < maxColors {
+		// Split the box with the widest channel range
+		index, channel, spread := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			ch, sp := box.widestChannel()
+			if sp > spread {
+				index, channel, spread = i, ch, sp
+			}
+		}
+		if index < 0 {
+			break
+		}
+
+		left, right := boxes[index].split(channel)
+		boxes[index] = left
+		boxes = append(boxes, right)
+	}
+
+	pal := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		pal = append(pal, box.average())
+	}
+
+	result := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), pal)
+	draw.Draw(result, result.Bounds(), img, bounds.Min, draw.Src)
+	return result
+}
+
+// paletteImage returns img as a paletted image when it has at most maxColors
+// distinct colors, so it is written as an indexed PNG without changing a
+// pixel. Images with more colors are returned unchanged with a warning.
+func paletteImage(img image.Image, maxColors int, log logging.Logger) image.Image {
+	if _, ok := img.(*image.Paletted); ok {
+		return img
+	}
+
+	if colors := countColors(img, maxColors); colors > maxColors {
+		log.Warn("Image has more than %d colors; writing it without a palette", maxColors)
+		return img
+	}
+
+	// Median cut keeps every color as its own entry when there are no more
+	// than maxColors of them
+	return QuantizeImage(img, maxColors)
+}
+
+// countColors returns the number of distinct colors in an image, stopping
+// once it exceeds limit
+func countColors(img image.Image, limit int) int {
+	bounds := img.Bounds()
+	seen := make(map[color.NRGBA]struct{}, limit+1)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			seen[color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)] = struct{}{}
+			if len(seen) > limit {
+				return len(seen)
+			}
+		}
+	}
+	return len(seen)
+}
+
+// channel returns one of the R, G, B, A components of a color
+func channel(c color.NRGBA, ch int) uint8 {
+	switch ch {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	case 2:
+		return c.B
+	default:
+		return c.A
+	}
+}
+
+// widestChannel returns the channel with the largest value range in the box
+func (b colorBox) widestChannel() (int, int) {
+	best, spread := 0, -1
+	for ch := 0; ch < 4; ch++ {
+		lo, hi := uint8(255), uint8(0)
+		for _, cc := range b {
+			v := channel(cc.c, ch)
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		if int(hi)-int(lo) > spread {
+			best, spread = ch, int(hi)-int(lo)
+		}
+	}
+	return best, spread
+}
+
+// split divides the box at the pixel-weighted median of a channel
+func (b colorBox) split(ch int) (colorBox, colorBox) {
+	sort.Slice(b, func(i, j int) bool {
+		return channel(b[i].c, ch) < channel(b[j].c, ch)
+	})
+
+	total := 0
+	for _, cc := range b {
+		total += cc.count
+	}
+
+	acc, at := 0, 1
+	for i, cc := range b[:len(b)-1] {
+		acc += cc.count
+		at = i + 1
+		if acc*2 >= total {
+			break
+		}
+	}
+
+	return b[:at], b[at:]
+}
+
+// average returns the pixel-weighted average color of the box
+func (b colorBox) average() color.Color {
+	var r, g, bl, a, total int
+	for _, cc := range b {
+		r += int(cc.c.R) * cc.count
+		g += int(cc.c.G) * cc.count
+		bl += int(cc.c.B) * cc.count
+		a += int(cc.c.A) * cc.count
+		total += cc.count
+	}
+	if total == 0 {
+		return color.NRGBA{}
+	}
+	return color.NRGBA{
+		R: uint8(r / total),
+		G: uint8(g / total),
+		B: uint8(bl / total),
+		A: uint8(a / total),
+	}
+}
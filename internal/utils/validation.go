@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,21 +13,36 @@ import (
 // ValidateConfig performs comprehensive validation of the configuration
 func ValidateConfig(cfg *config.Config) error {
 	if err := cfg.Validate(); err != nil {
-		return err
+		return fmt.Errorf("configuration error: %w", err)
 	}
 
-	// Additional validation for file paths and permissions
-	if err := ValidateInputPath(cfg.Input); err != nil {
+	// Additional validation for file paths and permissions. With an input
+	// list the input directory is only the base of its relative paths.
+	if cfg.InputList != "" {
+		if err := ValidateInputList(cfg.InputList, cfg.Input); err != nil {
+			return fmt.Errorf("input validation failed: %w", err)
+		}
+	} else if len(cfg.ExtraInputs) > 0 {
+		if err := ValidateInputDirs(cfg.InputDirs()); err != nil {
+			return fmt.Errorf("input validation failed: %w", err)
+		}
+	} else if err := ValidateInputPath(cfg.Input); err != nil {
 		return fmt.Errorf("input validation failed: %w", err)
 	}
 
-	if err := ValidateOutputPath(cfg.Output, cfg.Force); err != nil {
+	// --skip-unchanged replaces outputs whose input changed, as --force would
+	if err := ValidateOutputPath(cfg.Output, cfg.Force || cfg.SkipUnchanged, cfg.DryRun); err != nil {
 		return fmt.Errorf("output validation failed: %w", err)
 	}
 
 	// Validate metadata output path if specified
 	if cfg.Meta != "" {
-		if err := ValidateMetadataPath(cfg.Meta, cfg.Force); err != nil {
+		if err := ValidateMetadataPath(cfg.Meta, cfg.MetaFormat, cfg.Force, cfg.DryRun); err != nil {
+			return fmt.Errorf("metadata path validation failed: %w", err)
+		}
+	}
+	if cfg.MetaCSV != "" {
+		if err := ValidateMetadataPath(cfg.MetaCSV, string(config.MetaNative), cfg.Force, cfg.DryRun); err != nil {
 			return fmt.Errorf("metadata path validation failed: %w", err)
 		}
 	}
@@ -40,21 +56,63 @@ func ValidateConfig(cfg *config.Config) error {
 	return nil
 }
 
-// ValidateMetadataPath validates the metadata output path
-func ValidateMetadataPath(path string, force bool) error {
+// ValidateInputList checks that the input list exists and that base, the
+// directory its relative paths start from, is a directory
+func ValidateInputList(list, base string) error {
+	if !FileExists(list) {
+		return fmt.Errorf("input list does not exist: %s", list)
+	}
+
+	isDir, err := IsDirectory(base)
+	if err != nil {
+		return fmt.Errorf("failed to access input path %s: %w", base, err)
+	}
+	if !isDir {
+		return fmt.Errorf("input-list needs --input to be a directory, the base of its relative paths: %s", base)
+	}
+
+	return nil
+}
+
+// ValidateInputDirs checks every one of several inputs, which must all be
+// directories
+func ValidateInputDirs(dirs []string) error {
+	for _, dir := range dirs {
+		if err := ValidateInputPath(dir); err != nil {
+			return err
+		}
+		if isDir, _ := IsDirectory(dir); !isDir {
+			return fmt.Errorf("several inputs must all be directories: %s", dir)
+		}
+	}
+	return nil
+}
+
+// ValidateMetadataPath validates the metadata output path. A dry run only
+// checks the extension and that no existing file would be replaced.
+func ValidateMetadataPath(path, format string, force, dryRun bool) error {
 	if path == "" {
 		return fmt.Errorf("metadata path cannot be empty")
 	}
 
+	// Godot resources are written into a directory
+	if config.MetaFormat(format) == config.MetaGodot {
+		return nil
+	}
+
 	ext := strings.ToLower(filepath.Ext(path))
-	if ext != ".json" && ext != ".csv" {
-		return fmt.Errorf("metadata file must have .json or .csv extension, got: %s", ext)
+	if ext != ".json" && ext != ".csv" && ext != ".css" && ext != ".atlas" && ext != ".xml" {
+		return fmt.Errorf("metadata file must have .json, .csv, .css, .atlas, or .xml extension, got: %s", ext)
 	}
 
 	if FileExists(path) && !force {
 		return fmt.Errorf("metadata file already exists: %s (use --force to overwrite)", path)
 	}
 
+	if dryRun {
+		return nil
+	}
+
 	parentDir := filepath.Dir(path)
 	if err := EnsureDir(parentDir); err != nil {
 		return fmt.Errorf("cannot create metadata output directory: %w", err)
@@ -65,7 +123,14 @@ func ValidateMetadataPath(path string, force bool) error {
 
 // ValidateSpritesheetConfig validates spritesheet-specific configuration
 func ValidateSpritesheetConfig(cfg *config.Config) error {
-	if cfg.TileWidth <= 0 || cfg.TileHeight <= 0 {
+	if cfg.Pack {
+		// Packed sheets have no grid; sprites keep their own size
+		return nil
+	}
+
+	// Axes measured by --auto-tile are checked again once they are known
+	autoWidth, autoHeight := cfg.AutoTileAxes()
+	if cfg.TileWidth <= 0 && !autoWidth || cfg.TileHeight <= 0 && !autoHeight {
 		return fmt.Errorf("tile dimensions must be positive: %dx%d", cfg.TileWidth, cfg.TileHeight)
 	}
 
@@ -75,14 +140,10 @@ func ValidateSpritesheetConfig(cfg *config.Config) error {
 		return fmt.Errorf("tile dimensions too large (max %d): %dx%d", maxTileSize, cfg.TileWidth, cfg.TileHeight)
 	}
 
-	if cfg.Cols <= 0 && cfg.Rows <= 0 {
+	if cfg.Cols <= 0 && cfg.Rows <= 0 && cfg.RowSpec == "" {
 		return fmt.Errorf("either cols or rows must be specified for spritesheet")
 	}
 
-	if cfg.Cols > 0 && cfg.Rows > 0 {
-		return fmt.Errorf("cannot specify both cols and rows")
-	}
-
 	// Check reasonable limits
 	maxGridSize := 100
 	if cfg.Cols > maxGridSize || cfg.Rows > maxGridSize {
@@ -98,6 +159,15 @@ func ValidateSpritesheetConfig(cfg *config.Config) error {
 		return fmt.Errorf("padding too large (max %d): %d", maxPadding, cfg.Padding)
 	}
 
+	if cfg.Margin < 0 {
+		return fmt.Errorf("margin cannot be negative: %d", cfg.Margin)
+	}
+
+	maxMargin := 100
+	if cfg.Margin > maxMargin {
+		return fmt.Errorf("margin too large (max %d): %d", maxMargin, cfg.Margin)
+	}
+
 	return nil
 }
 
@@ -138,30 +208,51 @@ func ValidateScale(scale float64) error {
 	return nil
 }
 
-// ValidateFileCount validates the number of files for processing
-func ValidateFileCount(count int, mode string) error {
-	if count <= 0 {
+// ValidateFileCount checks the number of input files against --max-files
+// and, for a spritesheet, the number of sprites they make against
+// --max-sprites
+func ValidateFileCount(cfg *config.Config, fileCount, spriteCount int, spritesheet bool) error {
+	if fileCount <= 0 {
 		return fmt.Errorf("no files to process")
 	}
 
-	maxFiles := 1000
-	if count > maxFiles {
-		return fmt.Errorf("too many files to process (max %d): %d", maxFiles, count)
+	maxFiles := cfg.MaxFiles
+	if maxFiles == 0 {
+		maxFiles = config.DefaultMaxFiles
+	}
+	if fileCount > maxFiles {
+		return fmt.Errorf("too many files to process: %d (max %d, raise it with --max-files)", fileCount, maxFiles)
 	}
 
-	// For spritesheet mode, check reasonable limits
-	if mode == "spritesheet" {
-		maxSprites := 256
-		if count > maxSprites {
-			return fmt.Errorf("too many sprites for spritesheet (max %d): %d", maxSprites, count)
-		}
+	maxSprites := cfg.MaxSprites
+	if maxSprites == 0 {
+		maxSprites = config.DefaultMaxSprites
+	}
+	if spritesheet && spriteCount > maxSprites {
+		return fmt.Errorf("too many sprites for a spritesheet: %d (max %d, raise it with --max-sprites)", spriteCount, maxSprites)
 	}
 
 	return nil
 }
 
-// ValidateMemoryUsage estimates and validates memory usage
+// ValidateMemoryUsage checks the estimated memory usage against --max-memory
 func ValidateMemoryUsage(cfg *config.Config, fileCount int) error {
+	estimatedMemory := EstimateMemoryUsage(cfg, fileCount)
+
+	maxMB := cfg.MaxMemory
+	if maxMB == 0 {
+		maxMB = config.DefaultMaxMemory
+	}
+	if estimatedMemory > int64(maxMB)*1024*1024 {
+		return fmt.Errorf("estimated memory usage too high: %d MB (max %d MB, see --max-memory)", estimatedMemory/(1024*1024), maxMB)
+	}
+
+	return nil
+}
+
+// EstimateMemoryUsage returns the approximate number of bytes needed to hold
+// the decoded tiles and the spritesheet for fileCount files
+func EstimateMemoryUsage(cfg *config.Config, fileCount int) int64 {
 	// Estimate memory usage based on configuration
 	tileSize := cfg.TileWidth * cfg.TileHeight * 4 // 4 bytes per pixel (RGBA)
 
@@ -174,6 +265,10 @@ func ValidateMemoryUsage(cfg *config.Config, fileCount int) error {
 		// Calculate spritesheet dimensions
 		cols := cfg.Cols
 		rows := cfg.Rows
+		if cols == 0 && rows == 0 {
+			// Row specs and packed sheets: assume a roughly square grid
+			cols = max(1, int(math.Ceil(math.Sqrt(float64(fileCount)))))
+		}
 		if cols == 0 {
 			cols = (fileCount + rows - 1) / rows
 		}
@@ -181,8 +276,8 @@ func ValidateMemoryUsage(cfg *config.Config, fileCount int) error {
 			rows = (fileCount + cols - 1) / cols
 		}
 
-		spritesheetWidth := cols*cfg.TileWidth + (cols-1)*cfg.Padding
-		spritesheetHeight := rows*cfg.TileHeight + (rows-1)*cfg.Padding
+		spritesheetWidth := cols*cfg.TileWidth + (cols-1)*cfg.Padding + 2*cfg.Margin
+		spritesheetHeight := rows*cfg.TileHeight + (rows-1)*cfg.Padding + 2*cfg.Margin
 		spritesheetMemory := int64(spritesheetWidth * spritesheetHeight * 4)
 
 		estimatedMemory = tilesMemory + spritesheetMemory
@@ -191,20 +286,14 @@ func ValidateMemoryUsage(cfg *config.Config, fileCount int) error {
 		estimatedMemory = int64(tileSize)
 	}
 
-	// Check against reasonable memory limit (500MB)
-	maxMemory := int64(500 * 1024 * 1024)
-	if estimatedMemory > maxMemory {
-		return fmt.Errorf("estimated memory usage too high: %d MB (max 500 MB)", estimatedMemory/(1024*1024))
-	}
-
-	return nil
+	return estimatedMemory
 }
 
 // ValidateOutputFormat validates the output file format
 func ValidateOutputFormat(outputPath string) error {
 	ext := strings.ToLower(filepath.Ext(outputPath))
 
-	validExtensions := []string{".png", ".jpg", ".jpeg"}
+	validExtensions := []string{".png", ".jpg", ".jpeg", ".webp"}
 	for _, validExt := range validExtensions {
 		if ext == validExt {
 			return nil
@@ -216,7 +305,7 @@ func ValidateOutputFormat(outputPath string) error {
 
 // ValidateSortMode validates the sort mode
 func ValidateSortMode(mode string) error {
-	validModes := []string{"name", "ctime", "manual"}
+	validModes := []string{"name", "natural", "ctime", "manual", "filesize", "size", "size-desc"}
 
 	for _, validMode := range validModes {
 		if mode == validMode {
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+)
+
+func TestEstimateMemoryUsage(t *testing.T) {
+	const tile = 64 * 64 * 4
+
+	tests := []struct {
+		name  string
+		cfg   config.Config
+		files int
+		want  int64
+	}{
+		{
+			name:  "single conversion",
+			cfg:   config.Config{TileWidth: 64, TileHeight: 64},
+			files: 10,
+			want:  tile,
+		},
+		{
+			name:  "cols",
+			cfg:   config.Config{TileWidth: 64, TileHeight: 64, Cols: 4},
+			files: 10,
+			// 4x3 grid of 256x192
+			want: 10*tile + 256*192*4,
+		},
+		{
+			name:  "rows",
+			cfg:   config.Config{TileWidth: 64, TileHeight: 64, Rows: 2},
+			files: 10,
+			// 5x2 grid of 320x128
+			want: 10*tile + 320*128*4,
+		},
+		{
+			name:  "fixed grid",
+			cfg:   config.Config{TileWidth: 64, TileHeight: 64, Cols: 4, Rows: 4},
+			files: 3,
+			want:  3*tile + 256*256*4,
+		},
+		{
+			name:  "padding and margin",
+			cfg:   config.Config{TileWidth: 64, TileHeight: 64, Cols: 4, Padding: 2, Margin: 5},
+			files: 8,
+			// 4x2 grid with 3 and 1 gaps and a margin on both sides
+			want: 8*tile + (256+6+10)*(128+2+10)*4,
+		},
+		{
+			name:  "packed",
+			cfg:   config.Config{TileWidth: 64, TileHeight: 64, Pack: true},
+			files: 10,
+			// assumed 4x3 square-ish grid
+			want: 10*tile + 256*192*4,
+		},
+		{
+			name:  "packed without files",
+			cfg:   config.Config{TileWidth: 64, TileHeight: 64, Pack: true},
+			files: 0,
+			want:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateMemoryUsage(&tt.cfg, tt.files); got != tt.want {
+				t.Errorf("EstimateMemoryUsage = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMemoryUsage(t *testing.T) {
+	// 1000 1024x1024 tiles take 4000 MB before the sheet is counted
+	cfg := config.Config{TileWidth: 1024, TileHeight: 1024, Cols: 10}
+
+	if err := ValidateMemoryUsage(&cfg, 1000); err == nil {
+		t.Error("ValidateMemoryUsage accepted 1000 1024x1024 tiles under the default limit")
+	}
+
+	cfg.MaxMemory = 10000
+	if err := ValidateMemoryUsage(&cfg, 1000); err != nil {
+		t.Errorf("ValidateMemoryUsage with --max-memory 10000: %v", err)
+	}
+
+	// Exactly at the limit is allowed, one byte over is not
+	small := config.Config{TileWidth: 512, TileHeight: 512, Cols: 1, MaxMemory: 2}
+	if err := ValidateMemoryUsage(&small, 1); err != nil {
+		t.Errorf("ValidateMemoryUsage at the limit: %v", err)
+	}
+	small.TileHeight = 513
+	if err := ValidateMemoryUsage(&small, 1); err == nil {
+		t.Error("ValidateMemoryUsage accepted a sheet over the limit")
+	}
+}
+
+func TestValidateFileCount(t *testing.T) {
+	cfg := config.Config{MaxFiles: 3, MaxSprites: 5}
+
+	tests := []struct {
+		name        string
+		files       int
+		sprites     int
+		spritesheet bool
+		wantErr     bool
+	}{
+		{name: "no files", files: 0, sprites: 0, wantErr: true},
+		{name: "under the file limit", files: 2, sprites: 2},
+		{name: "at the file limit", files: 3, sprites: 3},
+		{name: "one file over", files: 4, sprites: 4, wantErr: true},
+		{name: "at the sprite limit", files: 2, sprites: 5, spritesheet: true},
+		{name: "one sprite over", files: 2, sprites: 6, spritesheet: true, wantErr: true},
+		{name: "sprite limit only applies to sheets", files: 2, sprites: 6},
+		{name: "file limit applies to sheets too", files: 4, sprites: 4, spritesheet: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFileCount(&cfg, tt.files, tt.sprites, tt.spritesheet)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateFileCount(%d, %d) = %v, want error %v", tt.files, tt.sprites, err, tt.wantErr)
+			}
+		})
+	}
+
+	// Unset limits fall back to the defaults
+	var defaults config.Config
+	if err := ValidateFileCount(&defaults, config.DefaultMaxFiles, config.DefaultMaxSprites, true); err != nil {
+		t.Errorf("at the default limits: %v", err)
+	}
+	if err := ValidateFileCount(&defaults, config.DefaultMaxFiles+1, 1, false); err == nil {
+		t.Error("accepted one file over the default limit")
+	}
+	if err := ValidateFileCount(&defaults, 1, config.DefaultMaxSprites+1, true); err == nil {
+		t.Error("accepted one sprite over the default limit")
+	}
+}
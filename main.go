@@ -7,7 +7,16 @@ import (
 	"github.com/thanhfphan/svg2sheet/cmd"
 )
 
+// Set with -ldflags by the Makefile
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
 func main() {
+	cmd.SetVersion(Version, Commit, BuildTime)
+
 	if err := cmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -0,0 +1,141 @@
+package svg2sheet
+
+import (
+	"context"
+	"fmt"
+	"image"
+
+	"github.com/thanhfphan/svg2sheet/internal/utils"
+)
+
+// planFile describes the conversion of a single SVG without writing it
+func (r *runner) planFile(ctx context.Context) (*Result, error) {
+	planned := FileResult{
+		Input:   r.config.Input,
+		Output:  r.config.Output,
+		Action:  "render",
+		Backend: string(r.converter.Type()),
+	}
+
+	// The size of an SVG on stdin is only known once it has been read
+	if !r.config.IsStdinInput() {
+		width, height, err := r.converter.GetImageDimensions(ctx, r.config.Input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure %s: %w", r.config.Input, err)
+		}
+		planned.Width, planned.Height = width, height
+	}
+
+	return &Result{Files: []FileResult{planned}}, nil
+}
+
+// planConversions describes the per-file conversions of a directory without
+// creating the output directory or any file in it
+func (r *runner) planConversions(files []string) *Result {
+	result := &Result{Files: make([]FileResult, len(files))}
+	for i, file := range files {
+		result.Files[i] = FileResult{
+			Input:  file,
+			Output: r.outputPath(file),
+			Action: convertAction(file),
+		}
+	}
+	return result
+}
+
+// planSpritesheet computes the spritesheet layout for the sorted input files
+// and returns the metadata the sheet would get, without rendering or writing
+// anything
+func (r *runner) planSpritesheet(ctx context.Context, files []string) (*Metadata, error) {
+	if autoWidth, autoHeight := r.config.AutoTileAxes(); autoWidth || autoHeight {
+		if err := r.planAutoTile(ctx, files); err != nil {
+			return nil, err
+		}
+	}
+
+	sizes, err := r.planSizes(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each animated SVG becomes one sprite per frame, all of its size
+	var sprites []utils.FileMapping
+	var spriteSizes []image.Point
+	for i, file := range files {
+		if r.animated(file) {
+			for _, frame := range r.frameMappings(file) {
+				sprites = append(sprites, frame)
+				spriteSizes = append(spriteSizes, sizes[i])
+			}
+			continue
+		}
+		sprites = append(sprites, utils.FileMapping{OriginalPath: file, Name: r.spriteName(file)})
+		spriteSizes = append(spriteSizes, sizes[i])
+	}
+
+	meta, err := r.generator.PlanMetadata(sprites, spriteSizes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate layout: %w", err)
+	}
+	r.completeMetadata(meta)
+	return meta, nil
+}
+
+// planAutoTile resolves --auto-tile from the untrimmed size of every file,
+// which is measured without rendering
+func (r *runner) planAutoTile(ctx context.Context, files []string) error {
+	var largest image.Point
+	for _, file := range files {
+		width, height, err := r.measureFile(ctx, file)
+		if err != nil {
+			return fmt.Errorf("failed to measure %s: %w", file, err)
+		}
+
+		tileSize := r.tileSizes[file]
+		if tileSize.X == 0 && width > largest.X {
+			largest.X = width
+		}
+		if tileSize.Y == 0 && height > largest.Y {
+			largest.Y = height
+		}
+	}
+	return r.resolveAutoTile(largest, r.spriteCount(files))
+}
+
+// planSizes returns the size each file would have in the spritesheet. Grid
+// sprites get the tile size, or their own from --manifest; packed sprites
+// without one keep their rendered size, which is measured without rendering.
+func (r *runner) planSizes(ctx context.Context, files []string) ([]image.Point, error) {
+	sizes := make([]image.Point, len(files))
+
+	for i, file := range files {
+		tileSize, sized := r.tileSizes[file]
+		if !r.config.Pack || sized {
+			sizes[i] = image.Pt(r.config.TileWidth, r.config.TileHeight)
+			if tileSize.X > 0 {
+				sizes[i].X = tileSize.X
+			}
+			if tileSize.Y > 0 {
+				sizes[i].Y = tileSize.Y
+			}
+			continue
+		}
+
+		width, height, err := r.measureFile(ctx, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure %s: %w", file, err)
+		}
+		sizes[i] = image.Pt(width, height)
+	}
+
+	return sizes, nil
+}
+
+// measureFile returns the pixel size of an SVG as the converter would render
+// it, or of a raster image as stored
+func (r *runner) measureFile(ctx context.Context, file string) (int, int, error) {
+	if !utils.IsRasterInput(file) {
+		return r.converter.GetImageDimensions(ctx, file)
+	}
+	return imageSize(file)
+}
@@ -0,0 +1,1219 @@
+package svg2sheet
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/thanhfphan/svg2sheet/internal/cache"
+	"github.com/thanhfphan/svg2sheet/internal/config"
+	"github.com/thanhfphan/svg2sheet/internal/logging"
+	"github.com/thanhfphan/svg2sheet/internal/metadata"
+	"github.com/thanhfphan/svg2sheet/internal/spritesheet"
+	"github.com/thanhfphan/svg2sheet/internal/svg"
+	"github.com/thanhfphan/svg2sheet/internal/utils"
+	_ "golang.org/x/image/webp"
+)
+
+// runner carries out a single Convert or GenerateSheet call
+type runner struct {
+	config    *config.Config
+	opts      Options
+	log       logging.Logger
+	converter *svg.Converter
+	generator *spritesheet.Generator
+	exporter  *metadata.Exporter
+	failures  []Failure
+	tileSizes map[string]image.Point // per-file tile sizes from --manifest
+	tags      []metadata.Animation   // sprite ranges from --aseprite frame tags
+	renders   *cache.Cache           // rendered PNGs from earlier runs, nil when caching is off
+	renamed   map[string]string      // output paths or sprite names given by --on-collision rename
+	density   float64                // density of the sheet among those of --scales, 0 without them
+}
+
+// newRunner applies defaults to a copy of the options' config, validates it
+// and creates the converter. sheet tells whether a spritesheet is generated.
+func newRunner(opts Options, sheet bool) (*runner, error) {
+	cfg := opts.Config
+	cfg.SetDefaults()
+	if err := utils.ValidateConfig(&cfg); err != nil {
+		return nil, err
+	}
+
+	log := opts.Logger
+	if log == nil {
+		log = cfg.NewLogger()
+	}
+	log.Debug("Configuration: %+v", cfg)
+
+	// Sprites must stay transparent for trimming and placement; the generator
+	// fills the sheet background instead
+	converterCfg := &cfg
+	if sheet && cfg.Background != "" {
+		sheetCfg := cfg
+		sheetCfg.Background = ""
+		converterCfg = &sheetCfg
+	}
+
+	converter, err := svg.NewConverter(converterCfg, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SVG converter: %w", err)
+	}
+
+	renders, err := openCache(converterCfg, converter, log)
+	if err != nil {
+		converter.Close()
+		return nil, err
+	}
+
+	return &runner{
+		config:    &cfg,
+		opts:      opts,
+		log:       log,
+		converter: converter,
+		generator: spritesheet.NewGenerator(&cfg, log),
+		exporter:  metadata.NewExporter(&cfg, log),
+		renders:   renders,
+	}, nil
+}
+
+// openCache returns the render cache for the converter's settings, or nil
+// with --no-cache, with --dry-run or when the default cache directory cannot
+// be used. --skip-unchanged keeps its records in the cache, so it fails
+// instead.
+func openCache(cfg *config.Config, converter *svg.Converter, log logging.Logger) (*cache.Cache, error) {
+	if cfg.NoCache || cfg.DryRun {
+		return nil, nil
+	}
+
+	dir := cfg.CacheDir
+	if dir == "" {
+		var err error
+		if dir, err = cache.DefaultDir(); err != nil {
+			if cfg.SkipUnchanged {
+				return nil, fmt.Errorf("skip-unchanged needs a cache directory: %w", err)
+			}
+			log.Debug("Render cache disabled: %v", err)
+			return nil, nil
+		}
+	}
+
+	renders, err := cache.New(dir, cfg, converter.Type())
+	if err != nil {
+		// Only a directory the user asked for is worth failing over
+		if cfg.CacheDir != "" || cfg.SkipUnchanged {
+			return nil, err
+		}
+		log.Debug("Render cache disabled: %v", err)
+		return nil, nil
+	}
+
+	log.Debug("Render cache: %s", renders.Dir())
+	return renders, nil
+}
+
+// close releases the converter
+func (r *runner) close() {
+	r.converter.Close()
+}
+
+// inputIsDir reports whether the input is a directory
+func (r *runner) inputIsDir() (bool, error) {
+	if r.config.IsStdinInput() {
+		return false, nil
+	}
+
+	inputInfo, err := os.Stat(r.config.Input)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat input: %w", err)
+	}
+	return inputInfo.IsDir(), nil
+}
+
+// step reports progress before converting file number done+1 of total
+func (r *runner) step(done, total int, path string) {
+	if r.opts.Progress != nil {
+		r.opts.Progress(done, total, path)
+	}
+}
+
+// convertFile handles single file processing
+func (r *runner) convertFile(ctx context.Context) (*Result, error) {
+	r.log.Debug("Processing single file: %s", r.config.Input)
+
+	if !r.config.IsSVGInput() {
+		return nil, fmt.Errorf("single file input must be an SVG file")
+	}
+
+	if r.config.DryRun {
+		return r.planFile(ctx)
+	}
+
+	if r.config.IsStdinInput() || r.config.IsStdoutOutput() {
+		return r.convertStream(ctx)
+	}
+
+	converted := FileResult{
+		Input:  r.config.Input,
+		Output: r.config.Output,
+		Action: "render",
+	}
+
+	key := r.cacheKey(r.config.Input)
+	if r.unchanged(r.config.Input, r.config.Output, key) {
+		converted.Unchanged = true
+	} else {
+		if err := r.converter.ConvertFile(ctx, r.config.Input, r.config.Output); err != nil {
+			return nil, err
+		}
+		converted.Backend = string(r.converter.Type())
+		r.recordOutput(r.config.Output, key)
+	}
+
+	var err error
+	converted.Width, converted.Height, err = imageSize(r.config.Output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", r.config.Output, err)
+	}
+
+	return &Result{Files: []FileResult{converted}}, nil
+}
+
+// convertStream converts a single SVG when either end is "-": the SVG is read
+// from stdin or its file, rendered in memory, and the PNG is written to
+// stdout or the output file
+func (r *runner) convertStream(ctx context.Context) (*Result, error) {
+	var svgData []byte
+	var err error
+	if r.config.IsStdinInput() {
+		svgData, err = io.ReadAll(r.opts.stdin())
+	} else {
+		svgData, err = os.ReadFile(r.config.Input)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SVG input: %w", err)
+	}
+
+	img, err := r.converter.ConvertToImage(ctx, svgData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert SVG to image: %w", err)
+	}
+
+	opts := svg.NewConversionOptions(r.config, r.log).EncodeOptions()
+	if config.StdoutEncoding(r.config.StdoutEncoding) == config.StdoutBase64 {
+		var buf bytes.Buffer
+		if err = utils.WriteImage(&buf, img, utils.FormatPNG, opts); err == nil {
+			err = writeEnvelope(r.opts.stdout(), buf.Bytes(), nil)
+		}
+	} else if r.config.IsStdoutOutput() {
+		err = utils.WriteImage(r.opts.stdout(), img, utils.FormatPNG, opts)
+	} else {
+		err = utils.SaveImage(img, r.config.Output, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{Files: []FileResult{{
+		Input:   r.config.Input,
+		Output:  r.config.Output,
+		Action:  "render",
+		Width:   img.Bounds().Dx(),
+		Height:  img.Bounds().Dy(),
+		Backend: string(r.converter.Type()),
+	}}}, nil
+}
+
+// inputFiles returns the SVG and raster images of the input directory, or
+// those named by --input-list, in the configured order
+func (r *runner) inputFiles() ([]string, error) {
+	var files []string
+	var err error
+	if r.config.InputList != "" {
+		r.log.Debug("Processing input list: %s", r.config.InputList)
+		files, err = r.listedInputFiles()
+	} else {
+		r.log.Debug("Processing directory: %s", strings.Join(r.config.InputDirs(), ", "))
+		files, err = r.scanInputDirs()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get input files: %w", err)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no valid input files found in directory")
+	}
+
+	r.log.Debug("Found %d files to process", len(files))
+
+	sortedFiles, err := utils.SortFiles(files, config.SortMode(r.config.Sort))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sort files: %w", err)
+	}
+
+	if r.config.Manifest != "" {
+		if sortedFiles, err = r.applyManifest(sortedFiles); err != nil {
+			return nil, err
+		}
+	}
+
+	return sortedFiles, nil
+}
+
+// scanInputDirs returns a list of valid input files from the input
+// directories, in the order they were given. A file reached through two of
+// them, such as a directory given along with its parent, is listed once.
+func (r *runner) scanInputDirs() ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+
+	for _, dir := range r.config.InputDirs() {
+		paths, err := r.dirFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range paths {
+			// An output inside the input directory, such as the sheet of a
+			// previous run, is not an input
+			if !utils.IsInputFile(path) || utils.IsOutputPath(path, r.config.Output) || seen[filepath.Clean(path)] {
+				continue
+			}
+			if rel, err := filepath.Rel(dir, path); err == nil && !utils.MatchesFilters(rel, r.config.Include, r.config.Exclude) {
+				continue
+			}
+			seen[filepath.Clean(path)] = true
+			files = append(files, path)
+		}
+	}
+
+	return files, nil
+}
+
+// dirFiles returns the paths of the files in dir and, unless --no-recursive
+// is set, in every directory below it
+func (r *runner) dirFiles(dir string) ([]string, error) {
+	var paths []string
+
+	if r.config.NoRecursive {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				paths = append(paths, filepath.Join(dir, entry.Name()))
+			}
+		}
+		return paths, nil
+	}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// listedInputFiles returns the files named by --input-list in its order,
+// leaving out outputs of a previous run that a glob matched
+func (r *runner) listedInputFiles() ([]string, error) {
+	listed, err := utils.LoadInputList(r.config.InputList, r.config.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	files := listed[:0]
+	for _, file := range listed {
+		if !utils.IsOutputPath(file, r.config.Output) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// applyManifest orders files as listed in --manifest and records the tile
+// sizes it declares
+func (r *runner) applyManifest(files []string) ([]string, error) {
+	entries, err := utils.LoadManifest(r.config.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered, sizes, err := utils.OrderByManifest(files, r.config.InputDirs(), entries)
+	if err != nil {
+		return nil, err
+	}
+	// Manifest sizes are given at 1x, like the configured tile size
+	if r.density > 0 {
+		for file, size := range sizes {
+			sizes[file] = image.Pt(config.ScaleSize(size.X, r.density), config.ScaleSize(size.Y, r.density))
+		}
+	}
+	r.tileSizes = sizes
+
+	r.log.Debug("Manifest %s lists %d of %d files, %d with their own tile size",
+		r.config.Manifest, len(entries), len(files), len(sizes))
+
+	return ordered, nil
+}
+
+// skipFile records a failed file and returns nil when --skip-errors is set,
+// and returns err otherwise
+func (r *runner) skipFile(ctx context.Context, file string, err error) error {
+	if ctx.Err() != nil || !r.config.SkipErrors {
+		return err
+	}
+
+	r.log.Debug("Skipping %s: %v", file, err)
+	r.failures = append(r.failures, Failure{Path: file, Err: err})
+	return nil
+}
+
+// failureError returns a *PartialFailureError listing the files left out by
+// --skip-errors, or nil when there were none
+func (r *runner) failureError(total int) error {
+	if len(r.failures) == 0 {
+		return nil
+	}
+	return &PartialFailureError{Failures: r.failures, Total: total}
+}
+
+// convertFiles converts multiple files individually
+func (r *runner) convertFiles(ctx context.Context, files []string) (*Result, error) {
+	files, err := r.resolveCollisions(files, "output", r.outputPath, func(output string, n int) string {
+		ext := filepath.Ext(output)
+		return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(output, ext), n, ext)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if r.config.DryRun {
+		return r.planConversions(files), nil
+	}
+
+	if err := os.MkdirAll(r.config.Output, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	result := &Result{}
+	for i, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		r.step(i, len(files), file)
+		r.log.Debug("Converting file %d/%d: %s", i+1, len(files), file)
+
+		converted := FileResult{
+			Input:  file,
+			Output: r.outputPath(file),
+			Action: convertAction(file),
+		}
+
+		if r.config.PreserveTree {
+			if err := utils.EnsureDir(filepath.Dir(converted.Output)); err != nil {
+				return nil, err
+			}
+		}
+
+		var err error
+		switch converted.Action {
+		case "copy":
+			err = utils.CopyFile(file, converted.Output)
+		case "convert":
+			err = r.reencodeFile(file, converted.Output)
+		default:
+			err = r.renderFile(ctx, file, &converted)
+		}
+		if err == nil {
+			converted.Width, converted.Height, err = imageSize(converted.Output)
+		}
+		if err != nil {
+			if err := r.skipFile(ctx, file, err); err != nil {
+				verb := "convert"
+				if converted.Action == "copy" {
+					verb = "copy"
+				}
+				return nil, fmt.Errorf("failed to %s %s: %w", verb, file, err)
+			}
+			continue
+		}
+
+		result.Files = append(result.Files, converted)
+	}
+
+	return result, nil
+}
+
+// outputPath returns where the PNG converted from file is written: directly
+// in the output directory, or with --preserve-tree in the same subdirectory
+// the file has under the input directory
+func (r *runner) outputPath(file string) string {
+	if output, ok := r.renamed[file]; ok {
+		return output
+	}
+
+	name := utils.GetFileNameWithoutExt(file) + ".png"
+	if r.config.PreserveTree {
+		if rel, err := filepath.Rel(utils.InputRoot(file, r.config.InputDirs()), filepath.Dir(file)); err == nil {
+			return filepath.Join(r.config.Output, rel, name)
+		}
+	}
+	return filepath.Join(r.config.Output, name)
+}
+
+// spriteName returns the name of the sprite made from file
+func (r *runner) spriteName(file string) string {
+	if name, ok := r.renamed[file]; ok {
+		return name
+	}
+	return r.fileSpriteName(file)
+}
+
+// fileSpriteName returns the sprite name given by file's name, normalized by
+// --name-case and --name-sanitize, before any --on-collision rename. The
+// _1 of renames and _000 of frames are added to it as they are.
+func (r *runner) fileSpriteName(file string) string {
+	name := utils.GetFileNameWithoutExt(file)
+	return utils.NormalizeName(name, config.NameCase(r.config.NameCase), r.config.NameSanitize)
+}
+
+// resolveCollisions applies --on-collision to files whose key, their output
+// path or sprite name, is already taken by an earlier file, and returns the
+// files to process. rename builds the nth alternative for a key; renamed
+// files are recorded in r.renamed. what names the key in messages.
+func (r *runner) resolveCollisions(files []string, what string, key func(file string) string, rename func(key string, n int) string) ([]string, error) {
+	// Every file's own key is reserved up front, so a renamed file never
+	// takes the key of a file further down the list
+	taken := make(map[string]string, len(files))
+	for _, file := range files {
+		taken[key(file)] = ""
+	}
+
+	var kept []string
+	for _, file := range files {
+		k := key(file)
+		first := taken[k]
+		if first == "" {
+			taken[k] = file
+			kept = append(kept, file)
+			continue
+		}
+
+		switch config.CollisionPolicy(r.config.OnCollision) {
+		case config.CollisionError:
+			return nil, fmt.Errorf("%s and %s have the same %s %s (see --on-collision)", first, file, what, k)
+		case config.CollisionSkip:
+			r.log.Warn("skipping %s, which has the same %s %s as %s", file, what, k, first)
+		case config.CollisionRename:
+			n := 1
+			for _, used := taken[rename(k, n)]; used; _, used = taken[rename(k, n)] {
+				n++
+			}
+			alternative := rename(k, n)
+			taken[alternative] = file
+			if r.renamed == nil {
+				r.renamed = make(map[string]string)
+			}
+			r.renamed[file] = alternative
+			kept = append(kept, file)
+			r.log.Debug("Using %s %s for %s, as %s has %s", what, alternative, file, first, k)
+		default:
+			r.log.Warn("%s and %s have the same %s %s (see --on-collision)", first, file, what, k)
+			kept = append(kept, file)
+		}
+	}
+
+	return kept, nil
+}
+
+// renderFile renders an SVG to converted.Output, copying the cached PNG
+// instead when the SVG and the rendering settings are unchanged
+func (r *runner) renderFile(ctx context.Context, file string, converted *FileResult) error {
+	key := r.cacheKey(file)
+	if r.unchanged(file, converted.Output, key) {
+		converted.Unchanged = true
+		return nil
+	}
+
+	if cached, ok := r.cachedPNG(key, file); ok {
+		converted.Backend = string(r.converter.Type())
+		converted.Cached = true
+		if err := utils.CopyFile(cached, converted.Output); err != nil {
+			return err
+		}
+		r.recordOutput(converted.Output, key)
+		return nil
+	}
+
+	if err := r.converter.ConvertFile(ctx, file, converted.Output); err != nil {
+		return err
+	}
+
+	converted.Backend = string(r.converter.Type())
+	r.log.Debug("Rendered %s with %s", file, converted.Backend)
+	r.storePNG(key, file, converted.Output)
+	r.recordOutput(converted.Output, key)
+	return nil
+}
+
+// unchanged reports whether --skip-unchanged leaves output as it is: it was
+// last written from the same SVG content and settings, which key covers
+func (r *runner) unchanged(file, output, key string) bool {
+	if !r.config.SkipUnchanged || key == "" || !r.renders.Unchanged(output, key) {
+		return false
+	}
+
+	r.log.Debug("Skipping unchanged %s", file)
+	return true
+}
+
+// recordOutput notes for --skip-unchanged that output was written from the
+// SVG with key. Failing only costs a render next time, so it only warns.
+func (r *runner) recordOutput(output, key string) {
+	if !r.config.SkipUnchanged || key == "" {
+		return
+	}
+
+	if err := r.renders.Record(output, key); err != nil {
+		r.log.Warn("%v", err)
+	}
+}
+
+// cacheKey returns the render cache key of an SVG, or "" when caching is
+// off or the file cannot be read, in which case rendering reports the error
+func (r *runner) cacheKey(file string) string {
+	if r.renders == nil {
+		return ""
+	}
+
+	key, err := r.renders.Key(file)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+// cachedPNG returns the cached PNG for key and whether there was one,
+// logging the hit or miss for file
+func (r *runner) cachedPNG(key, file string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+
+	cached, ok := r.renders.Lookup(key)
+	if ok {
+		r.log.Debug("Cache hit: %s", file)
+	} else {
+		r.log.Debug("Cache miss: %s", file)
+	}
+	return cached, ok
+}
+
+// storePNG caches the PNG rendered from file under key. The render itself
+// succeeded, so a failure only warns.
+func (r *runner) storePNG(key, file, png string) {
+	if key == "" {
+		return
+	}
+
+	if err := r.renders.Put(key, png); err != nil {
+		r.log.Warn("failed to cache %s: %v", file, err)
+	}
+}
+
+// convertAction returns how an input file becomes a PNG: SVGs are rendered,
+// PNGs copied and other raster images re-encoded
+func convertAction(file string) string {
+	switch {
+	case filepath.Ext(file) == ".png":
+		return "copy"
+	case utils.IsRasterInput(file):
+		return "convert"
+	default:
+		return "render"
+	}
+}
+
+// reencodeFile writes a JPEG or GIF input as the PNG outputFile
+func (r *runner) reencodeFile(file, outputFile string) error {
+	img, err := utils.DecodeImageFile(file)
+	if err != nil {
+		return err
+	}
+	return utils.SaveImage(img, outputFile, utils.NewEncodeOptions(r.config, r.log))
+}
+
+// generateSpritesheet creates a spritesheet from the input files
+func (r *runner) generateSpritesheet(ctx context.Context, files []string) (*Metadata, error) {
+	r.log.Debug("Generating spritesheet with %d files", len(files))
+
+	if r.config.IsGIFOutput() {
+		return r.generateAnimation(ctx, files)
+	}
+
+	// Names are compared after --name-case and --name-sanitize, which can
+	// make different file names equal
+	files, err := r.resolveCollisions(files, "sprite name", r.fileSpriteName, func(name string, n int) string {
+		return fmt.Sprintf("%s_%d", name, n)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadAnimations(r.spriteCount(files)); err != nil {
+		return nil, err
+	}
+
+	if r.config.DryRun {
+		return r.planSpritesheet(ctx, files)
+	}
+
+	// Refuse sheets too large to hold in memory before rendering anything
+	spriteCount := r.spriteCount(files)
+	if err := utils.ValidateMemoryUsage(r.config, spriteCount); err != nil {
+		return nil, err
+	}
+	r.log.Debug("Estimated peak memory: ~%d MB (max %d MB)", utils.EstimateMemoryUsage(r.config, spriteCount)/(1024*1024), r.config.MaxMemory)
+
+	var before runtime.MemStats
+	if r.config.Verbose {
+		runtime.ReadMemStats(&before)
+	}
+
+	if r.config.DebugGrid {
+		r.log.Warn("--debug-grid draws outlines and indexes over the sprites of %s; do not ship it", r.config.Output)
+	}
+
+	// Convert SVG files to PNG if needed (in-memory or temporary files)
+	fileMappings, cleanup, err := r.preparePNGFiles(ctx, files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare PNG files: %w", err)
+	}
+	defer cleanup()
+
+	if autoWidth, autoHeight := r.config.AutoTileAxes(); autoWidth || autoHeight {
+		largest, err := utils.MaxSpriteSize(fileMappings, r.config)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.resolveAutoTile(largest, spriteCount); err != nil {
+			return nil, err
+		}
+	}
+
+	// File size and area ordering need the converted PNGs, so they are
+	// applied here
+	switch mode := config.SortMode(r.config.Sort); mode {
+	case config.SortByFileSize:
+		fileMappings, err = utils.SortMappingsByFileSize(fileMappings)
+	case config.SortBySize, config.SortBySizeDesc:
+		fileMappings, err = utils.SortMappingsBySize(fileMappings, mode == config.SortBySizeDesc, r.config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to sort files by size: %w", err)
+	}
+
+	// Generate the spritesheet; streamed sheets add their animations before
+	// writing the metadata along
+	var metadata *Metadata
+	if r.config.IsStdoutOutput() {
+		metadata, err = r.streamSpritesheet(ctx, fileMappings)
+	} else if metadata, err = r.generator.Generate(ctx, fileMappings, r.config.Output); err == nil {
+		r.completeMetadata(metadata)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate spritesheet: %w", err)
+	}
+
+	// Export metadata if requested
+	if r.config.Meta != "" {
+		if err := r.exporter.Export(metadata, r.config.Meta); err != nil {
+			return nil, fmt.Errorf("failed to export metadata: %w", err)
+		}
+	}
+	if r.config.MetaCSV != "" {
+		if err := r.exporter.ExportCSV(metadata, r.config.MetaCSV); err != nil {
+			return nil, fmt.Errorf("failed to export CSV metadata: %w", err)
+		}
+	}
+
+	if r.config.Verbose {
+		var after runtime.MemStats
+		runtime.ReadMemStats(&after)
+		r.log.Debug("Memory: %d MB allocated during generation, %d MB obtained from the system",
+			(after.TotalAlloc-before.TotalAlloc)/(1024*1024), after.Sys/(1024*1024))
+	}
+	r.log.Debug("Spritesheet generated successfully: %s", r.config.Output)
+	if r.config.Meta != "" {
+		r.log.Debug("Metadata exported: %s", r.config.Meta)
+	}
+	if r.config.MetaCSV != "" {
+		r.log.Debug("Metadata exported: %s", r.config.MetaCSV)
+	}
+
+	return metadata, nil
+}
+
+// streamSpritesheet generates the spritesheet onto standard output in the
+// configured stdout encoding. Base64 output carries the metadata along,
+// unless Meta writes it to disk.
+func (r *runner) streamSpritesheet(ctx context.Context, fileMappings []utils.FileMapping) (*Metadata, error) {
+	if config.StdoutEncoding(r.config.StdoutEncoding) != config.StdoutBase64 {
+		meta, err := r.generator.GenerateTo(ctx, fileMappings, r.opts.stdout())
+		if err != nil {
+			return nil, err
+		}
+		r.completeMetadata(meta)
+		return meta, nil
+	}
+
+	var buf bytes.Buffer
+	meta, err := r.generator.GenerateTo(ctx, fileMappings, &buf)
+	if err != nil {
+		return nil, err
+	}
+	r.completeMetadata(meta)
+
+	envelope := meta
+	if r.config.Meta != "" {
+		envelope = nil
+	}
+	if err := writeEnvelope(r.opts.stdout(), buf.Bytes(), envelope); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// stdoutEnvelope is the JSON object written to standard output with a stdout
+// encoding of base64
+type stdoutEnvelope struct {
+	Image    string    `json:"image"`              // base64 PNG
+	Metadata *Metadata `json:"metadata,omitempty"` // sheet metadata, unless written to Meta
+}
+
+// writeEnvelope writes a PNG, and the sheet metadata when not nil, to w as a
+// single line of JSON
+func writeEnvelope(w io.Writer, png []byte, meta *Metadata) error {
+	envelope := stdoutEnvelope{
+		Image:    base64.StdEncoding.EncodeToString(png),
+		Metadata: meta,
+	}
+	if err := json.NewEncoder(w).Encode(envelope); err != nil {
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	return nil
+}
+
+// generateAnimation captures the frames of a single animated SVG into an
+// animated GIF at the output path. The metadata lists the frames, each
+// covering the whole animation at its origin.
+func (r *runner) generateAnimation(ctx context.Context, files []string) (*Metadata, error) {
+	if len(files) != 1 || !r.animated(files[0]) {
+		return nil, fmt.Errorf("gif output needs a single animated SVG as input, not %d files", len(files))
+	}
+	file := files[0]
+
+	width, height, err := r.converter.GetImageDimensions(ctx, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure %s: %w", file, err)
+	}
+
+	frames := r.frameMappings(file)
+	meta := &Metadata{
+		Width:      width,
+		Height:     height,
+		TileWidth:  width,
+		TileHeight: height,
+		Cols:       len(frames),
+		Rows:       1,
+		Density:    r.density,
+	}
+	for i, frame := range frames {
+		name, err := r.generator.SpriteName(frame, i)
+		if err != nil {
+			return nil, err
+		}
+		meta.Sprites = append(meta.Sprites, metadata.SpriteInfo{
+			Name:   name,
+			Width:  width,
+			Height: height,
+			Index:  i,
+			Source: r.generator.SourcePath(file),
+		})
+	}
+
+	if r.config.DryRun {
+		return meta, nil
+	}
+
+	paths := make([]string, len(frames))
+	for i := range paths {
+		tempFile, err := utils.CreateTempFile(".png")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp file: %w", err)
+		}
+		defer os.Remove(tempFile)
+		paths[i] = tempFile
+	}
+
+	if err := r.converter.ConvertFrames(ctx, file, r.frameInterval(), paths); err != nil {
+		return nil, fmt.Errorf("failed to capture frames of %s: %w", file, err)
+	}
+
+	// The converter renders frames without the background for sheets, so it
+	// is filled in here
+	background, _ := r.config.BackgroundColor()
+	images := make([]image.Image, len(paths))
+	for i, path := range paths {
+		img, err := utils.DecodeImageFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load frame %d: %w", i, err)
+		}
+		if background != nil {
+			img = utils.FlattenImage(img, background)
+		}
+		images[i] = img
+	}
+
+	if err := utils.SaveGIF(images, r.frameInterval(), r.config.Output); err != nil {
+		return nil, err
+	}
+
+	r.log.Debug("Animation generated successfully: %s", r.config.Output)
+
+	return meta, nil
+}
+
+// preparePNGFiles converts SVG files to PNG and returns a list of PNG files with mappings
+func (r *runner) preparePNGFiles(ctx context.Context, files []string) ([]utils.FileMapping, func(), error) {
+	var fileMappings []utils.FileMapping
+	var tempFiles []string
+	var svgIndexes []int
+	keys := make(map[string]string) // cache keys of the SVGs to render
+
+	used := make(map[string]bool) // names taken in --keep-intermediate
+
+	// Kept intermediates are left in place; only temporary files go
+	cleanup := func() {
+		for _, tempFile := range tempFiles {
+			os.Remove(tempFile)
+		}
+	}
+
+	if r.config.KeepIntermediate != "" {
+		if err := os.MkdirAll(r.config.KeepIntermediate, 0755); err != nil {
+			return nil, nil, fmt.Errorf("failed to create intermediate directory: %w", err)
+		}
+	}
+
+	for _, file := range files {
+		if utils.IsRasterInput(file) {
+			// Raster inputs are loaded by the generator as they are
+			fileMappings = append(fileMappings, utils.FileMapping{
+				PNGPath:      file,
+				OriginalPath: file,
+				IsTemporary:  false,
+				TileSize:     r.tileSizes[file],
+				Name:         r.spriteName(file),
+			})
+		} else if r.animated(file) {
+			// Frames are captured straight away; the cache holds still renders only
+			frames := r.frameMappings(file)
+			paths := make([]string, len(frames))
+			for i := range frames {
+				path, temporary, err := r.intermediatePath(frames[i].SpriteName(), used)
+				if err != nil {
+					cleanup()
+					return nil, nil, err
+				}
+				if temporary {
+					tempFiles = append(tempFiles, path)
+				}
+				frames[i].PNGPath = path
+				frames[i].IsTemporary = temporary
+				paths[i] = path
+			}
+
+			if err := r.converter.ConvertFrames(ctx, file, r.frameInterval(), paths); err != nil {
+				err = fmt.Errorf("failed to capture frames of %s: %w", file, err)
+				if err := r.skipFile(ctx, file, err); err != nil {
+					cleanup()
+					return nil, nil, err
+				}
+				continue
+			}
+			fileMappings = append(fileMappings, frames...)
+		} else {
+			key := r.cacheKey(file)
+			if cached, ok := r.cachedPNG(key, file); ok {
+				if err := r.keepCachedPNG(cached, file, used); err != nil {
+					cleanup()
+					return nil, nil, err
+				}
+				// The generator only reads the PNG, so the cached file is used in place
+				fileMappings = append(fileMappings, utils.FileMapping{
+					PNGPath:      cached,
+					OriginalPath: file,
+					IsTemporary:  false,
+					TileSize:     r.tileSizes[file],
+					Name:         r.spriteName(file),
+				})
+				continue
+			}
+			keys[file] = key
+
+			path, temporary, err := r.intermediatePath(r.spriteName(file), used)
+			if err != nil {
+				cleanup()
+				return nil, nil, err
+			}
+
+			if temporary {
+				tempFiles = append(tempFiles, path)
+			}
+			svgIndexes = append(svgIndexes, len(fileMappings))
+
+			fileMappings = append(fileMappings, utils.FileMapping{
+				PNGPath:      path,
+				OriginalPath: file,
+				IsTemporary:  temporary,
+				TileSize:     r.tileSizes[file],
+				Name:         r.spriteName(file),
+			})
+		}
+	}
+
+	// Convert all SVGs together so batch-capable backends can share setup work
+	pending := make([]utils.FileMapping, len(svgIndexes))
+	for i, index := range svgIndexes {
+		pending[i] = fileMappings[index]
+	}
+
+	done := 0
+	err := r.converter.ConvertFiles(ctx, pending, func(path string) {
+		r.step(done, len(pending), path)
+		done++
+	})
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	for i, index := range svgIndexes {
+		fileMappings[index] = pending[i]
+		if pending[i].Err != nil {
+			r.skipFile(ctx, pending[i].OriginalPath, pending[i].Err)
+			if !pending[i].IsTemporary {
+				// A failed render leaves nothing worth keeping
+				os.Remove(pending[i].PNGPath)
+			}
+		} else {
+			r.log.Debug("Rendered %s with %s", pending[i].OriginalPath, r.converter.Type())
+			r.storePNG(keys[pending[i].OriginalPath], pending[i].OriginalPath, pending[i].PNGPath)
+		}
+	}
+
+	// Files left out by --skip-errors get no sprite
+	rendered := fileMappings[:0]
+	for _, mapping := range fileMappings {
+		if mapping.Err == nil {
+			rendered = append(rendered, mapping)
+		}
+	}
+	if len(rendered) == 0 {
+		cleanup()
+		return nil, nil, fmt.Errorf("no input file could be rendered")
+	}
+
+	return rendered, cleanup, nil
+}
+
+// intermediatePath returns where the PNG rendered for the sprite name is
+// written: with --keep-intermediate a file in that directory named after
+// the sprite, otherwise a temporary file. used holds the names already taken
+// in the directory, so sprites sharing a name get _2, _3 and so on. The bool
+// reports whether the file is temporary.
+func (r *runner) intermediatePath(name string, used map[string]bool) (string, bool, error) {
+	if r.config.KeepIntermediate == "" {
+		tempFile, err := utils.CreateTempFile(".png")
+		if err != nil {
+			return "", false, fmt.Errorf("failed to create temp file: %w", err)
+		}
+		return tempFile, true, nil
+	}
+
+	// Compared without case, for case-insensitive file systems
+	base := name
+	for n := 2; used[strings.ToLower(name)]; n++ {
+		name = fmt.Sprintf("%s_%d", base, n)
+	}
+	used[strings.ToLower(name)] = true
+
+	return filepath.Join(r.config.KeepIntermediate, name+".png"), false, nil
+}
+
+// keepCachedPNG copies the cached render of file into --keep-intermediate,
+// where it would have been rendered without the cache
+func (r *runner) keepCachedPNG(cached, file string, used map[string]bool) error {
+	if r.config.KeepIntermediate == "" {
+		return nil
+	}
+
+	path, _, err := r.intermediatePath(r.spriteName(file), used)
+	if err != nil {
+		return err
+	}
+	if err := utils.CopyFile(cached, path); err != nil {
+		return fmt.Errorf("failed to keep the render of %s: %w", file, err)
+	}
+	return nil
+}
+
+// loadAnimations reads the frame tags of --aseprite for a sheet of count
+// sprites, whose indexes the tags must stay within
+func (r *runner) loadAnimations(count int) error {
+	if r.config.Aseprite == "" {
+		return nil
+	}
+
+	animations, err := metadata.LoadAsepriteTags(r.config.Aseprite)
+	if err != nil {
+		return err
+	}
+	for _, animation := range animations {
+		if animation.To >= count {
+			return fmt.Errorf("aseprite frame tag %s ends at frame %d, but the sheet has %d sprites", animation.Name, animation.To, count)
+		}
+	}
+
+	r.tags = animations
+	r.log.Debug("Loaded %d animations from %s", len(animations), r.config.Aseprite)
+	return nil
+}
+
+// resolveAutoTile sets the tile axes --auto-tile measures to the size of the
+// largest sprite, or to the default 64 when every sprite has its own size
+// from --manifest there, and checks the completed settings again
+func (r *runner) resolveAutoTile(largest image.Point, spriteCount int) error {
+	autoWidth, autoHeight := r.config.AutoTileAxes()
+	if autoWidth {
+		r.config.TileWidth = largest.X
+		if r.config.TileWidth == 0 {
+			r.config.TileWidth = 64
+		}
+	}
+	if autoHeight {
+		r.config.TileHeight = largest.Y
+		if r.config.TileHeight == 0 {
+			r.config.TileHeight = 64
+		}
+	}
+	r.log.Debug("Auto tile size: %dx%d", r.config.TileWidth, r.config.TileHeight)
+
+	if err := r.config.Validate(); err != nil {
+		return fmt.Errorf("auto-tile chose %dx%d tiles: %w", r.config.TileWidth, r.config.TileHeight, err)
+	}
+	if err := utils.ValidateSpritesheetConfig(r.config); err != nil {
+		return fmt.Errorf("auto-tile chose %dx%d tiles: %w", r.config.TileWidth, r.config.TileHeight, err)
+	}
+	return utils.ValidateMemoryUsage(r.config, spriteCount)
+}
+
+// completeMetadata records what the layout leaves out of the sheet's
+// metadata: its density among --scales and its animations
+func (r *runner) completeMetadata(meta *Metadata) {
+	meta.Density = r.density
+	r.addAnimations(meta)
+}
+
+// addAnimations records the animations of the sheet in meta: the frame tags
+// of --aseprite, or with --group-by-prefix those its sprite names form
+func (r *runner) addAnimations(meta *Metadata) {
+	if !r.config.GroupByPrefix {
+		meta.Animations = r.tags
+		return
+	}
+
+	animations, scattered := metadata.GroupByPrefix(meta.Sprites)
+	for _, name := range scattered {
+		r.log.Warn("the frames of %s are not next to each other in frame order, so it is not recorded as an animation (see --sort natural)", name)
+	}
+	meta.Animations = animations
+	r.log.Debug("Grouped %d animations by name prefix", len(animations))
+}
+
+// animated reports whether file is an SVG captured as animation frames
+func (r *runner) animated(file string) bool {
+	return r.config.Frames > 0 && !utils.IsRasterInput(file)
+}
+
+// frameMappings returns one mapping for each animation frame of the SVG
+// file, named after it with the frame number: icon_000, icon_001, ...
+func (r *runner) frameMappings(file string) []utils.FileMapping {
+	base := r.spriteName(file)
+	frames := make([]utils.FileMapping, r.config.Frames)
+	for i := range frames {
+		frames[i] = utils.FileMapping{
+			OriginalPath: file,
+			IsTemporary:  true,
+			TileSize:     r.tileSizes[file],
+			Name:         fmt.Sprintf("%s_%03d", base, i),
+		}
+	}
+	return frames
+}
+
+// spriteCount returns the number of sprites files make: one per frame of
+// animated SVGs and one for every other file
+func (r *runner) spriteCount(files []string) int {
+	count := 0
+	for _, file := range files {
+		if r.animated(file) {
+			count += r.config.Frames
+		} else {
+			count++
+		}
+	}
+	return count
+}
+
+// frameInterval returns the animation time between captured frames
+func (r *runner) frameInterval() time.Duration {
+	// Validated by Config.Validate
+	interval, _ := r.config.FrameIntervalDuration()
+	return interval
+}
+
+// imageSize returns the pixel size of an image file without decoding its pixels
+func imageSize(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	imgConfig, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return imgConfig.Width, imgConfig.Height, nil
+}
@@ -0,0 +1,291 @@
+package svg2sheet
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-rod/rod/lib/launcher"
+)
+
+// scannedFiles returns the input files a runner for cfg finds, relative to
+// the input directory and sorted by path
+func scannedFiles(t *testing.T, cfg Config) []string {
+	t.Helper()
+
+	r, err := newRunner(testOptions(cfg), true)
+	if err != nil {
+		t.Fatalf("newRunner: %v", err)
+	}
+	defer r.close()
+
+	files, err := r.inputFiles()
+	if err != nil {
+		t.Fatalf("inputFiles: %v", err)
+	}
+
+	rels := make([]string, len(files))
+	for i, file := range files {
+		rel, err := filepath.Rel(cfg.Input, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rels[i] = filepath.ToSlash(rel)
+	}
+	sort.Strings(rels)
+	return rels
+}
+
+// filterTree writes a tree of icons with drafts and an old directory
+func filterTree(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, name := range []string{
+		"play.svg", "play_draft.svg", "stop.svg",
+		"ui/ok.svg", "ui/ok_draft.svg", "ui/old/cancel.svg",
+	} {
+		writeSVG(t, filepath.Join(dir, filepath.FromSlash(name)), "red", 8)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestScanFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+	}{
+		{
+			name: "no filters",
+			want: []string{"play.svg", "play_draft.svg", "stop.svg", "ui/ok.svg", "ui/ok_draft.svg", "ui/old/cancel.svg"},
+		},
+		{
+			name:    "exclude drafts",
+			exclude: []string{"*_draft.svg"},
+			want:    []string{"play.svg", "stop.svg", "ui/ok.svg", "ui/old/cancel.svg"},
+		},
+		{
+			name:    "overlapping include and exclude",
+			include: []string{"play*", "ok*"},
+			exclude: []string{"*_draft.svg"},
+			want:    []string{"play.svg", "ui/ok.svg"},
+		},
+		{
+			name:    "path include with a name exclude",
+			include: []string{"ui/*"},
+			exclude: []string{"ok_*"},
+			want:    []string{"ui/ok.svg"},
+		},
+		{
+			name:    "several excludes",
+			exclude: []string{"*_draft.svg", "ui/old/*", "stop.svg"},
+			want:    []string{"play.svg", "ui/ok.svg"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scannedFiles(t, Config{
+				Input:   filterTree(t),
+				Output:  filepath.Join(t.TempDir(), "sheet.png"),
+				Pack:    true,
+				Include: tt.include,
+				Exclude: tt.exclude,
+			})
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("scanned %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanNoRecursive(t *testing.T) {
+	dir := filterTree(t)
+	extra := t.TempDir()
+	writeSVG(t, filepath.Join(extra, "more.svg"), "blue", 8)
+	writeSVG(t, filepath.Join(extra, "nested", "deep.svg"), "blue", 8)
+
+	tests := []struct {
+		name        string
+		noRecursive bool
+		exclude     []string
+		want        []string
+	}{
+		{
+			name: "recursive by default",
+			want: []string{"play.svg", "play_draft.svg", "stop.svg", "ui/ok.svg", "ui/ok_draft.svg", "ui/old/cancel.svg"},
+		},
+		{
+			name:        "top level only",
+			noRecursive: true,
+			want:        []string{"play.svg", "play_draft.svg", "stop.svg"},
+		},
+		{
+			name:        "top level with filters",
+			noRecursive: true,
+			exclude:     []string{"*_draft.svg"},
+			want:        []string{"play.svg", "stop.svg"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scannedFiles(t, Config{
+				Input:       dir,
+				Output:      filepath.Join(t.TempDir(), "sheet.png"),
+				Pack:        true,
+				NoRecursive: tt.noRecursive,
+				Exclude:     tt.exclude,
+			})
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("scanned %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Extra input directories are scanned the same way
+	r, err := newRunner(testOptions(Config{
+		Input:       dir,
+		ExtraInputs: []string{extra},
+		Output:      filepath.Join(t.TempDir(), "sheet.png"),
+		Pack:        true,
+		NoRecursive: true,
+	}), true)
+	if err != nil {
+		t.Fatalf("newRunner: %v", err)
+	}
+	defer r.close()
+
+	files, err := r.inputFiles()
+	if err != nil {
+		t.Fatalf("inputFiles: %v", err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, filepath.Base(file))
+	}
+	sort.Strings(names)
+	if want := []string{"more.svg", "play.svg", "play_draft.svg", "stop.svg"}; fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Errorf("scanned %q with an extra input, want %q", names, want)
+	}
+}
+
+func TestFileCountLimits(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		writeSVG(t, filepath.Join(dir, name+".svg"), "red", 8)
+	}
+
+	tests := []struct {
+		name       string
+		maxFiles   int
+		maxSprites int
+		wantErr    bool
+	}{
+		{name: "at the file limit", maxFiles: 3},
+		{name: "one file over", maxFiles: 2, wantErr: true},
+		{name: "at the sprite limit", maxSprites: 3},
+		{name: "one sprite over", maxSprites: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GenerateSheet(context.Background(), testOptions(Config{
+				Input:      dir,
+				Output:     filepath.Join(t.TempDir(), "sheet.png"),
+				Pack:       true,
+				MaxFiles:   tt.maxFiles,
+				MaxSprites: tt.maxSprites,
+			}))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GenerateSheet = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpriteCountFromFrames(t *testing.T) {
+	files := []string{"spin.svg", "walk.svg", "still.png"}
+
+	// Each SVG makes one sprite per frame, the raster image one sprite
+	tests := []struct {
+		frames int
+		want   int
+	}{
+		{frames: 0, want: 3},
+		{frames: 1, want: 3},
+		{frames: 4, want: 9},
+	}
+	for _, tt := range tests {
+		r := &runner{config: &Config{Frames: tt.frames}}
+		if got := r.spriteCount(files); got != tt.want {
+			t.Errorf("with %d frames spriteCount = %d, want %d", tt.frames, got, tt.want)
+		}
+	}
+
+	if _, found := launcher.LookPath(); !found {
+		t.Skip("Chrome/Chromium not found")
+	}
+
+	// The limit counts frames and is checked before any is captured
+	dir := t.TempDir()
+	writeSVG(t, filepath.Join(dir, "spin.svg"), "red", 8)
+	writeSVG(t, filepath.Join(dir, "walk.svg"), "red", 8)
+
+	_, err := GenerateSheet(context.Background(), testOptions(Config{
+		Input:      dir,
+		Output:     filepath.Join(t.TempDir(), "sheet.png"),
+		Converter:  "rod",
+		Frames:     4,
+		MaxSprites: 7,
+	}))
+	if err == nil || !strings.Contains(err.Error(), "max-sprites") {
+		t.Errorf("GenerateSheet = %v, want the sprite limit error", err)
+	}
+}
+
+func TestAnimationSourcePaths(t *testing.T) {
+	if _, found := launcher.LookPath(); !found {
+		t.Skip("Chrome/Chromium not found")
+	}
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "spinner.svg")
+	writeSVG(t, input, "red", 8)
+
+	tests := []struct {
+		noSourcePaths bool
+		want          string
+	}{
+		{want: "spinner.svg"},
+		{noSourcePaths: true, want: ""},
+	}
+
+	for _, tt := range tests {
+		meta, err := GenerateSheet(context.Background(), testOptions(Config{
+			Input:         input,
+			Output:        filepath.Join(t.TempDir(), "spinner.gif"),
+			Converter:     "rod",
+			Frames:        3,
+			NoSourcePaths: tt.noSourcePaths,
+			DryRun:        true,
+		}))
+		if err != nil {
+			t.Fatalf("GenerateSheet: %v", err)
+		}
+		for _, sprite := range meta.Sprites {
+			if sprite.Source != tt.want {
+				t.Errorf("with no-source-paths %v %s has source %q, want %q", tt.noSourcePaths, sprite.Name, sprite.Source, tt.want)
+			}
+		}
+	}
+}
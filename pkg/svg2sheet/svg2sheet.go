@@ -0,0 +1,238 @@
+// Package svg2sheet converts SVG files to raster images and packs folders of
+// SVG and raster images into spritesheets. It is the library behind the
+// svg2sheet command and can be embedded in other Go build tools.
+//
+// Every call works on its own copy of the options and keeps no global
+// state, so calls may run concurrently. Progress messages and warnings go
+// to Options.Logger; without one only warnings are printed unless Verbose is
+// set.
+package svg2sheet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/thanhfphan/svg2sheet/internal/config"
+	"github.com/thanhfphan/svg2sheet/internal/logging"
+	"github.com/thanhfphan/svg2sheet/internal/metadata"
+	"github.com/thanhfphan/svg2sheet/internal/utils"
+)
+
+// Config holds the conversion and layout settings. Its fields mirror the
+// command-line flags; zero values get the same defaults.
+type Config = config.Config
+
+// Metadata describes a generated spritesheet and the placement of every
+// sprite, as written with --meta in the native format
+type Metadata = metadata.SpritesheetMetadata
+
+// SpriteInfo describes one sprite of a Metadata
+type SpriteInfo = metadata.SpriteInfo
+
+// Logger receives the messages of a call, formatted like fmt.Printf
+type Logger = logging.Logger
+
+// Options configures a Convert or GenerateSheet call
+type Options struct {
+	Config
+
+	// Stdin and Stdout replace os.Stdin and os.Stdout for an Input or
+	// Output of "-"
+	Stdin  io.Reader
+	Stdout io.Writer
+
+	// Progress, when not nil, is called before each file is converted with
+	// the number of files already done and the total
+	Progress func(done, total int, path string)
+
+	// Logger, when not nil, receives progress messages and warnings.
+	// Otherwise they are printed as text, or as JSON with LogJSON, at the
+	// level chosen by Verbose and Quiet.
+	Logger Logger
+}
+
+// Result describes the images a Convert call wrote, or would write with
+// DryRun
+type Result struct {
+	Files []FileResult
+}
+
+// FileResult describes one converted image
+type FileResult struct {
+	Input     string // source file, "-" for stdin
+	Output    string // written image, "-" for stdout
+	Action    string // "render" for SVGs, "copy" for PNGs, "convert" for other raster images
+	Width     int    // pixel size, zero when a dry run did not measure it
+	Height    int
+	Backend   string // converter backend that rendered an SVG
+	Cached    bool   // the SVG's PNG was reused from the render cache
+	Unchanged bool   // the output was left as it was by SkipUnchanged
+}
+
+// Failure is an input file left out with SkipErrors
+type Failure struct {
+	Path string
+	Err  error
+}
+
+// PartialFailureError is returned along with a result when SkipErrors left
+// some files out
+type PartialFailureError struct {
+	Failures []Failure
+	Total    int // number of input files
+}
+
+func (e *PartialFailureError) Error() string {
+	return fmt.Sprintf("%d of %d files failed", len(e.Failures), e.Total)
+}
+
+// Convert renders a single SVG, or every SVG and raster image of an input
+// directory, to images in the output format. With DryRun nothing is written
+// and the result describes the planned conversions. With SkipErrors the
+// files that fail are left out and listed in a *PartialFailureError returned
+// together with the result.
+func Convert(ctx context.Context, opts Options) (*Result, error) {
+	r, err := newRunner(opts, false)
+	if err != nil {
+		return nil, err
+	}
+	defer r.close()
+
+	if r.config.Frames > 0 {
+		return nil, fmt.Errorf("animation frames are laid out as a spritesheet, use GenerateSheet")
+	}
+	if r.config.Scales != "" {
+		return nil, fmt.Errorf("scales applies to spritesheets only")
+	}
+
+	isDir, err := r.inputIsDir()
+	if err != nil {
+		return nil, err
+	}
+	if !isDir {
+		return r.convertFile(ctx)
+	}
+	if r.config.IsStdoutOutput() {
+		return nil, fmt.Errorf("standard output requires a single SVG input, not a directory")
+	}
+
+	files, err := r.inputFiles()
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.ValidateFileCount(r.config, len(files), len(files), false); err != nil {
+		return nil, err
+	}
+
+	result, err := r.convertFiles(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+	return result, r.failureError(len(files))
+}
+
+// GenerateSheet packs the SVG and raster images of the input directory into
+// a spritesheet at the output path, writes its metadata when Meta is set,
+// and returns the metadata. With DryRun nothing is written and the metadata
+// describes the planned layout. With SkipErrors the files that fail are left
+// out and listed in a *PartialFailureError returned together with the
+// metadata.
+//
+// With Scales one sheet is generated for each density, re-rendering the
+// sources at it, and the metadata of the first is returned.
+func GenerateSheet(ctx context.Context, opts Options) (*Metadata, error) {
+	cfg := opts.Config
+	cfg.SetDefaults()
+	if err := utils.ValidateConfig(&cfg); err != nil {
+		return nil, err
+	}
+	scales, _ := cfg.SheetScales() // Validated by Config.Validate
+	if len(scales) == 0 {
+		return generateSheet(ctx, opts, 0)
+	}
+
+	var first *Metadata
+	var partial *PartialFailureError
+	for _, density := range scales {
+		scaled := opts
+		scaled.Config = cfg.AtScale(density)
+
+		meta, err := generateSheet(ctx, scaled, density)
+		if err != nil && !errors.As(err, &partial) {
+			return nil, fmt.Errorf("%gx sheet: %w", density, err)
+		}
+		if first == nil {
+			first = meta
+		}
+	}
+	if partial != nil {
+		return first, partial
+	}
+	return first, nil
+}
+
+// generateSheet generates the sheet of a single density of --scales, or 0
+// without them
+func generateSheet(ctx context.Context, opts Options, density float64) (*Metadata, error) {
+	r, err := newRunner(opts, true)
+	if err != nil {
+		return nil, err
+	}
+	defer r.close()
+	r.density = density
+
+	isDir, err := r.inputIsDir()
+	if err != nil {
+		return nil, err
+	}
+	if !isDir && !r.animated(r.config.Input) {
+		return nil, fmt.Errorf("spritesheet input must be a directory, or an SVG with frames: %s", r.config.Input)
+	}
+
+	// The frames of a single animated SVG make up the whole sheet
+	files := []string{r.config.Input}
+	if isDir {
+		if files, err = r.inputFiles(); err != nil {
+			return nil, err
+		}
+	}
+	if err := utils.ValidateFileCount(r.config, len(files), r.spriteCount(files), true); err != nil {
+		return nil, err
+	}
+
+	meta, err := r.generateSpritesheet(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+	return meta, r.failureError(len(files))
+}
+
+// IsSheetInput reports whether the options describe a spritesheet, as
+// opposed to a conversion: the input is a directory, or Frames are captured
+// from an SVG, and spritesheet layout options are set
+func IsSheetInput(opts Options) bool {
+	cfg := opts.Config
+	cfg.SetDefaults()
+
+	isDir, _ := utils.IsDirectory(cfg.Input)
+	return (isDir || cfg.Frames > 0) && cfg.IsSpritesheetMode()
+}
+
+// stdin returns the reader for an Input of "-"
+func (o *Options) stdin() io.Reader {
+	if o.Stdin != nil {
+		return o.Stdin
+	}
+	return os.Stdin
+}
+
+// stdout returns the writer for an Output of "-"
+func (o *Options) stdout() io.Writer {
+	if o.Stdout != nil {
+		return o.Stdout
+	}
+	return os.Stdout
+}
@@ -0,0 +1,335 @@
+package svg2sheet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/thanhfphan/svg2sheet/internal/logging"
+	"github.com/thanhfphan/svg2sheet/internal/metadata"
+)
+
+// writeSVG writes a size x size square SVG filled with fill to path,
+// creating its directory
+func writeSVG(t *testing.T, path, fill string, size int) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d"><rect width="%d" height="%d" fill="%s"/></svg>`, size, size, size, size, fill)
+	if err := os.WriteFile(path, []byte(svg), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testOptions returns options for cfg that log nowhere
+func testOptions(cfg Config) Options {
+	return Options{Config: cfg, Logger: logging.Discard()}
+}
+
+// spriteNames returns the sorted names of the sprites of meta
+func spriteNames(meta *Metadata) []string {
+	names := make([]string, len(meta.Sprites))
+	for i, sprite := range meta.Sprites {
+		names[i] = sprite.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// collidingTree writes two play.svg files in different subdirectories and a
+// play_1.svg that a plain rename would clash with, and returns the directory
+func collidingTree(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeSVG(t, filepath.Join(dir, "a", "play.svg"), "red", 16)
+	writeSVG(t, filepath.Join(dir, "b", "play.svg"), "blue", 16)
+	writeSVG(t, filepath.Join(dir, "play_1.svg"), "green", 16)
+	return dir
+}
+
+func TestOnCollisionSpritesheet(t *testing.T) {
+	tests := []struct {
+		policy  string
+		want    []string
+		wantErr bool
+	}{
+		{policy: "warn", want: []string{"play", "play", "play_1"}},
+		{policy: "error", wantErr: true},
+		{policy: "skip", want: []string{"play", "play_1"}},
+		{policy: "rename", want: []string{"play", "play_1", "play_2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			dir := collidingTree(t)
+			output := filepath.Join(t.TempDir(), "sheet.png")
+
+			meta, err := GenerateSheet(context.Background(), testOptions(Config{
+				Input:       dir,
+				Output:      output,
+				Pack:        true,
+				OnCollision: tt.policy,
+			}))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("GenerateSheet succeeded, want a collision error")
+				}
+				if _, statErr := os.Stat(output); !os.IsNotExist(statErr) {
+					t.Error("the sheet was written despite the collision error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateSheet: %v", err)
+			}
+
+			if got := spriteNames(meta); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("sprite names = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOnCollisionConvert(t *testing.T) {
+	tests := []struct {
+		policy  string
+		want    []string
+		wantErr bool
+	}{
+		{policy: "error", wantErr: true},
+		{policy: "skip", want: []string{"play.png", "play_1.png"}},
+		{policy: "rename", want: []string{"play.png", "play_1.png", "play_2.png"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			dir := collidingTree(t)
+			output := filepath.Join(t.TempDir(), "out")
+
+			result, err := Convert(context.Background(), testOptions(Config{
+				Input:       dir,
+				Output:      output,
+				OnCollision: tt.policy,
+			}))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Convert succeeded, want a collision error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Convert: %v", err)
+			}
+
+			entries, err := os.ReadDir(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var written []string
+			for _, entry := range entries {
+				written = append(written, entry.Name())
+			}
+			if fmt.Sprint(written) != fmt.Sprint(tt.want) {
+				t.Errorf("wrote %v, want %v", written, tt.want)
+			}
+			if len(result.Files) != len(tt.want) {
+				t.Errorf("result lists %d files, want %d", len(result.Files), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestNameCaseKeepsNamesUnique(t *testing.T) {
+	// Both files normalize to my_icon_v2
+	dir := t.TempDir()
+	writeSVG(t, filepath.Join(dir, "My Icon (v2).svg"), "red", 16)
+	writeSVG(t, filepath.Join(dir, "my-icon-v2.svg"), "blue", 16)
+	writeSVG(t, filepath.Join(dir, "Other Thing.svg"), "green", 16)
+
+	tests := []struct {
+		policy  string
+		want    []string
+		wantErr bool
+	}{
+		{policy: "error", wantErr: true},
+		{policy: "rename", want: []string{"my_icon_v2", "my_icon_v2_1", "other_thing"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			meta, err := GenerateSheet(context.Background(), testOptions(Config{
+				Input:       dir,
+				Output:      filepath.Join(t.TempDir(), "sheet.png"),
+				Pack:        true,
+				NameCase:    "snake",
+				OnCollision: tt.policy,
+			}))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("GenerateSheet succeeded, want an error for the normalized names")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateSheet: %v", err)
+			}
+
+			if got := spriteNames(meta); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("sprite names = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupByPrefix(t *testing.T) {
+	// Written interleaved; natural sorting brings each animation together
+	dir := t.TempDir()
+	for i, name := range []string{"walk_1", "idle_1", "walk_2", "idle_2", "walk_10", "logo"} {
+		writeSVG(t, filepath.Join(dir, name+".svg"), fmt.Sprintf("#%02x0000", 40*i), 8)
+	}
+
+	want := []metadata.Animation{
+		{Name: "idle", From: 0, To: 1, Direction: "forward"},
+		{Name: "walk", From: 3, To: 5, Direction: "forward"},
+	}
+
+	for _, format := range []string{"native", "texturepacker-hash"} {
+		t.Run(format, func(t *testing.T) {
+			out := t.TempDir()
+			metaPath := filepath.Join(out, "sheet.json")
+
+			meta, err := GenerateSheet(context.Background(), testOptions(Config{
+				Input:         dir,
+				Output:        filepath.Join(out, "sheet.png"),
+				Meta:          metaPath,
+				MetaFormat:    format,
+				Pack:          true,
+				Sort:          "natural",
+				GroupByPrefix: true,
+			}))
+			if err != nil {
+				t.Fatalf("GenerateSheet: %v", err)
+			}
+			if !reflect.DeepEqual(meta.Animations, want) {
+				t.Errorf("animations = %+v, want %+v", meta.Animations, want)
+			}
+
+			data, err := os.ReadFile(metaPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var written struct {
+				Animations []metadata.Animation `json:"animations"`
+				Meta       struct {
+					FrameTags []metadata.Animation `json:"frameTags"`
+				} `json:"meta"`
+			}
+			if err := json.Unmarshal(data, &written); err != nil {
+				t.Fatalf("parsing %s metadata: %v", format, err)
+			}
+			got := written.Animations
+			if format != "native" {
+				got = written.Meta.FrameTags
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s metadata has animations %+v, want %+v", format, got, want)
+			}
+		})
+	}
+}
+
+func TestDedupeAliases(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"ok", "ok_copy", "ok_old"} {
+		writeSVG(t, filepath.Join(dir, name+".svg"), "green", 16)
+	}
+	writeSVG(t, filepath.Join(dir, "stop.svg"), "red", 16)
+
+	type frame struct {
+		Frame struct {
+			X, Y, W, H int
+		} `json:"frame"`
+		Aliases []string `json:"aliases"`
+	}
+
+	for _, format := range []string{"native", "texturepacker-hash", "texturepacker-array"} {
+		t.Run(format, func(t *testing.T) {
+			out := t.TempDir()
+			metaPath := filepath.Join(out, "sheet.json")
+
+			meta, err := GenerateSheet(context.Background(), testOptions(Config{
+				Input:      dir,
+				Output:     filepath.Join(out, "sheet.png"),
+				Meta:       metaPath,
+				MetaFormat: format,
+				Pack:       true,
+				Dedupe:     true,
+			}))
+			if err != nil {
+				t.Fatalf("GenerateSheet: %v", err)
+			}
+
+			// The three green sprites share one drawn region
+			regions := make(map[[2]int]bool)
+			for _, sprite := range meta.Sprites {
+				regions[[2]int{sprite.X, sprite.Y}] = true
+			}
+			if len(regions) != 2 {
+				t.Errorf("sprites use %d regions, want 2", len(regions))
+			}
+
+			data, err := os.ReadFile(metaPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			aliases := make(map[string][]string)
+			switch format {
+			case "native":
+				var written Metadata
+				if err := json.Unmarshal(data, &written); err != nil {
+					t.Fatal(err)
+				}
+				for _, sprite := range written.Sprites {
+					aliases[sprite.Name] = sprite.Aliases
+				}
+			case "texturepacker-hash":
+				var written struct {
+					Frames map[string]frame `json:"frames"`
+				}
+				if err := json.Unmarshal(data, &written); err != nil {
+					t.Fatal(err)
+				}
+				for name, f := range written.Frames {
+					aliases[name] = f.Aliases
+				}
+			default:
+				var written struct {
+					Frames []struct {
+						frame
+						Filename string `json:"filename"`
+					} `json:"frames"`
+				}
+				if err := json.Unmarshal(data, &written); err != nil {
+					t.Fatal(err)
+				}
+				for _, f := range written.Frames {
+					aliases[f.Filename] = f.Aliases
+				}
+			}
+
+			want := map[string][]string{"ok": {"ok_copy", "ok_old"}, "ok_copy": nil, "ok_old": nil, "stop": nil}
+			if !reflect.DeepEqual(aliases, want) {
+				t.Errorf("aliases = %v, want %v", aliases, want)
+			}
+		})
+	}
+}